@@ -4,41 +4,253 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"tempmail/internal/config"
 	"tempmail/internal/database"
 	"tempmail/internal/handlers"
+	"tempmail/internal/inbox"
+	"tempmail/internal/models"
+	"tempmail/internal/scheduler"
+	"tempmail/internal/secrets"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
 	godotenv.Load()
+
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
 	port := config.GetPort()
-	database.InitDB()
+	loadMasterKey()
+	database.InitDB(config.IsAutoMigrate())
+	store.Use(database.DB)
+
+	// Segredos gravados antes da cifragem são cifrados agora; se algum não abrir,
+	// a chave mestra é a errada e não adianta subir
+	sealed, err := store.Secrets.Seal()
+	if err != nil {
+		log.Fatal("Os segredos do banco não abrem com esta chave mestra: ", err)
+	}
+	if sealed > 0 {
+		log.Printf("🔐 %d segredo(s) em texto puro cifrado(s)", sealed)
+	}
+
+	if config.IsDemoMode() {
+		log.Println("⚠️ Modo demo: Cloudflare simulada em memória, nenhuma regra real será criada")
+		services.CF = services.NewFakeCloudflare(true)
+	} else {
+		cf := services.NewCloudflare(config.GetCloudflareURL(), services.NewHTTPClient(config.GetCloudflareTimeout()))
+		cf.SetGraphQLURL(config.GetCloudflareGraphQLURL())
+		services.CF = cf
+	}
+	if ttl := config.GetDestinationCacheTTL(); ttl > 0 {
+		services.CF = services.NewCachedCloudflare(services.CF, ttl)
+	}
+
+	// Rearma (ou executa) as expirações que estavam pendentes antes do restart
+	if err := scheduler.Restore(); err != nil {
+		log.Println("Erro ao restaurar expirações:", err)
+	}
+	scheduler.StartSweeper(time.Minute)
+	scheduler.StartReconciler(config.GetSyncInterval())
+	scheduler.StartAnalytics(config.GetAnalyticsInterval())
+
+	// Reenvio opcional aos destinos do que chega pelo SMTP embutido ou pelo Worker
+	if relayAddr := config.GetRelayAddr(); relayAddr != "" {
+		username, password, err := config.GetRelayAuth()
+		if err != nil {
+			log.Fatal("Erro ao ler a senha do SMTP de saída: ", err)
+		}
+		inbox.Relay = &inbox.SMTPRelay{Addr: relayAddr, Username: username, Password: password, From: config.GetRelayFrom()}
+	}
+
+	// Receptor SMTP opcional: guarda o que chega aos aliases na caixa de entrada do painel
+	if smtpAddr := config.GetSMTPAddr(); smtpAddr != "" {
+		go func() {
+			log.Fatal("Erro no receptor SMTP: ", inbox.ListenAndServe(smtpAddr, config.GetSMTPHostname(), config.GetSMTPMaxSize()))
+		}()
+		log.Printf("📥 Receptor SMTP ouvindo em %s", smtpAddr)
+	}
+
+	handlers.SetTrustedProxies(config.GetTrustedProxies())
+	inboundSecret, err := config.GetInboundSecret()
+	if err != nil {
+		log.Fatal("Erro ao ler o segredo do Worker: ", err)
+	}
+	handlers.SetInbound(inboundSecret, config.GetSMTPMaxSize())
+	if inboundSecret != "" {
+		handlers.StartInboundSweeper(time.Minute)
+	}
+	publicLimiter := services.NewRateLimiter(30, 10)
+	apiLimiter := services.NewRateLimiter(config.GetAPIRateLimit(), config.GetAPIRateLimit())
+	createLimiter := services.NewRateLimiter(config.GetCreateRateLimit(), config.GetCreateRateLimit())
 
 	fs := http.FileServer(http.Dir("./static"))
 	http.Handle("/", fs)
 
-	// Rotas Públicas
+	// Rotas Públicas (limitadas por IP)
+	public := func(h http.HandlerFunc) http.HandlerFunc {
+		return handlers.RateLimit(publicLimiter, h)
+	}
 	http.HandleFunc("/api/status", handlers.HandleStatus)
-	http.HandleFunc("/api/setup", handlers.HandleSetup)
-	http.HandleFunc("/api/login", handlers.HandleLogin)
-
-	// Rotas Protegidas
-	http.HandleFunc("/api/logout", handlers.AuthMiddleware(handlers.HandleLogout))
-	http.HandleFunc("/api/auth/change-password", handlers.AuthMiddleware(handlers.HandleChangePassword)) // NOVA ROTA
-	http.HandleFunc("/api/test-cf", handlers.AuthMiddleware(handlers.HandleTestCloudflare))
-	http.HandleFunc("/api/config", handlers.AuthMiddleware(handlers.HandleConfig))
-	http.HandleFunc("/api/destinations", handlers.AuthMiddleware(handlers.HandleDestinations))
-	http.HandleFunc("/api/check", handlers.AuthMiddleware(handlers.HandleCheck))
-	http.HandleFunc("/api/create", handlers.AuthMiddleware(handlers.HandleCreate))
-	http.HandleFunc("/api/pin", handlers.AuthMiddleware(handlers.HandlePin))
-	http.HandleFunc("/api/active", handlers.AuthMiddleware(handlers.HandleListActive))
-	http.HandleFunc("/api/history", handlers.AuthMiddleware(handlers.HandleHistory))
-	http.HandleFunc("/api/delete", handlers.AuthMiddleware(handlers.HandleDelete))
-	http.HandleFunc("/api/tags", handlers.AuthMiddleware(handlers.HandleTags))
+	http.HandleFunc("/api/setup", public(handlers.HandleSetup))
+	http.HandleFunc("/api/login", public(handlers.HandleLogin))
+	http.HandleFunc("/api/login/2fa", public(handlers.HandleLoginTOTP))
+	http.HandleFunc("/api/refresh", public(handlers.HandleRefresh))
+	http.HandleFunc("/api/inbound", handlers.HandleInbound) // autenticada por HMAC (Email Worker)
+
+	// Rotas Protegidas (sessão do painel ou chave de API com o escopo indicado, limitadas por usuário)
+	auth := func(scope string, h http.HandlerFunc) http.HandlerFunc {
+		return handlers.AuthMiddleware(handlers.RateLimit(apiLimiter, handlers.RequireScope(scope, h)))
+	}
+	admin := func(h http.HandlerFunc) http.HandlerFunc {
+		return auth(models.ScopeAccount, handlers.AdminOnly(h))
+	}
+
+	http.HandleFunc("/api/logout", auth(models.ScopeAccount, handlers.HandleLogout))
+	http.HandleFunc("/api/auth/change-password", auth(models.ScopeAccount, handlers.HandleChangePassword))
+	http.HandleFunc("/api/me", auth(models.ScopeAliasRead, handlers.HandleMe))
+	http.HandleFunc("/api/keys", auth(models.ScopeAccount, handlers.HandleAPIKeys))
+	http.HandleFunc("/api/sessions", auth(models.ScopeAccount, handlers.HandleSessions))
+	http.HandleFunc("/api/2fa", auth(models.ScopeAccount, handlers.HandleTOTPStatus))
+	http.HandleFunc("/api/2fa/setup", auth(models.ScopeAccount, handlers.HandleTOTPSetup))
+	http.HandleFunc("/api/2fa/confirm", auth(models.ScopeAccount, handlers.HandleTOTPConfirm))
+	http.HandleFunc("/api/2fa/disable", auth(models.ScopeAccount, handlers.HandleTOTPDisable))
+	http.HandleFunc("/api/users", admin(handlers.HandleUsers))
+	http.HandleFunc("/api/test-cf", admin(handlers.HandleTestCloudflare))
+	http.HandleFunc("/api/config", auth(models.ScopeAccount, handlers.HandleConfig))
+	http.HandleFunc("/api/domains", auth(models.ScopeAliasRead, handlers.HandleDomains))
+	http.HandleFunc("/api/catch-all", admin(handlers.HandleCatchAll))
+	http.HandleFunc("/api/audit", admin(handlers.HandleAudit))
+	http.HandleFunc("/api/destinations", auth(models.ScopeAliasRead, handlers.HandleDestinations))
+	http.HandleFunc("/api/check", auth(models.ScopeAliasRead, handlers.HandleCheck))
+	http.HandleFunc("/api/create", auth(models.ScopeAliasCreate, handlers.RateLimit(createLimiter, handlers.HandleCreate)))
+	http.HandleFunc("/api/pin", auth(models.ScopeAliasWrite, handlers.HandlePin))
+	http.HandleFunc("/api/burn", auth(models.ScopeAliasWrite, handlers.HandleBurn))
+	http.HandleFunc("/api/update", auth(models.ScopeAliasWrite, handlers.HandleUpdate))
+	http.HandleFunc("/api/active", auth(models.ScopeAliasRead, handlers.HandleListActive))
+	http.HandleFunc("/api/history", auth(models.ScopeAliasRead, handlers.HandleHistory))
+	http.HandleFunc("/api/delete", auth(models.ScopeAliasWrite, handlers.HandleDelete))
+	http.HandleFunc("/api/messages", auth(models.ScopeAliasRead, handlers.HandleMessages))
+	http.HandleFunc("/api/messages/attachment", auth(models.ScopeAliasRead, handlers.HandleAttachment))
+	http.HandleFunc("/api/tags", auth(models.ScopeAliasRead, handlers.HandleTags))
+	http.HandleFunc("/api/sync", admin(handlers.HandleSync))
+	http.HandleFunc("/api/analytics/pull", admin(handlers.HandleAnalyticsPull))
 
 	addr := ":" + port
 	fmt.Printf("🚀 Sistema Mail com JWT rodando em http://localhost%s\n", addr)
 	log.Fatal(http.ListenAndServe(addr, nil))
-}
\ No newline at end of file
+}
+
+// runCommand executa os subcomandos de linha de comando (ex: "tempmail migrate status")
+func runCommand(args []string) {
+	switch args[0] {
+	case "migrate":
+		runMigrate(args[1:])
+	case "keygen":
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			log.Fatal("Erro ao gerar chave:", err)
+		}
+		fmt.Println(key)
+	case "rotate-key":
+		runRotateKey()
+	default:
+		fmt.Fprintf(os.Stderr, "Comando desconhecido: %s\nUso: tempmail [migrate status|up | keygen | rotate-key]\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// runMigrate mostra ou aplica as migrações do banco
+func runMigrate(args []string) {
+	database.Open()
+
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "status":
+		migrations, err := database.MigrationStatus()
+		if err != nil {
+			log.Fatal("Erro ao ler migrações:", err)
+		}
+		pending := 0
+		for _, m := range migrations {
+			state := "pendente"
+			if m.AppliedAt != nil {
+				state = "aplicada em " + m.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			} else {
+				pending++
+			}
+			fmt.Printf("%04d  %-20s %s\n", m.Version, m.Name, state)
+		}
+		fmt.Printf("\n%d migração(ões) pendente(s)\n", pending)
+	case "up":
+		applied, err := database.Migrate()
+		for _, m := range applied {
+			fmt.Printf("✔ %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Erro ao migrar DB:", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Banco já está atualizado")
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "Uso: tempmail migrate status|up")
+		os.Exit(2)
+	}
+}
+
+// loadMasterKey carrega a chave que cifra os segredos do banco; sem ela o sistema não sobe
+func loadMasterKey() {
+	raw, err := config.GetMasterKey()
+	if err != nil {
+		log.Fatal("Erro ao ler a chave mestra: ", err)
+	}
+	if raw == "" {
+		log.Fatal("Chave mestra ausente: defina TEMPMAIL_MASTER_KEY ou TEMPMAIL_MASTER_KEY_FILE (gere uma com \"tempmail keygen\")")
+	}
+	key, err := secrets.ParseKey(raw)
+	if err != nil {
+		log.Fatal("Chave mestra inválida: ", err)
+	}
+	if err := secrets.SetMasterKey(key); err != nil {
+		log.Fatal("Chave mestra inválida: ", err)
+	}
+}
+
+// runRotateKey recifra os segredos do banco com TEMPMAIL_NEW_MASTER_KEY
+func runRotateKey() {
+	loadMasterKey()
+
+	raw, err := config.GetNewMasterKey()
+	if err != nil {
+		log.Fatal("Erro ao ler a nova chave mestra: ", err)
+	}
+	if raw == "" {
+		log.Fatal("Defina a nova chave em TEMPMAIL_NEW_MASTER_KEY ou TEMPMAIL_NEW_MASTER_KEY_FILE")
+	}
+	newKey, err := secrets.ParseKey(raw)
+	if err != nil {
+		log.Fatal("Nova chave mestra inválida: ", err)
+	}
+
+	database.Open()
+	store.Use(database.DB)
+	count, err := store.Secrets.Rotate(newKey)
+	if err != nil {
+		log.Fatal("Erro ao rotacionar a chave (nada foi alterado): ", err)
+	}
+	fmt.Printf("✔ %d segredo(s) recifrado(s)\n", count)
+	fmt.Println("Troque TEMPMAIL_MASTER_KEY pela nova chave e reinicie todas as instâncias")
+}
@@ -3,74 +3,66 @@ package database
 import (
 	"database/sql"
 	"log"
-	"tempmail/internal/models"
+	"tempmail/internal/config"
 
 	_ "github.com/glebarez/go-sqlite"
 )
 
 var DB *sql.DB
 
-func InitDB() {
+// Dialetos suportados (DB_DRIVER)
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// Dialect é o banco em uso, definido por Open
+var Dialect = DialectSQLite
+
+// Open abre o banco configurado (DB_DRIVER e DATABASE_URL) sem alterar o schema
+func Open() {
 	var err error
-	DB, err = sql.Open("sqlite", "./data/data.db")
+	Dialect = config.GetDBDriver()
+	switch Dialect {
+	case DialectSQLite:
+		DB, err = sql.Open("sqlite", config.GetDatabaseURL())
+	case DialectPostgres:
+		if config.GetDatabaseURL() == "" {
+			log.Fatal("DATABASE_URL é obrigatório com DB_DRIVER=postgres")
+		}
+		DB, err = openPostgres(config.GetDatabaseURL())
+	default:
+		log.Fatalf("DB_DRIVER desconhecido: %q (use sqlite ou postgres)", Dialect)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	_, err = DB.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT UNIQUE,
-			password TEXT,
-			full_name TEXT,
-			created_at DATETIME
-		);
-		CREATE TABLE IF NOT EXISTS config (
-			id INTEGER PRIMARY KEY CHECK (id = 1),
-			cf_token TEXT,
-			zone_id TEXT,
-			domain TEXT
-		);
-		CREATE TABLE IF NOT EXISTS emails (
-			id TEXT PRIMARY KEY,
-			email TEXT UNIQUE,
-			destination TEXT,
-			created_at DATETIME,
-			active BOOLEAN,
-			pinned BOOLEAN DEFAULT 0
-		);
-		CREATE TABLE IF NOT EXISTS tags (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT UNIQUE,
-			color TEXT
-		);
-		CREATE TABLE IF NOT EXISTS email_tags (
-			email_id TEXT,
-			tag_id INTEGER,
-			PRIMARY KEY (email_id, tag_id),
-			FOREIGN KEY(email_id) REFERENCES emails(id),
-			FOREIGN KEY(tag_id) REFERENCES tags(id)
-		);
-	`)
-	if err != nil {
-		log.Fatal("Erro ao migrar DB:", err)
+	if err := DB.Ping(); err != nil {
+		log.Fatal("Erro ao conectar no banco:", err)
 	}
 }
 
-func IsSetupDone() bool {
-	var count int
-	DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
-	return count > 0
-}
+// InitDB abre o banco e aplica as migrações pendentes. Com autoMigrate desligado,
+// o sistema se recusa a subir enquanto houver migrações pendentes (use "tempmail migrate up").
+func InitDB(autoMigrate bool) {
+	Open()
 
-func GetConfig() (models.Config, error) {
-	var c models.Config
-	err := DB.QueryRow("SELECT cf_token, zone_id, domain FROM config WHERE id = 1").Scan(&c.CFToken, &c.ZoneID, &c.Domain)
-	return c, err
-}
+	if !autoMigrate {
+		pending, err := PendingMigrations()
+		if err != nil {
+			log.Fatal("Erro ao verificar migrações:", err)
+		}
+		if len(pending) > 0 {
+			log.Fatalf("Há %d migração(ões) pendente(s). Rode \"tempmail migrate up\" antes de iniciar.", len(pending))
+		}
+		return
+	}
 
-func EmailExists(email string) bool {
-	var exists bool
-	DB.QueryRow("SELECT EXISTS(SELECT 1 FROM emails WHERE email = ?)", email).Scan(&exists)
-	return exists
-}
\ No newline at end of file
+	applied, err := Migrate()
+	for _, m := range applied {
+		log.Printf("🗄️ Migração aplicada: %04d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatal("Erro ao migrar DB:", err)
+	}
+}
@@ -0,0 +1,145 @@
+package database
+
+import "database/sql"
+
+// legacyVersion é a versão em que se encontra um banco criado antes do controle de versões,
+// depois de passar por upgradeLegacySchema
+const legacyVersion = 6
+
+// upgradeLegacySchema leva um banco da época do script único (qualquer versão intermediária)
+// ao schema da migração legacyVersion. Nada aqui deve mudar: o schema novo vai em migrations/.
+// Roda dentro da transação que também registra as versões, para não deixar o banco pela metade.
+func upgradeLegacySchema(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER,
+			name TEXT,
+			prefix TEXT,
+			key_hash TEXT UNIQUE,
+			scopes TEXT,
+			created_at DATETIME,
+			last_used_at DATETIME,
+			revoked BOOLEAN DEFAULT 0,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+		CREATE TABLE IF NOT EXISTS sessions (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER,
+			refresh_hash TEXT UNIQUE,
+			user_agent TEXT,
+			ip TEXT,
+			created_at DATETIME,
+			last_seen_at DATETIME,
+			expires_at DATETIME,
+			revoked BOOLEAN DEFAULT 0,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+		CREATE TABLE IF NOT EXISTS session_refresh_history (
+			refresh_hash TEXT PRIMARY KEY,
+			session_id TEXT,
+			rotated_at DATETIME,
+			FOREIGN KEY(session_id) REFERENCES sessions(id)
+		);
+		CREATE TABLE IF NOT EXISTS recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER,
+			code_hash TEXT,
+			used_at DATETIME,
+			FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Colunas adicionadas depois da primeira versão do schema
+	columns := [][3]string{
+		{"emails", "pinned", "BOOLEAN DEFAULT 0"},
+		{"emails", "expires_at", "DATETIME"},
+		{"emails", "ttl", "INTEGER"},
+		{"emails", "owner_id", "INTEGER"},
+		{"users", "role", "TEXT DEFAULT 'user'"},
+		{"users", "disabled", "BOOLEAN DEFAULT 0"},
+		{"users", "password_changed_at", "DATETIME"},
+		{"users", "totp_secret", "TEXT"},
+		{"users", "totp_enabled", "BOOLEAN DEFAULT 0"},
+		{"users", "totp_last_step", "INTEGER DEFAULT 0"},
+	}
+	for _, c := range columns {
+		added, err := addColumn(tx, c[0], c[1], c[2])
+		if err != nil {
+			return err
+		}
+		// Em instalações antigas o único usuário existente era o administrador
+		if added && c[1] == "role" {
+			if _, err := tx.Exec("UPDATE users SET role = 'admin' WHERE id = (SELECT MIN(id) FROM users)"); err != nil {
+				return err
+			}
+		}
+	}
+	if err := migrateTagsOwner(tx); err != nil {
+		return err
+	}
+
+	// Dados criados antes do multiusuário pertencem ao primeiro administrador
+	if _, err := tx.Exec("UPDATE emails SET owner_id = (SELECT MIN(id) FROM users WHERE role = 'admin') WHERE owner_id IS NULL"); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE tags SET owner_id = (SELECT MIN(id) FROM users WHERE role = 'admin') WHERE owner_id IS NULL")
+	return err
+}
+
+// hasColumn verifica se a coluna já existe na tabela
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// addColumn cria a coluna apenas se ela ainda não existir, para que bancos antigos sejam atualizados.
+// Retorna true quando a coluna foi de fato criada.
+func addColumn(tx *sql.Tx, table, column, definition string) (bool, error) {
+	exists, err := hasColumn(tx, table, column)
+	if err != nil || exists {
+		return false, err
+	}
+	_, err = tx.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err == nil, err
+}
+
+// migrateTagsOwner recria a tabela tags de bancos antigos: o nome deixa de ser único
+// globalmente e passa a ser único por dono. O SQLite não altera constraints via ALTER TABLE.
+func migrateTagsOwner(tx *sql.Tx) error {
+	exists, err := hasColumn(tx, "tags", "owner_id")
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		CREATE TABLE tags_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner_id INTEGER,
+			name TEXT,
+			color TEXT,
+			UNIQUE(owner_id, name)
+		);
+		INSERT INTO tags_new (id, owner_id, name, color) SELECT id, NULL, name, color FROM tags;
+		DROP TABLE tags;
+		ALTER TABLE tags_new RENAME TO tags;
+	`)
+	return err
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Os arquivos de migração seguem o formato NNNN_descricao.sql e são aplicados em ordem.
+// Cada dialeto tem sua pasta (migrations/sqlite e migrations/postgres) com as mesmas versões.
+// Uma migração já publicada nunca deve ser editada: mudanças novas vão em um arquivo novo.
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// Migration é um passo do schema
+type Migration struct {
+	Version   int
+	Name      string
+	SQL       string
+	AppliedAt *time.Time
+}
+
+// loadMigrations lê as migrações do dialeto em uso, ordenadas por versão
+func loadMigrations() ([]Migration, error) {
+	dir := "migrations/" + Dialect
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Migration
+	seen := make(map[int]string)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".sql")
+		num, desc, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("nome de migração inválido: %s", e.Name())
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("versão %d duplicada: %s e %s", version, other, e.Name())
+		}
+		seen[version] = e.Name()
+
+		body, err := migrationFiles.ReadFile(dir + "/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Migration{Version: version, Name: desc, SQL: string(body)})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// ensureMigrationsTable cria a tabela de controle. Um banco SQLite anterior ao controle de
+// versões (com tabelas mas sem schema_migrations) é atualizado pelo caminho legado e marcado
+// como estando na versão legacyVersion, tudo na mesma transação. O PostgreSQL já nasceu com
+// migrações.
+func ensureMigrationsTable() error {
+	tracked, err := tableExists("schema_migrations")
+	if err != nil || tracked {
+		return err
+	}
+	legacy := false
+	if Dialect == DialectSQLite {
+		if legacy, err = tableExists("users"); err != nil {
+			return err
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if legacy {
+		if err := upgradeLegacySchema(tx); err != nil {
+			return fmt.Errorf("atualizando banco legado: %w", err)
+		}
+	}
+
+	timestamp := "DATETIME"
+	if Dialect == DialectPostgres {
+		timestamp = "TIMESTAMPTZ"
+	}
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT,
+			applied_at ` + timestamp + `
+		)`)
+	if err != nil {
+		return err
+	}
+
+	if legacy {
+		migrations, err := loadMigrations()
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if m.Version > legacyVersion {
+				break
+			}
+			if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// tableExists consulta o catálogo do banco em uso
+func tableExists(name string) (bool, error) {
+	query := "SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)"
+	if Dialect == DialectPostgres {
+		query = "SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?)"
+	}
+	var exists bool
+	err := DB.QueryRow(query, name).Scan(&exists)
+	return exists, err
+}
+
+// MigrationStatus lista todas as migrações conhecidas e quando cada uma foi aplicada
+// (AppliedAt nil = pendente)
+func MigrationStatus() ([]Migration, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time)
+	rows, err := DB.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var at sql.NullTime
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at.Time
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range migrations {
+		if at, ok := applied[migrations[i].Version]; ok {
+			migrations[i].AppliedAt = &at
+		}
+	}
+	return migrations, nil
+}
+
+// PendingMigrations retorna as migrações ainda não aplicadas
+func PendingMigrations() ([]Migration, error) {
+	all, err := MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range all {
+		if m.AppliedAt == nil {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate aplica as migrações pendentes em ordem, cada uma em sua própria transação
+// junto com o registro em schema_migrations. Para na primeira falha.
+func Migrate() ([]Migration, error) {
+	pending, err := PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range pending {
+		applied, err := applyMigration(m)
+		if err != nil {
+			return done, fmt.Errorf("migração %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if applied {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// migrationLockID identifica o advisory lock que serializa as migrações no PostgreSQL
+const migrationLockID = 7370706
+
+// applyMigration aplica uma migração; false quando outra instância já a aplicou
+func applyMigration(m Migration) (bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Várias réplicas podem subir juntas contra o mesmo PostgreSQL: só uma migra por vez
+	// e as demais encontram a versão já registrada
+	if Dialect == DialectPostgres {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID); err != nil {
+			return false, err
+		}
+		var done bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)", m.Version).Scan(&done); err != nil || done {
+			return false, err
+		}
+	}
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, time.Now()); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
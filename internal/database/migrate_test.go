@@ -0,0 +1,152 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTemp abre um banco SQLite vazio em um diretório temporário
+func openTemp(t *testing.T) {
+	t.Helper()
+	t.Setenv("DB_DRIVER", DialectSQLite)
+	t.Setenv("DATABASE_URL", filepath.Join(t.TempDir(), "data.db"))
+	Open()
+	db := DB
+	t.Cleanup(func() { db.Close() })
+}
+
+func TestLoadMigrationsOrdered(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) < legacyVersion {
+		t.Fatalf("%d migrações, esperado ao menos %d", len(migrations), legacyVersion)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 || m.Name == "" || strings.TrimSpace(m.SQL) == "" {
+			t.Errorf("migração %d fora de ordem ou vazia: %04d_%s", i, m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	openTemp(t)
+
+	status, err := MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range status {
+		if m.AppliedAt != nil {
+			t.Fatalf("banco novo com a migração %04d aplicada", m.Version)
+		}
+	}
+
+	applied, err := Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(status) {
+		t.Fatalf("%d migrações aplicadas, esperado %d", len(applied), len(status))
+	}
+	for i := 1; i < len(applied); i++ {
+		if applied[i].Version <= applied[i-1].Version {
+			t.Fatalf("aplicadas fora de ordem: %04d depois de %04d", applied[i].Version, applied[i-1].Version)
+		}
+	}
+
+	// Rodar de novo não aplica nada
+	again, err := Migrate()
+	if err != nil || len(again) != 0 {
+		t.Fatalf("segunda execução aplicou %d migrações: %v", len(again), err)
+	}
+	pending, err := PendingMigrations()
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("%d pendentes após migrar: %v", len(pending), err)
+	}
+	status, err = MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range status {
+		if m.AppliedAt == nil || time.Since(*m.AppliedAt) > time.Minute {
+			t.Errorf("migração %04d_%s sem data de aplicação válida: %v", m.Version, m.Name, m.AppliedAt)
+		}
+	}
+}
+
+func TestUpgradeLegacyDatabase(t *testing.T) {
+	openTemp(t)
+
+	// Banco criado pelo script único original, ainda sem a coluna pinned
+	baseline, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := strings.Replace(baseline[0].SQL, ",\n\tpinned BOOLEAN DEFAULT 0", "", 1)
+	if _, err := DB.Exec(schema); err != nil {
+		t.Fatal(err)
+	}
+	_, err = DB.Exec(`
+		INSERT INTO users (id, username, password, full_name, created_at) VALUES (1, 'ana', 'x', 'Ana', CURRENT_TIMESTAMP);
+		INSERT INTO emails (id, email, destination, created_at, active) VALUES ('r1', 'a@exemplo.test', 'ana@dest.test', CURRENT_TIMESTAMP, 1);
+		INSERT INTO tags (id, name, color) VALUES (1, 'compras', '#fff');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var role string
+	var emailOwner, tagOwner int64
+	var pinned bool
+	DB.QueryRow("SELECT role FROM users WHERE id = 1").Scan(&role)
+	DB.QueryRow("SELECT owner_id, pinned FROM emails WHERE id = 'r1'").Scan(&emailOwner, &pinned)
+	DB.QueryRow("SELECT owner_id FROM tags WHERE id = 1").Scan(&tagOwner)
+	if role != "admin" || emailOwner != 1 || tagOwner != 1 || pinned {
+		t.Fatalf("dados legados: role=%q, dono do alias=%d, dono da tag=%d, pinned=%v", role, emailOwner, tagOwner, pinned)
+	}
+
+	status, err := MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range status {
+		if m.AppliedAt == nil {
+			t.Errorf("migração %04d_%s pendente após a atualização", m.Version, m.Name)
+		}
+	}
+}
+
+func TestUpgradeLegacyDatabaseIsAtomic(t *testing.T) {
+	openTemp(t)
+
+	// Sem a coluna color a recriação de tags falha no meio da atualização
+	_, err := DB.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT UNIQUE, password TEXT, full_name TEXT, created_at DATETIME);
+		CREATE TABLE emails (id TEXT PRIMARY KEY, email TEXT UNIQUE, destination TEXT, created_at DATETIME, active BOOLEAN);
+		CREATE TABLE tags (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT UNIQUE);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Migrate(); err == nil {
+		t.Fatal("atualização de um banco inválido não falhou")
+	}
+
+	var tracked, apiKeys bool
+	DB.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE name = 'schema_migrations')").Scan(&tracked)
+	DB.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE name = 'api_keys')").Scan(&apiKeys)
+	var columns int
+	DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('emails')").Scan(&columns)
+	if tracked || apiKeys || columns != 5 {
+		t.Fatalf("atualização parcial ficou no banco: schema_migrations=%v, api_keys=%v, colunas em emails=%d", tracked, apiKeys, columns)
+	}
+}
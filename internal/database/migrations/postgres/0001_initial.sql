@@ -0,0 +1,32 @@
+-- Schema original (anterior ao controle de versões)
+CREATE TABLE users (
+	id BIGSERIAL PRIMARY KEY,
+	username TEXT UNIQUE,
+	password TEXT,
+	full_name TEXT,
+	created_at TIMESTAMPTZ
+);
+CREATE TABLE config (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	cf_token TEXT,
+	zone_id TEXT,
+	domain TEXT
+);
+CREATE TABLE emails (
+	id TEXT PRIMARY KEY,
+	email TEXT UNIQUE,
+	destination TEXT,
+	created_at TIMESTAMPTZ,
+	active BOOLEAN,
+	pinned BOOLEAN DEFAULT FALSE
+);
+CREATE TABLE tags (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT UNIQUE,
+	color TEXT
+);
+CREATE TABLE email_tags (
+	email_id TEXT REFERENCES emails(id),
+	tag_id BIGINT REFERENCES tags(id),
+	PRIMARY KEY (email_id, tag_id)
+);
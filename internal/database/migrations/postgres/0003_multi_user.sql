@@ -0,0 +1,14 @@
+-- Contas com papéis; aliases e tags passam a ter dono
+ALTER TABLE users ADD COLUMN role TEXT DEFAULT 'user';
+ALTER TABLE users ADD COLUMN disabled BOOLEAN DEFAULT FALSE;
+ALTER TABLE emails ADD COLUMN owner_id BIGINT;
+
+UPDATE users SET role = 'admin' WHERE id = (SELECT MIN(id) FROM users);
+
+-- O nome da tag deixa de ser único globalmente e passa a ser único por dono
+ALTER TABLE tags ADD COLUMN owner_id BIGINT;
+ALTER TABLE tags DROP CONSTRAINT tags_name_key;
+ALTER TABLE tags ADD CONSTRAINT tags_owner_name_key UNIQUE (owner_id, name);
+
+UPDATE emails SET owner_id = (SELECT MIN(id) FROM users WHERE role = 'admin') WHERE owner_id IS NULL;
+UPDATE tags SET owner_id = (SELECT MIN(id) FROM users WHERE role = 'admin') WHERE owner_id IS NULL;
@@ -0,0 +1,12 @@
+-- Chaves de API nomeadas, com escopos e revogáveis
+CREATE TABLE api_keys (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT REFERENCES users(id),
+	name TEXT,
+	prefix TEXT,
+	key_hash TEXT UNIQUE,
+	scopes TEXT,
+	created_at TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	revoked BOOLEAN DEFAULT FALSE
+);
@@ -0,0 +1,19 @@
+-- Sessões no servidor com rotação de refresh token
+CREATE TABLE sessions (
+	id TEXT PRIMARY KEY,
+	user_id BIGINT REFERENCES users(id),
+	refresh_hash TEXT UNIQUE,
+	user_agent TEXT,
+	ip TEXT,
+	created_at TIMESTAMPTZ,
+	last_seen_at TIMESTAMPTZ,
+	expires_at TIMESTAMPTZ,
+	revoked BOOLEAN DEFAULT FALSE
+);
+-- Refresh tokens já trocados: reapresentar qualquer um deles é reuso
+CREATE TABLE session_refresh_history (
+	refresh_hash TEXT PRIMARY KEY,
+	session_id TEXT REFERENCES sessions(id),
+	rotated_at TIMESTAMPTZ
+);
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMPTZ;
@@ -0,0 +1,10 @@
+-- Autenticação em duas etapas (TOTP) e códigos de recuperação
+ALTER TABLE users ADD COLUMN totp_secret TEXT;
+ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT DEFAULT 0;
+CREATE TABLE recovery_codes (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT REFERENCES users(id),
+	code_hash TEXT,
+	used_at TIMESTAMPTZ
+);
@@ -0,0 +1,25 @@
+-- Caixa de entrada: mensagens recebidas pelo SMTP embutido, ligadas ao alias pelo endereço
+-- (o ID do alias muda quando ele é recriado). Os anexos ficam em tabela própria.
+CREATE TABLE messages (
+	id BIGSERIAL PRIMARY KEY,
+	alias TEXT NOT NULL,
+	mail_from TEXT,
+	header_from TEXT,
+	subject TEXT,
+	headers TEXT,
+	text_body TEXT,
+	html_body TEXT,
+	size BIGINT,
+	seen BOOLEAN DEFAULT FALSE,
+	received_at TIMESTAMPTZ
+);
+CREATE INDEX idx_messages_alias ON messages(alias, received_at);
+CREATE TABLE message_attachments (
+	id BIGSERIAL PRIMARY KEY,
+	message_id BIGINT REFERENCES messages(id),
+	filename TEXT,
+	content_type TEXT,
+	size BIGINT,
+	data BYTEA
+);
+CREATE INDEX idx_message_attachments_message ON message_attachments(message_id);
@@ -0,0 +1,14 @@
+-- Zonas roteadas para um Email Worker: as regras dos aliases apontam para o script, que
+-- entrega a mensagem em /api/inbound em vez de a Cloudflare encaminhar
+ALTER TABLE domains ADD COLUMN worker TEXT;
+
+-- Contador de mensagens que chegaram ao sistema (SMTP embutido ou Worker) por alias
+ALTER TABLE emails ADD COLUMN hits BIGINT DEFAULT 0;
+ALTER TABLE emails ADD COLUMN last_hit_at TIMESTAMPTZ;
+
+-- Assinaturas de entregas do Worker já aceitas, compartilhadas entre as instâncias para
+-- barrar reenvios; as vencidas são apagadas periodicamente
+CREATE TABLE inbound_signatures (
+	signature TEXT PRIMARY KEY,
+	expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,34 @@
+-- Schema original (anterior ao controle de versões)
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT UNIQUE,
+	password TEXT,
+	full_name TEXT,
+	created_at DATETIME
+);
+CREATE TABLE config (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	cf_token TEXT,
+	zone_id TEXT,
+	domain TEXT
+);
+CREATE TABLE emails (
+	id TEXT PRIMARY KEY,
+	email TEXT UNIQUE,
+	destination TEXT,
+	created_at DATETIME,
+	active BOOLEAN,
+	pinned BOOLEAN DEFAULT 0
+);
+CREATE TABLE tags (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE,
+	color TEXT
+);
+CREATE TABLE email_tags (
+	email_id TEXT,
+	tag_id INTEGER,
+	PRIMARY KEY (email_id, tag_id),
+	FOREIGN KEY(email_id) REFERENCES emails(id),
+	FOREIGN KEY(tag_id) REFERENCES tags(id)
+);
@@ -0,0 +1,3 @@
+-- Expiração persistida e TTL por alias
+ALTER TABLE emails ADD COLUMN expires_at DATETIME;
+ALTER TABLE emails ADD COLUMN ttl INTEGER;
@@ -0,0 +1,24 @@
+-- Contas com papéis; aliases e tags passam a ter dono
+ALTER TABLE users ADD COLUMN role TEXT DEFAULT 'user';
+ALTER TABLE users ADD COLUMN disabled BOOLEAN DEFAULT 0;
+ALTER TABLE emails ADD COLUMN owner_id INTEGER;
+
+-- Em instalações antigas o único usuário existente era o administrador
+UPDATE users SET role = 'admin' WHERE id = (SELECT MIN(id) FROM users);
+
+-- O nome da tag deixa de ser único globalmente e passa a ser único por dono.
+-- O SQLite não altera constraints via ALTER TABLE, então a tabela é recriada.
+CREATE TABLE tags_new (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	owner_id INTEGER,
+	name TEXT,
+	color TEXT,
+	UNIQUE(owner_id, name)
+);
+INSERT INTO tags_new (id, owner_id, name, color) SELECT id, NULL, name, color FROM tags;
+DROP TABLE tags;
+ALTER TABLE tags_new RENAME TO tags;
+
+-- Dados criados antes do multiusuário pertencem ao primeiro administrador
+UPDATE emails SET owner_id = (SELECT MIN(id) FROM users WHERE role = 'admin') WHERE owner_id IS NULL;
+UPDATE tags SET owner_id = (SELECT MIN(id) FROM users WHERE role = 'admin') WHERE owner_id IS NULL;
@@ -0,0 +1,13 @@
+-- Chaves de API nomeadas, com escopos e revogáveis
+CREATE TABLE api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	name TEXT,
+	prefix TEXT,
+	key_hash TEXT UNIQUE,
+	scopes TEXT,
+	created_at DATETIME,
+	last_used_at DATETIME,
+	revoked BOOLEAN DEFAULT 0,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
@@ -0,0 +1,21 @@
+-- Sessões no servidor com rotação de refresh token
+CREATE TABLE sessions (
+	id TEXT PRIMARY KEY,
+	user_id INTEGER,
+	refresh_hash TEXT UNIQUE,
+	user_agent TEXT,
+	ip TEXT,
+	created_at DATETIME,
+	last_seen_at DATETIME,
+	expires_at DATETIME,
+	revoked BOOLEAN DEFAULT 0,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
+-- Refresh tokens já trocados: reapresentar qualquer um deles é reuso
+CREATE TABLE session_refresh_history (
+	refresh_hash TEXT PRIMARY KEY,
+	session_id TEXT,
+	rotated_at DATETIME,
+	FOREIGN KEY(session_id) REFERENCES sessions(id)
+);
+ALTER TABLE users ADD COLUMN password_changed_at DATETIME;
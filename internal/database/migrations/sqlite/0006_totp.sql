@@ -0,0 +1,11 @@
+-- Autenticação em duas etapas (TOTP) e códigos de recuperação
+ALTER TABLE users ADD COLUMN totp_secret TEXT;
+ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER DEFAULT 0;
+CREATE TABLE recovery_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	code_hash TEXT,
+	used_at DATETIME,
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
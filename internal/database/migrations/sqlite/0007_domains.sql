@@ -0,0 +1,21 @@
+-- Vários domínios (zonas da Cloudflare) por instalação, cada um com token e conta próprios
+CREATE TABLE domains (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	domain TEXT UNIQUE NOT NULL,
+	zone_id TEXT NOT NULL,
+	account_id TEXT,
+	cf_token TEXT,
+	is_default BOOLEAN DEFAULT FALSE,
+	created_at DATETIME
+);
+
+-- A configuração de linha única vira o domínio padrão
+INSERT INTO domains (domain, zone_id, cf_token, is_default, created_at)
+SELECT LOWER(domain), zone_id, cf_token, TRUE, CURRENT_TIMESTAMP FROM config
+WHERE id = 1 AND COALESCE(domain, '') <> '';
+
+-- Cada alias guarda em qual zona está sua regra
+ALTER TABLE emails ADD COLUMN domain_id INTEGER REFERENCES domains(id);
+UPDATE emails SET domain_id = (SELECT id FROM domains WHERE is_default = TRUE);
+
+DROP TABLE config;
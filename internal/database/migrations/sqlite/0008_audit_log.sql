@@ -0,0 +1,12 @@
+-- Registro das alterações administrativas: quem fez, quando, de onde e o quê
+CREATE TABLE audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at DATETIME,
+	user_id INTEGER,
+	username TEXT,
+	action TEXT,
+	target TEXT,
+	details TEXT,
+	ip TEXT
+);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
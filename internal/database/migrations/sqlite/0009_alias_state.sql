@@ -0,0 +1,4 @@
+-- Estado do alias: active (encaminhando), burned (regra trocada por drop, endereço
+-- reservado) ou inactive (sem regra). A coluna active continua valendo state = 'active'.
+ALTER TABLE emails ADD COLUMN state TEXT;
+UPDATE emails SET state = CASE WHEN active = TRUE THEN 'active' ELSE 'inactive' END;
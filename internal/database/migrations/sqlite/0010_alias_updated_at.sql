@@ -0,0 +1,2 @@
+-- Última alteração feita no lugar (destino ou tags), exibida no histórico
+ALTER TABLE emails ADD COLUMN updated_at DATETIME;
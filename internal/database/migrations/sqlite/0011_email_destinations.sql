@@ -0,0 +1,10 @@
+-- Destinos de cada alias, na ordem escolhida: um alias pode encaminhar para vários.
+-- emails.destination continua guardando o primeiro (usado nas listagens).
+CREATE TABLE email_destinations (
+	email_id TEXT REFERENCES emails(id),
+	destination TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (email_id, destination)
+);
+INSERT INTO email_destinations (email_id, destination, position)
+SELECT id, destination, 0 FROM emails WHERE destination IS NOT NULL AND destination <> '';
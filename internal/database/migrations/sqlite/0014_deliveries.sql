@@ -0,0 +1,15 @@
+-- Eventos de entrega por alias (ligados pelo endereço, como as mensagens): recebidos pelo
+-- SMTP embutido, pelo Worker ou importados das métricas de Email Routing da Cloudflare.
+-- external_id evita importar o mesmo evento da Cloudflare duas vezes.
+CREATE TABLE deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	alias TEXT NOT NULL,
+	sender TEXT,
+	sender_domain TEXT,
+	status TEXT NOT NULL,
+	source TEXT NOT NULL,
+	external_id TEXT,
+	received_at DATETIME NOT NULL
+);
+CREATE INDEX idx_deliveries_alias ON deliveries(alias, received_at);
+CREATE UNIQUE INDEX idx_deliveries_external ON deliveries(external_id);
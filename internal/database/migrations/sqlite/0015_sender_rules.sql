@@ -0,0 +1,9 @@
+-- Listas de remetentes permitidos (allow) e bloqueados (block) de cada alias, ligadas pelo
+-- endereço para sobreviver à recriação. pattern é um endereço (a@b.com) ou um domínio
+-- (b.com, que vale também para os subdomínios).
+CREATE TABLE sender_rules (
+	alias TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	pattern TEXT NOT NULL,
+	PRIMARY KEY (alias, kind, pattern)
+);
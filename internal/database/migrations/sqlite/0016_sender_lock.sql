@@ -0,0 +1,7 @@
+-- Alias de uso único: o primeiro domínio remetente vira o único permitido (em sender_rules)
+-- e qualquer outro remetente expira o alias na hora
+ALTER TABLE emails ADD COLUMN sender_lock BOOLEAN DEFAULT 0;
+
+-- Motivo da expiração automática (ex: alias de uso único que recebeu de outro remetente),
+-- exibido no histórico; limpo quando o alias é recriado
+ALTER TABLE emails ADD COLUMN expire_reason TEXT;
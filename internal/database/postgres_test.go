@@ -0,0 +1,27 @@
+package database
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"sem placeholders", "SELECT 1", "SELECT 1"},
+		{"em ordem", "UPDATE t SET a = ?, b = ? WHERE id = ?", "UPDATE t SET a = $1, b = $2 WHERE id = $3"},
+		{"colados", "VALUES (?,?)", "VALUES ($1,$2)"},
+		{"acima de nove", "IN (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", "IN ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"},
+		{"dentro de string", "SELECT '?' FROM t WHERE a = ?", "SELECT '?' FROM t WHERE a = $1"},
+		{"aspas escapadas", "SELECT 'it''s ?' WHERE a = ?", "SELECT 'it''s ?' WHERE a = $1"},
+		{"identificador entre aspas", `SELECT "a?b" FROM t WHERE c = ?`, `SELECT "a?b" FROM t WHERE c = $1`},
+		{"comentário", "SELECT a -- quem? \nFROM t WHERE b = ?", "SELECT a -- quem? \nFROM t WHERE b = $1"},
+		{"comentário no fim", "SELECT ? -- e agora?", "SELECT $1 -- e agora?"},
+		{"string sem fim", "SELECT ? WHERE a = 'x?", "SELECT $1 WHERE a = 'x?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebind(tt.in); got != tt.want {
+				t.Errorf("rebind(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
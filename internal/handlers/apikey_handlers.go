@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+	"time"
+)
+
+// HandleAPIKeys gerencia as chaves de API do usuário logado:
+// GET lista, POST cria (o segredo só é devolvido nesta resposta) e DELETE ?id= revoga
+func HandleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listAPIKeys(w, r)
+	case http.MethodPost:
+		createAPIKey(w, r)
+	case http.MethodDelete:
+		revokeAPIKey(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := store.APIKeys.List(currentUserID(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(keys)
+}
+
+func createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "Nome obrigatório", http.StatusBadRequest)
+		return
+	}
+	for _, sc := range req.Scopes {
+		if !validAPIScope(sc) {
+			http.Error(w, "Escopo inválido: "+sc, http.StatusBadRequest)
+			return
+		}
+	}
+
+	key, prefix, hash, err := services.GenerateAPIKey()
+	if err != nil {
+		http.Error(w, "Erro ao gerar chave", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	id, err := store.APIKeys.Create(models.APIKey{
+		UserID:    currentUserID(r),
+		Name:      req.Name,
+		Prefix:    prefix,
+		Scopes:    req.Scopes,
+		CreatedAt: now,
+	}, hash)
+	if err != nil {
+		http.Error(w, "Erro ao salvar chave", http.StatusInternalServerError)
+		return
+	}
+
+	scopes := req.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         id,
+		"name":       req.Name,
+		"prefix":     prefix,
+		"scopes":     scopes,
+		"created_at": now,
+		"key":        key,
+	})
+}
+
+func revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "ID obrigatório", http.StatusBadRequest)
+		return
+	}
+
+	err := store.APIKeys.Revoke(id, currentUserID(r))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Chave não encontrada", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao revogar chave", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func validAPIScope(scope string) bool {
+	for _, s := range models.APIScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -2,28 +2,33 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
-	"tempmail/internal/database"
+	"tempmail/internal/config"
 	"tempmail/internal/models"
 	"tempmail/internal/services"
+	"tempmail/internal/store"
 	"time"
 )
 
 // HandleStatus verifica o estado atual do sistema
 func HandleStatus(w http.ResponseWriter, r *http.Request) {
-	setupDone := database.IsSetupDone()
-	cfg, _ := database.GetConfig()
-	configDone := cfg.CFToken != ""
+	setupDone := isSetupDone()
+	_, err := store.Domains.Default()
+	configDone := err == nil
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"setup_done":  setupDone,
 		"config_done": configDone,
+		"default_ttl": int64(config.GetDefaultTTL().Seconds()),
+		"max_ttl":     int64(config.GetMaxTTL().Seconds()),
 	})
 }
 
 // HandleSetup cria o primeiro usuário administrador
 func HandleSetup(w http.ResponseWriter, r *http.Request) {
-	if database.IsSetupDone() {
+	if isSetupDone() {
 		http.Error(w, "Setup já realizado", http.StatusForbidden)
 		return
 	}
@@ -36,10 +41,12 @@ func HandleSetup(w http.ResponseWriter, r *http.Request) {
 
 	hashedPassword, _ := services.HashPassword(req.Password)
 
-	_, err := database.DB.Exec(
-		"INSERT INTO users (username, password, full_name, created_at) VALUES (?, ?, ?, ?)",
-		req.Username, hashedPassword, req.FullName, time.Now(),
-	)
+	_, err := store.Users.Create(models.User{
+		Username: req.Username,
+		Password: hashedPassword,
+		FullName: req.FullName,
+		Role:     models.RoleAdmin,
+	})
 
 	if err != nil {
 		http.Error(w, "Erro ao criar usuário", http.StatusInternalServerError)
@@ -57,20 +64,129 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var hashedPassword string
-	err := database.DB.QueryRow("SELECT password FROM users WHERE username = ?", req.Username).Scan(&hashedPassword)
+	ip, account := loginKeys(r, req.Username)
+	if loginLocked(w, ip, account) {
+		return
+	}
+
+	user, err := store.Users.Get(req.Username)
 
-	if err != nil || !services.CheckPasswordHash(req.Password, hashedPassword) {
+	if err != nil || !services.CheckPasswordHash(req.Password, user.Password) {
+		loginFailed(ip, account)
 		http.Error(w, "Usuário ou senha inválidos", http.StatusUnauthorized)
 		return
 	}
 
-	token, _ := services.GenerateToken(req.Username)
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	if user.Disabled {
+		http.Error(w, "Usuário desativado", http.StatusForbidden)
+		return
+	}
+
+	// Com 2FA ativo a senha só libera o desafio da segunda etapa (/api/login/2fa)
+	if totpEnabled(user.ID) {
+		mfaChallenge(w, user)
+		return
+	}
+
+	loginSucceeded(ip, account)
+	issueSession(w, r, user)
+}
+
+// issueSession cria uma sessão para o usuário e responde com o par de tokens
+func issueSession(w http.ResponseWriter, r *http.Request, user models.User) {
+	refresh, refreshHash, err := services.GenerateRefreshToken()
+	if err != nil {
+		http.Error(w, "Erro ao criar sessão", http.StatusInternalServerError)
+		return
+	}
+	sessionID, err := store.Sessions.Create(user.ID, refreshHash, r.UserAgent(), clientIP(r), time.Now().Add(config.GetRefreshTTL()))
+	if err != nil {
+		http.Error(w, "Erro ao criar sessão", http.StatusInternalServerError)
+		return
+	}
+
+	token, _ := services.GenerateToken(user.Username, sessionID)
+	json.NewEncoder(w).Encode(models.TokenResponse{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(services.AccessTokenTTL.Seconds()),
+	})
+}
+
+// rotateSession gera um novo par de tokens para uma sessão existente. Se o refresh token
+// apresentado (oldHash) já tiver sido trocado por outra requisição, trata como reuso.
+func rotateSession(w http.ResponseWriter, r *http.Request, username, sessionID, oldHash string) {
+	refresh, refreshHash, err := services.GenerateRefreshToken()
+	if err != nil {
+		http.Error(w, "Erro ao renovar sessão", http.StatusInternalServerError)
+		return
+	}
+	err = store.Sessions.Rotate(sessionID, oldHash, refreshHash, clientIP(r), time.Now().Add(config.GetRefreshTTL()))
+	if errors.Is(err, store.ErrRefreshReused) {
+		store.Sessions.Revoke(sessionID)
+		http.Error(w, "Refresh token reutilizado: sessão encerrada", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao renovar sessão", http.StatusInternalServerError)
+		return
+	}
+
+	token, _ := services.GenerateToken(username, sessionID)
+	json.NewEncoder(w).Encode(models.TokenResponse{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(services.AccessTokenTTL.Seconds()),
+	})
+}
+
+// HandleRefresh troca um refresh token válido por um novo par de tokens (rotação).
+// Reapresentar um refresh token já usado revoga a sessão inteira.
+func HandleRefresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+
+	hash := services.HashRefreshToken(req.RefreshToken)
+	session, reused, err := store.Sessions.FindByRefresh(hash)
+	if err != nil {
+		http.Error(w, "Sessão inválida", http.StatusUnauthorized)
+		return
+	}
+	if reused {
+		if err := store.Sessions.Revoke(session.ID); err != nil {
+			log.Printf("Erro ao revogar sessão %s após reuso de refresh token: %v", session.ID, err)
+			http.Error(w, "Erro ao encerrar sessão", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "Refresh token reutilizado: sessão encerrada", http.StatusUnauthorized)
+		return
+	}
+	if session.Revoked || time.Now().After(session.ExpiresAt) {
+		http.Error(w, "Sessão expirada", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := store.Users.GetByID(session.UserID)
+	if err != nil || user.Disabled {
+		http.Error(w, "Usuário desativado ou inexistente", http.StatusUnauthorized)
+		return
+	}
+	rotateSession(w, r, user.Username, session.ID, hash)
 }
 
-// HandleLogout apenas confirma o encerramento da sessão
+// HandleLogout encerra a sessão atual no servidor
 func HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if sid := currentSessionID(r); sid != "" {
+		if err := store.Sessions.Revoke(sid); err != nil && !errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Erro ao encerrar sessão", http.StatusInternalServerError)
+			return
+		}
+	}
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Logout realizado com sucesso"})
 }
@@ -91,28 +207,42 @@ func HandleChangePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verifica a senha atual
-	var hashedPassword string
-	err := database.DB.QueryRow("SELECT password FROM users WHERE username = ?", username).Scan(&hashedPassword)
+	user, err := store.Users.Get(username)
 	if err != nil {
 		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
 		return
 	}
 
-	if !services.CheckPasswordHash(req.CurrentPassword, hashedPassword) {
+	// Uma sessão roubada não pode ser usada para adivinhar a senha
+	ip, account := loginKeys(r, username)
+	if loginLocked(w, ip, account) {
+		return
+	}
+	if !services.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		loginFailed(ip, account)
 		http.Error(w, "Senha atual incorreta", http.StatusUnauthorized)
 		return
 	}
 
 	// Criptografa e atualiza a nova senha
 	newHashedPassword, _ := services.HashPassword(req.NewPassword)
-	_, err = database.DB.Exec("UPDATE users SET password = ? WHERE username = ?", newHashedPassword, username)
-	if err != nil {
+	if err := store.Users.SetPassword(user.ID, newHashedPassword); err != nil {
 		http.Error(w, "Erro ao atualizar senha", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Senha atualizada com sucesso"})
+	// Os tokens emitidos antes da troca deixam de valer: as outras sessões são encerradas
+	// e a atual recebe um novo par de tokens
+	sessionID := currentSessionID(r)
+	if err := store.Sessions.RevokeUser(user.ID, sessionID); err != nil {
+		http.Error(w, "Senha alterada, mas houve erro ao encerrar as outras sessões", http.StatusInternalServerError)
+		return
+	}
+	if sessionID == "" {
+		json.NewEncoder(w).Encode(map[string]string{"message": "Senha alterada com sucesso"})
+		return
+	}
+	rotateSession(w, r, username, sessionID, "")
 }
 
 // HandleTestCloudflare valida se as credenciais funcionam
@@ -123,11 +253,11 @@ func HandleTestCloudflare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := services.CfGetAccountID(cfg)
+	_, err := services.CF.GetAccountID(cfg)
 	if err != nil {
 		http.Error(w, "Falha na conexão: "+err.Error(), http.StatusUnauthorized)
 		return
 	}
 
 	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Conexão OK!"})
-}
\ No newline at end of file
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"tempmail/internal/models"
+	"tempmail/internal/store"
+	"tempmail/internal/testutil"
+	"testing"
+)
+
+func login(t *testing.T, username, password string) models.TokenResponse {
+	t.Helper()
+	w := call(HandleLogin, http.MethodPost, "/api/login", "", models.LoginRequest{Username: username, Password: password})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body)
+	}
+	var tok models.TokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&tok); err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func refresh(token string) *httptest.ResponseRecorder {
+	return call(HandleRefresh, http.MethodPost, "/api/refresh", "", map[string]string{"refresh_token": token})
+}
+
+// whoami é uma rota protegida mínima: responde com o usuário que o middleware autenticou
+var whoami = AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Context().Value("username").(string)))
+})
+
+func TestSessionLifecycle(t *testing.T) {
+	testutil.DB(t)
+	testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+
+	tok := login(t, "ana", "segredo123")
+	if w := call(whoami, http.MethodGet, "/api/me", tok.Token, nil); w.Code != http.StatusOK || w.Body.String() != "ana" {
+		t.Fatalf("token do login: status %d: %s", w.Code, w.Body)
+	}
+
+	// A rotação devolve um novo refresh token; o antigo passa a ser "usado"
+	w := refresh(tok.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d: %s", w.Code, w.Body)
+	}
+	var rotated models.TokenResponse
+	json.NewDecoder(w.Body).Decode(&rotated)
+	if rotated.RefreshToken == "" || rotated.RefreshToken == tok.RefreshToken {
+		t.Fatalf("refresh não rotacionou o token: %+v", rotated)
+	}
+
+	// Reapresentar o refresh token antigo encerra a sessão inteira
+	if w := refresh(tok.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reuso do refresh: status %d, esperado 401", w.Code)
+	}
+	if w := call(whoami, http.MethodGet, "/api/me", rotated.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("sessão deveria estar revogada após o reuso: status %d", w.Code)
+	}
+	if w := refresh(rotated.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh de sessão revogada: status %d, esperado 401", w.Code)
+	}
+
+	// Logout revoga a sessão no servidor
+	tok = login(t, "ana", "segredo123")
+	if w := call(AuthMiddleware(HandleLogout), http.MethodPost, "/api/logout", tok.Token, nil); w.Code != http.StatusOK {
+		t.Fatalf("logout: status %d: %s", w.Code, w.Body)
+	}
+	if w := call(whoami, http.MethodGet, "/api/me", tok.Token, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("token após logout: status %d, esperado 401", w.Code)
+	}
+}
+
+func TestRefreshReuseOfOlderToken(t *testing.T) {
+	testutil.DB(t)
+	testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+
+	// Um token de duas rotações atrás também é reuso, não apenas "desconhecido"
+	tokens := []models.TokenResponse{login(t, "ana", "segredo123")}
+	for i := 0; i < 2; i++ {
+		w := refresh(tokens[i].RefreshToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("refresh %d: status %d: %s", i+1, w.Code, w.Body)
+		}
+		var next models.TokenResponse
+		json.NewDecoder(w.Body).Decode(&next)
+		tokens = append(tokens, next)
+	}
+
+	if w := refresh(tokens[0].RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reuso do primeiro refresh: status %d, esperado 401", w.Code)
+	}
+	if w := refresh(tokens[2].RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("sessão deveria estar revogada após o reuso: status %d", w.Code)
+	}
+}
+
+func TestRefreshConcurrent(t *testing.T) {
+	testutil.DB(t)
+	ana := testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+	tok := login(t, "ana", "segredo123")
+
+	// Duas requisições com o mesmo refresh token: só uma rotação pode vencer, e a outra
+	// é tratada como reuso, encerrando a sessão
+	const n = 8
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- refresh(tok.RefreshToken).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	ok := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			ok++
+		case http.StatusUnauthorized:
+		default:
+			t.Errorf("status inesperado %d", code)
+		}
+	}
+	if ok > 1 {
+		t.Fatalf("%d rotações aceitas com o mesmo refresh token", ok)
+	}
+	if list, err := store.Sessions.List(ana.ID); err != nil || len(list) != 0 {
+		t.Fatalf("sessão não revogada após o uso concorrente: %+v, %v", list, err)
+	}
+}
+
+func TestRefreshUnknownToken(t *testing.T) {
+	testutil.DB(t)
+	testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+
+	if w := refresh("inexistente"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, esperado 401", w.Code)
+	}
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	testutil.DB(t)
+	ana := testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+	tok := login(t, "ana", "segredo123").Token
+	keys := AuthMiddleware(HandleAPIKeys)
+
+	w := call(keys, http.MethodPost, "/api/keys", tok, models.CreateAPIKeyRequest{Name: "script", Scopes: []string{models.ScopeAliasRead}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("criar chave: status %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID  int64  `json:"id"`
+		Key string `json:"key"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+
+	if w := call(whoami, http.MethodGet, "/api/me", created.Key, nil); w.Code != http.StatusOK || w.Body.String() != "ana" {
+		t.Fatalf("chave recém-criada: status %d: %s", w.Code, w.Body)
+	}
+	list, err := store.APIKeys.List(ana.ID)
+	if err != nil || len(list) != 1 || list[0].LastUsedAt == nil {
+		t.Fatalf("uso da chave não registrado: %+v, %v", list, err)
+	}
+
+	if w := call(keys, http.MethodDelete, "/api/keys?id=999", tok, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("revogar chave inexistente: status %d, esperado 404", w.Code)
+	}
+	if w := call(keys, http.MethodDelete, "/api/keys?id="+strconv.FormatInt(created.ID, 10), tok, nil); w.Code != http.StatusOK {
+		t.Fatalf("revogar chave: status %d: %s", w.Code, w.Body)
+	}
+	if w := call(whoami, http.MethodGet, "/api/me", created.Key, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("chave revogada: status %d, esperado 401", w.Code)
+	}
+}
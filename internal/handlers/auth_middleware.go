@@ -2,16 +2,18 @@ package handlers
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strings"
-	"tempmail/internal/database"
+	"tempmail/internal/models"
 	"tempmail/internal/services"
+	"tempmail/internal/store"
 )
 
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// BLOQUEIO TOTAL: Se não houver usuários no DB, as APIs protegidas não funcionam.
-		if !database.IsSetupDone() {
+		if !isSetupDone() {
 			http.Error(w, "Setup pendente. Crie um usuário primeiro.", http.StatusPreconditionFailed)
 			return
 		}
@@ -23,13 +25,109 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		username, err := services.ValidateToken(tokenString)
-		if err != nil {
-			http.Error(w, "Token inválido ou expirado", http.StatusUnauthorized)
-			return
+
+		var user models.User
+		var scopes []string // nil = sessão do painel, com todos os escopos
+		var sessionID string
+		if services.IsAPIKey(tokenString) {
+			key, err := store.APIKeys.GetByHash(services.HashAPIKey(tokenString))
+			if err != nil {
+				http.Error(w, "Chave de API inválida ou revogada", http.StatusUnauthorized)
+				return
+			}
+			user, err = store.Users.GetByID(key.UserID)
+			if err != nil || user.Disabled {
+				http.Error(w, "Usuário desativado ou inexistente", http.StatusUnauthorized)
+				return
+			}
+			if err := store.APIKeys.Touch(key.ID); err != nil {
+				log.Printf("Erro ao registrar uso da chave de API %d: %v", key.ID, err)
+			}
+
+			scopes = key.Scopes
+			if len(scopes) == 0 {
+				scopes = models.APIScopes
+			}
+		} else {
+			username, sid, err := services.ValidateToken(tokenString)
+			if err != nil {
+				http.Error(w, "Token inválido ou expirado", http.StatusUnauthorized)
+				return
+			}
+			user, err = store.Users.Get(username)
+			if err != nil || user.Disabled {
+				http.Error(w, "Usuário desativado ou inexistente", http.StatusUnauthorized)
+				return
+			}
+			sessionID = sid
+			if err := store.Sessions.Touch(sid); err != nil {
+				log.Printf("Erro ao registrar atividade da sessão %s: %v", sid, err)
+			}
 		}
 
-		ctx := context.WithValue(r.Context(), "username", username)
+		ctx := context.WithValue(r.Context(), "username", user.Username)
+		ctx = context.WithValue(ctx, "user_id", user.ID)
+		ctx = context.WithValue(ctx, "role", user.Role)
+		ctx = context.WithValue(ctx, "scopes", scopes)
+		ctx = context.WithValue(ctx, "session_id", sessionID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
-}
\ No newline at end of file
+}
+
+// RequireScope bloqueia chaves de API que não possuem o escopo exigido pela rota
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !hasScope(r, scope) {
+			http.Error(w, "Escopo insuficiente: "+scope, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+func hasScope(r *http.Request, scope string) bool {
+	scopes, _ := r.Context().Value("scopes").([]string)
+	if scopes == nil {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminOnly restringe a rota a administradores; deve ser usado dentro do AuthMiddleware
+func AdminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			http.Error(w, "Acesso restrito a administradores", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// currentUserID retorna o ID do usuário autenticado (definido pelo AuthMiddleware)
+func currentUserID(r *http.Request) int64 {
+	id, _ := r.Context().Value("user_id").(int64)
+	return id
+}
+
+// currentSessionID retorna a sessão do JWT usado na requisição (vazio para chaves de API)
+func currentSessionID(r *http.Request) string {
+	id, _ := r.Context().Value("session_id").(string)
+	return id
+}
+
+// isSetupDone indica se o primeiro usuário já foi criado
+func isSetupDone() bool {
+	count, err := store.Users.Count()
+	return err == nil && count > 0
+}
+
+func isAdmin(r *http.Request) bool {
+	role, _ := r.Context().Value("role").(string)
+	return role == models.RoleAdmin
+}
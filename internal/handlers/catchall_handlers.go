@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+)
+
+// HandleCatchAll lê (GET) ou altera (PUT/POST) a regra pega-tudo da zona do domínio
+// (?domain=, padrão se ausente). Toda alteração vai para o registro de auditoria.
+func HandleCatchAll(w http.ResponseWriter, r *http.Request) {
+	dom, err := requestDomain(r)
+	if err != nil {
+		http.Error(w, "Domínio não encontrado", http.StatusNotFound)
+		return
+	}
+
+	current, err := services.CF.GetCatchAll(dom.Config())
+	if err != nil {
+		cloudflareError(w, "Erro Cloudflare: ", err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(current)
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.CatchAll
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+	switch req.Action {
+	case models.CatchAllForward:
+		if req.Destination == "" {
+			http.Error(w, "Destino obrigatório para encaminhar", http.StatusBadRequest)
+			return
+		}
+	case models.CatchAllDrop, models.CatchAllDisabled:
+		req.Destination = ""
+	default:
+		http.Error(w, "Ação inválida: use forward, drop ou disabled", http.StatusBadRequest)
+		return
+	}
+	req.Domain = dom.Domain
+
+	if err := services.CF.SetCatchAll(dom.Config(), req); err != nil {
+		cloudflareError(w, "Erro Cloudflare: ", err)
+		return
+	}
+
+	audit(r, "catch_all.update", dom.Domain, describeCatchAll(current)+" → "+describeCatchAll(req))
+	json.NewEncoder(w).Encode(req)
+}
+
+func describeCatchAll(c models.CatchAll) string {
+	if c.Action == models.CatchAllForward {
+		return "forward:" + c.Destination
+	}
+	return c.Action
+}
+
+// HandleAudit lista o registro de auditoria (?limit=, padrão 100)
+func HandleAudit(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	list, err := store.Audit.List(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// audit registra uma alteração feita pelo usuário da requisição. Falhas só vão para o log:
+// a alteração já foi aplicada e não deve ser desfeita por isso.
+func audit(r *http.Request, action, target, details string) {
+	username, _ := r.Context().Value("username").(string)
+	err := store.Audit.Record(models.AuditEntry{
+		UserID:   currentUserID(r),
+		Username: username,
+		Action:   action,
+		Target:   target,
+		Details:  details,
+		IP:       clientIP(r),
+	})
+	if err != nil {
+		log.Printf("Erro ao registrar auditoria (%s %s): %v", action, target, err)
+	}
+}
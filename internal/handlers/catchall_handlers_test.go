@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"tempmail/internal/models"
+	"tempmail/internal/store"
+	"tempmail/internal/testutil"
+	"testing"
+)
+
+func getCatchAll(t *testing.T, target string) models.CatchAll {
+	t.Helper()
+	w := call(HandleCatchAll, http.MethodGet, target, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d: %s", target, w.Code, w.Body)
+	}
+	var rule models.CatchAll
+	json.NewDecoder(w.Body).Decode(&rule)
+	return rule
+}
+
+func TestCatchAll(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	testutil.Domain(t, "exemplo.test")
+	other := testutil.Domain(t, "outro.test")
+	testutil.Destination(t, cf, other.Config(), "ana@dest.test")
+
+	if rule := getCatchAll(t, "/api/catch-all"); rule.Action != models.CatchAllDisabled {
+		t.Fatalf("catch-all inicial: %+v", rule)
+	}
+
+	for _, tc := range []struct {
+		target string
+		body   models.CatchAll
+		code   int
+	}{
+		{"/api/catch-all", models.CatchAll{Action: "bounce"}, http.StatusBadRequest},
+		{"/api/catch-all", models.CatchAll{Action: models.CatchAllForward}, http.StatusBadRequest},
+		// O destino só foi confirmado na conta de outro.test
+		{"/api/catch-all", models.CatchAll{Action: models.CatchAllForward, Destination: "ana@dest.test"}, http.StatusBadRequest},
+		{"/api/catch-all?domain=nenhum.test", models.CatchAll{Action: models.CatchAllDrop}, http.StatusNotFound},
+	} {
+		if w := call(HandleCatchAll, http.MethodPut, tc.target, "", tc.body); w.Code != tc.code {
+			t.Errorf("PUT %s %+v: status %d, esperado %d", tc.target, tc.body, w.Code, tc.code)
+		}
+	}
+
+	forward := models.CatchAll{Action: models.CatchAllForward, Destination: "ana@dest.test"}
+	if w := call(HandleCatchAll, http.MethodPut, "/api/catch-all?domain=outro.test", "", forward); w.Code != http.StatusOK {
+		t.Fatalf("encaminhar: status %d: %s", w.Code, w.Body)
+	}
+	if rule := getCatchAll(t, "/api/catch-all?domain=outro.test"); rule.Action != forward.Action || rule.Destination != forward.Destination {
+		t.Fatalf("catch-all de outro.test: %+v", rule)
+	}
+	if rule := getCatchAll(t, "/api/catch-all"); rule.Action != models.CatchAllDisabled {
+		t.Fatalf("catch-all do domínio padrão alterado: %+v", rule)
+	}
+
+	// Descartar ignora o destino enviado
+	drop := models.CatchAll{Action: models.CatchAllDrop, Destination: "ana@dest.test"}
+	if w := call(HandleCatchAll, http.MethodPut, "/api/catch-all?domain=outro.test", "", drop); w.Code != http.StatusOK {
+		t.Fatalf("descartar: status %d: %s", w.Code, w.Body)
+	}
+	if rule := getCatchAll(t, "/api/catch-all?domain=outro.test"); rule.Action != models.CatchAllDrop || rule.Destination != "" {
+		t.Fatalf("catch-all após descartar: %+v", rule)
+	}
+
+	// Só as alterações aplicadas vão para a auditoria, da mais recente para a mais antiga
+	entries, err := store.Audit.List(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"forward:ana@dest.test → drop", "disabled → forward:ana@dest.test"}
+	if len(entries) != len(want) {
+		t.Fatalf("auditoria: %+v", entries)
+	}
+	for i, e := range entries {
+		if e.Action != "catch_all.update" || e.Target != "outro.test" || e.Details != want[i] {
+			t.Errorf("auditoria[%d]: %+v, esperado %q", i, e, want[i])
+		}
+	}
+}
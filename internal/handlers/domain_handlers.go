@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+)
+
+// HandleDomains gerencia os domínios disponíveis para os aliases:
+// GET lista (qualquer usuário, sem os tokens); POST cadastra, PUT ?id= altera
+// credenciais ou torna padrão e DELETE ?id= remove (somente admin)
+func HandleDomains(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		listDomains(w)
+		return
+	}
+	if !isAdmin(r) || !hasScope(r, models.ScopeAccount) {
+		http.Error(w, "Acesso restrito a administradores", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		createDomain(w, r)
+	case http.MethodPut:
+		updateDomain(w, r)
+	case http.MethodDelete:
+		deleteDomain(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listDomains(w http.ResponseWriter) {
+	domains, err := store.Domains.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range domains {
+		domains[i].CFToken = ""
+	}
+	json.NewEncoder(w).Encode(domains)
+}
+
+func createDomain(w http.ResponseWriter, r *http.Request) {
+	var d models.Domain
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+	d.ID = 0
+	d.Domain = normalizeDomain(d.Domain)
+	d.Worker = strings.TrimSpace(d.Worker)
+	if d.Domain == "" || d.ZoneID == "" || d.CFToken == "" {
+		http.Error(w, "Domínio, Zone ID e token são obrigatórios", http.StatusBadRequest)
+		return
+	}
+
+	// As credenciais são validadas antes de gravar, e a conta da zona já fica registrada
+	accountID, err := services.CF.GetAccountID(d.Config())
+	if err != nil {
+		http.Error(w, "Falha na conexão: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	d.AccountID = accountID
+
+	id, err := store.Domains.Save(d)
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "Domínio já cadastrado", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao salvar domínio", http.StatusInternalServerError)
+		return
+	}
+	if d.IsDefault {
+		if err := store.Domains.SetDefault(id); err != nil {
+			http.Error(w, "Domínio salvo, mas não foi possível torná-lo padrão", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "domain": d.Domain})
+}
+
+func updateDomain(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "ID obrigatório", http.StatusBadRequest)
+		return
+	}
+	current, err := store.Domains.Get(id)
+	if err != nil {
+		http.Error(w, "Domínio não encontrado", http.StatusNotFound)
+		return
+	}
+
+	// worker ausente mantém o atual; "" volta a encaminhar direto
+	var req struct {
+		models.Domain
+		Worker *string `json:"worker"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+	// Os aliases existentes dependem do nome: trocar de domínio é cadastrar outro
+	if name := normalizeDomain(req.Domain.Domain); name != "" && name != current.Domain {
+		http.Error(w, "O nome do domínio não pode ser alterado; cadastre um novo", http.StatusBadRequest)
+		return
+	}
+
+	updated := current
+	if req.ZoneID != "" {
+		updated.ZoneID = req.ZoneID
+	}
+	if req.CFToken != "" && req.CFToken != strings.Repeat("*", len(req.CFToken)) {
+		updated.CFToken = req.CFToken
+	}
+	if updated.ZoneID != current.ZoneID || updated.CFToken != current.CFToken {
+		accountID, err := services.CF.GetAccountID(updated.Config())
+		if err != nil {
+			http.Error(w, "Falha na conexão: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		updated.AccountID = accountID
+	}
+	// O Worker vale para as regras criadas ou alteradas a partir de agora
+	if req.Worker != nil {
+		updated.Worker = strings.TrimSpace(*req.Worker)
+	}
+	if updated != current {
+		if _, err := store.Domains.Save(updated); err != nil {
+			http.Error(w, "Erro ao salvar domínio", http.StatusInternalServerError)
+			return
+		}
+		if updated.Worker != current.Worker {
+			audit(r, "domain.worker", updated.Domain, describeWorker(current.Worker)+" → "+describeWorker(updated.Worker))
+		}
+	}
+
+	if req.IsDefault && !current.IsDefault {
+		if err := store.Domains.SetDefault(id); err != nil {
+			http.Error(w, "Erro ao salvar domínio", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func describeWorker(worker string) string {
+	if worker == "" {
+		return "forward"
+	}
+	return "worker:" + worker
+}
+
+func deleteDomain(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "ID obrigatório", http.StatusBadRequest)
+		return
+	}
+
+	err = store.Domains.Delete(id)
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "O domínio ainda tem aliases ativos", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Domínio não encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao remover domínio", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// resolveDomain escolhe o domínio do novo alias: o do endereço pedido, o informado em
+// req.Domain ("random" sorteia entre os cadastrados) ou o padrão
+func resolveDomain(req models.CreateRequest) (models.Domain, error) {
+	name := normalizeDomain(req.Domain)
+	if req.Email != "" {
+		_, host, ok := strings.Cut(req.Email, "@")
+		if !ok || host == "" {
+			return models.Domain{}, errors.New("Endereço inválido")
+		}
+		name = normalizeDomain(host)
+	}
+
+	switch name {
+	case "":
+		d, err := store.Domains.Default()
+		if errors.Is(err, store.ErrNotFound) {
+			return d, errors.New("Configure o sistema primeiro!")
+		}
+		return d, err
+	case "random":
+		domains, err := store.Domains.List()
+		if err != nil {
+			return models.Domain{}, err
+		}
+		if len(domains) == 0 {
+			return models.Domain{}, errors.New("Configure o sistema primeiro!")
+		}
+		return domains[rand.IntN(len(domains))], nil
+	}
+
+	d, err := store.Domains.FindByName(name)
+	if errors.Is(err, store.ErrNotFound) {
+		return d, errors.New("Domínio não cadastrado: " + name)
+	}
+	return d, err
+}
+
+// requestDomain devolve o domínio pedido em ?domain= (nome) ou o padrão
+func requestDomain(r *http.Request) (models.Domain, error) {
+	if name := normalizeDomain(r.URL.Query().Get("domain")); name != "" && name != "random" {
+		return store.Domains.FindByName(name)
+	}
+	return store.Domains.Default()
+}
+
+// accountIDOf devolve a conta da zona, consultando a Cloudflare só quando ainda não está gravada
+func accountIDOf(d models.Domain) (string, error) {
+	if d.AccountID != "" {
+		return d.AccountID, nil
+	}
+	accountID, err := services.CF.GetAccountID(d.Config())
+	if err != nil {
+		return "", err
+	}
+	// Falhar ao gravar só faz a conta ser consultada de novo na próxima vez
+	if d.ID != 0 {
+		if err := store.Domains.SetAccountID(d.ID, accountID); err != nil {
+			log.Printf("Erro ao gravar Account ID do domínio %s: %v", d.Domain, err)
+		}
+	}
+	return accountID, nil
+}
+
+func normalizeDomain(s string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "@"))
+}
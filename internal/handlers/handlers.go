@@ -2,44 +2,34 @@ package handlers
 
 import (
 	"crypto/rand"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"math/big"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
-	"tempmail/internal/database"
+	"tempmail/internal/config"
 	"tempmail/internal/models"
+	"tempmail/internal/scheduler"
 	"tempmail/internal/services"
+	"tempmail/internal/store"
 	"time"
 )
 
 var (
 	adjetivos    = []string{"cansado", "calvo", "radioativo", "humilde", "furioso", "suspeito", "duvidoso", "crocante", "quase-rico", "lendario", "misterioso", "caotico", "triste", "iludido", "blindado", "agiota", "nutella", "raiz", "toxico", "quase-senior"}
 	substantivos = []string{"boleto", "estagiario", "capivara", "gambiarra", "tijolo", "hacker", "pastel", "uno-com-escada", "coach", "cafe", "servidor", "bug", "golpe", "primo", "vaxco", "lider-tecnico", "git-blame", "deploy", "backup", "junior"}
-	coresTags    = []string{"#ef4444", "#f97316", "#f59e0b", "#84cc16", "#10b981", "#06b6d4", "#3b82f6", "#6366f1", "#8b5cf6", "#d946ef", "#f43f5e"}
-	activeTimers = make(map[string]*time.Timer)
-	timerMu      sync.Mutex
 )
 
 func HandleTags(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query("SELECT id, name, color FROM tags ORDER BY name")
+	tags, err := store.Tags.List(currentUserID(r))
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	defer rows.Close()
-
-	var tags []models.Tag
-	for rows.Next() {
-		var t models.Tag
-		rows.Scan(&t.ID, &t.Name, &t.Color)
-		tags = append(tags, t)
-	}
-	if tags == nil {
-		tags = []models.Tag{}
-	}
 	json.NewEncoder(w).Encode(tags)
 }
 
@@ -55,47 +45,234 @@ func HandlePin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg, err := database.GetConfig()
+	e, err := store.Emails.Get(req.ID)
+	if errors.Is(err, store.ErrNotFound) || err == nil && e.OwnerID != currentUserID(r) {
+		http.Error(w, "Email não encontrado", 404)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Erro config", 500)
+		http.Error(w, "Erro ao atualizar DB", 500)
+		return
+	}
+	// Queimado não expira: fixar ou desafixar só faz sentido depois de reativar
+	if e.State == models.StateBurned {
+		http.Error(w, "Alias queimado: reative-o antes de fixar", http.StatusConflict)
 		return
 	}
 
-	_, err = database.DB.Exec("UPDATE emails SET pinned = ? WHERE id = ?", req.Pinned, req.ID)
+	err = store.Emails.SetPinned(req.ID, e.OwnerID, req.Pinned)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Email não encontrado", 404)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Erro ao atualizar DB", 500)
 		return
 	}
 
-	timerMu.Lock()
-	defer timerMu.Unlock()
-
 	if req.Pinned {
-		if t, ok := activeTimers[req.ID]; ok {
-			t.Stop()
-			delete(activeTimers, req.ID)
+		scheduler.Cancel(req.ID)
+	} else if !scheduler.IsScheduled(req.ID) {
+		// Ao desafixar, o alias volta a contar a própria validade a partir de agora
+		now := time.Now()
+		if err := store.Emails.SetCreatedAt(req.ID, now); err != nil {
+			http.Error(w, "Erro ao atualizar DB", 500)
+			return
 		}
-	} else {
-		if _, ok := activeTimers[req.ID]; !ok {
-			activeTimers[req.ID] = time.AfterFunc(5*time.Minute, func() {
-				services.CfDeleteRule(cfg, req.ID)
-				database.DB.Exec("UPDATE emails SET active = 0 WHERE id = ?", req.ID)
-				timerMu.Lock()
-				delete(activeTimers, req.ID)
-				timerMu.Unlock()
-			})
-			database.DB.Exec("UPDATE emails SET created_at = ? WHERE id = ?", time.Now(), req.ID)
+		scheduler.Schedule(req.ID, now.Add(scheduler.TTLOf(e.TTL)))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleBurn queima um alias ativo: a regra continua na Cloudflare, mas com a ação drop,
+// e o endereço fica reservado. Com burned=false o alias volta a encaminhar e a contar a validade.
+func HandleBurn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var req models.BurnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", 400)
+		return
+	}
+
+	e, err := store.Emails.Get(req.ID)
+	if err != nil || e.OwnerID != currentUserID(r) {
+		http.Error(w, "Email não encontrado", 404)
+		return
+	}
+	dom, err := store.Domains.Get(e.DomainID)
+	if err != nil {
+		http.Error(w, "Erro config", 500)
+		return
+	}
+
+	if req.Burned {
+		if e.State != models.StateActive {
+			http.Error(w, "Só aliases ativos podem ser queimados", http.StatusConflict)
+			return
+		}
+		if err := services.CF.UpdateRule(dom.Config(), e.ID, e.Email, models.ActionDrop, nil); err != nil {
+			cloudflareError(w, "Erro Cloudflare: ", err)
+			return
+		}
+		if err := store.Emails.SetState(e.ID, models.StateBurned); err != nil {
+			http.Error(w, "Erro ao atualizar DB", 500)
+			return
 		}
+		scheduler.Cancel(e.ID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if e.State != models.StateBurned {
+		http.Error(w, "O alias não está queimado", http.StatusConflict)
+		return
+	}
+	if err := services.CF.UpdateRule(dom.Config(), e.ID, e.Email, models.ActionForward, e.Destinations); err != nil {
+		cloudflareError(w, "Erro Cloudflare: ", err)
+		return
+	}
+	now := time.Now()
+	if err := store.Emails.SetState(e.ID, models.StateActive); err != nil {
+		http.Error(w, "Erro ao atualizar DB", 500)
+		return
+	}
+	if err := store.Emails.SetCreatedAt(e.ID, now); err != nil {
+		http.Error(w, "Erro ao atualizar DB", 500)
+		return
+	}
+	if !e.Pinned {
+		scheduler.Schedule(e.ID, now.Add(scheduler.TTLOf(e.TTL)))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleUpdate troca os destinos, as tags, as listas de remetentes e/ou o uso único de um
+// alias sem recriá-lo: a regra na Cloudflare é alterada no lugar e o ID continua o mesmo.
+// Trocas de destino, de listas e do uso único vão para a auditoria.
+func HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var req models.UpdateAliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", 400)
+		return
+	}
+
+	e, err := store.Emails.Get(req.ID)
+	if err != nil || e.OwnerID != currentUserID(r) {
+		http.Error(w, "Email não encontrado", 404)
+		return
+	}
+
+	var dests []string
+	if req.Destinations != nil || req.Destination != nil {
+		if req.Destinations != nil {
+			dests = destinationList("", *req.Destinations)
+		} else {
+			dests = destinationList(*req.Destination, nil)
+		}
+		if len(dests) == 0 {
+			http.Error(w, "Destino obrigatório", 400)
+			return
+		}
+	}
+	changed := dests != nil && strings.Join(dests, ",") != strings.Join(e.Destinations, ",")
+
+	var allow, block []string
+	if req.AllowSenders != nil {
+		if allow, err = senderPatterns(*req.AllowSenders); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+	}
+	if req.BlockSenders != nil {
+		if block, err = senderPatterns(*req.BlockSenders); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+	}
+	allowChanged := allow != nil && strings.Join(allow, ",") != strings.Join(e.AllowSenders, ",")
+	blockChanged := block != nil && strings.Join(block, ",") != strings.Join(e.BlockSenders, ",")
+	lockChanged := req.SenderLock != nil && *req.SenderLock != e.SenderLock
+
+	// Só a regra que está encaminhando precisa mudar agora: aliases queimados ou inativos
+	// usam os destinos gravados quando forem reativados ou recriados
+	var cfg models.Config
+	live := changed && e.State == models.StateActive
+	if live {
+		dom, err := store.Domains.Get(e.DomainID)
+		if err != nil {
+			http.Error(w, "Erro config", 500)
+			return
+		}
+		cfg = dom.Config()
+		if err := services.CF.UpdateRule(cfg, e.ID, e.Email, models.ActionForward, dests); err != nil {
+			cloudflareError(w, "Erro Cloudflare: ", err)
+			return
+		}
+	}
+
+	update := store.EmailUpdate{Tags: req.Tags}
+	if changed {
+		update.Destinations = &dests
+	}
+	if allowChanged {
+		update.AllowSenders = &allow
+	}
+	if blockChanged {
+		update.BlockSenders = &block
+	}
+	if lockChanged {
+		update.SenderLock = req.SenderLock
+	}
+	err = store.Emails.Update(e.ID, e.OwnerID, update)
+	if err != nil {
+		// A regra já encaminha para os novos destinos: volta para os antigos para não divergir do banco
+		if live {
+			if err := services.CF.UpdateRule(cfg, e.ID, e.Email, models.ActionForward, e.Destinations); err != nil {
+				log.Printf("Erro ao restaurar destino da regra %s na Cloudflare: %v", e.ID, err)
+			}
+		}
+		http.Error(w, "Erro ao atualizar DB", 500)
+		return
+	}
+
+	if changed {
+		audit(r, "alias.destination", e.Email, strings.Join(e.Destinations, ", ")+" → "+strings.Join(dests, ", "))
+	}
+	if allowChanged {
+		audit(r, "alias.senders", e.Email, "permitidos: "+describeSenders(allow))
+	}
+	if blockChanged {
+		audit(r, "alias.senders", e.Email, "bloqueados: "+describeSenders(block))
+	}
+	if lockChanged {
+		audit(r, "alias.sender_lock", e.Email, describeLock(*req.SenderLock))
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
+// HandleConfig lê e grava as credenciais do domínio padrão (os demais ficam em /api/domains)
 func HandleConfig(w http.ResponseWriter, r *http.Request) {
-	var currentCfg models.Config
-	row := database.DB.QueryRow("SELECT cf_token, zone_id, domain FROM config WHERE id = 1")
-	row.Scan(&currentCfg.CFToken, &currentCfg.ZoneID, &currentCfg.Domain)
+	current, err := store.Domains.Default()
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	currentCfg := current.Config()
 
 	if r.Method == http.MethodPost {
+		if !isAdmin(r) {
+			http.Error(w, "Acesso restrito a administradores", http.StatusForbidden)
+			return
+		}
 		var newCfg models.Config
 		if err := json.NewDecoder(r.Body).Decode(&newCfg); err != nil {
 			http.Error(w, err.Error(), 400)
@@ -107,12 +284,23 @@ func HandleConfig(w http.ResponseWriter, r *http.Request) {
 			finalToken = currentCfg.CFToken
 		}
 
-		_, err := database.DB.Exec(`
-			INSERT INTO config (id, cf_token, zone_id, domain) 
-			VALUES (1, ?, ?, ?) 
-			ON CONFLICT(id) DO UPDATE SET cf_token=excluded.cf_token, zone_id=excluded.zone_id, domain=excluded.domain
-		`, finalToken, newCfg.ZoneID, newCfg.Domain)
+		// Trocar o domínio aqui cadastra um novo padrão: o anterior continua atendendo seus aliases
+		d := current
+		name := normalizeDomain(newCfg.Domain)
+		if existing, err := store.Domains.FindByName(name); err == nil {
+			d = existing
+		} else if name != current.Domain {
+			d = models.Domain{}
+		}
+		if d.ZoneID != newCfg.ZoneID || d.CFToken != finalToken {
+			d.AccountID = ""
+		}
+		d.Domain, d.ZoneID, d.CFToken = name, newCfg.ZoneID, finalToken
 
+		id, err := store.Domains.Save(d)
+		if err == nil {
+			err = store.Domains.SetDefault(id)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -130,28 +318,39 @@ func HandleConfig(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(maskedCfg)
 }
 
+// HandleDestinations gerencia os destinos verificados da conta do domínio (?domain=, padrão se ausente).
+// A listagem vem do cache; ?refresh=1 busca de novo na Cloudflare.
 func HandleDestinations(w http.ResponseWriter, r *http.Request) {
-	cfg, err := database.GetConfig()
+	dom, err := requestDomain(r)
 	if err != nil {
 		http.Error(w, "Configure o sistema primeiro", 400)
 		return
 	}
-	accountID, err := services.CfGetAccountID(cfg)
+	cfg := dom.Config()
+	accountID, err := accountIDOf(dom)
 	if err != nil {
-		http.Error(w, "Erro Account ID: "+err.Error(), 500)
+		cloudflareError(w, "Erro Account ID: ", err)
 		return
 	}
 
 	if r.Method == http.MethodGet {
-		dests, err := services.CfGetVerifiedDestinations(cfg, accountID)
+		if r.URL.Query().Get("refresh") == "1" {
+			services.ForgetDestinations(accountID)
+		}
+		dests, err := services.CF.GetVerifiedDestinations(cfg, accountID)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			cloudflareError(w, "", err)
 			return
 		}
 		json.NewEncoder(w).Encode(dests)
 		return
 	}
 
+	if (r.Method == http.MethodPost || r.Method == http.MethodDelete) && (!isAdmin(r) || !hasScope(r, models.ScopeAccount)) {
+		http.Error(w, "Acesso restrito a administradores", http.StatusForbidden)
+		return
+	}
+
 	if r.Method == http.MethodPost {
 		var req struct {
 			Email string `json:"email"`
@@ -160,8 +359,8 @@ func HandleDestinations(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "JSON inválido", 400)
 			return
 		}
-		if err := services.CfCreateDestination(cfg, accountID, req.Email); err != nil {
-			http.Error(w, err.Error(), 500)
+		if err := services.CF.CreateDestination(cfg, accountID, req.Email); err != nil {
+			cloudflareError(w, "", err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -174,8 +373,8 @@ func HandleDestinations(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "ID obrigatório", 400)
 			return
 		}
-		if err := services.CfDeleteDestination(cfg, accountID, destID); err != nil {
-			http.Error(w, err.Error(), 500)
+		if err := services.CF.DeleteDestination(cfg, accountID, destID); err != nil {
+			cloudflareError(w, "", err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -191,37 +390,70 @@ func HandleCheck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var exists bool
-	var active bool
-	err := database.DB.QueryRow("SELECT 1, active FROM emails WHERE email = ?", email).Scan(&exists, &active)
-
-	if err == sql.ErrNoRows {
+	e, err := store.Emails.FindByAddress(email)
+	if errors.Is(err, store.ErrNotFound) {
 		json.NewEncoder(w).Encode(map[string]bool{"exists": false})
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]bool{"exists": true, "active": active})
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	// Endereços de outros usuários não podem ser recriados por quem consulta
+	owned := e.OwnerID == currentUserID(r)
+	json.NewEncoder(w).Encode(map[string]interface{}{"exists": true, "active": e.Active, "state": e.State, "owned": owned})
 }
 
 func HandleCreate(w http.ResponseWriter, r *http.Request) {
-	cfg, err := database.GetConfig()
+	var req models.CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", 400)
+		return
+	}
+	dests := destinationList(req.Destination, req.Destinations)
+	if len(dests) == 0 {
+		http.Error(w, "Destino obrigatório", 400)
+		return
+	}
+
+	dom, err := resolveDomain(req)
 	if err != nil {
-		http.Error(w, "Configure o sistema primeiro!", 400)
+		http.Error(w, err.Error(), 400)
 		return
 	}
+	cfg := dom.Config()
 
-	var req models.CreateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Destination == "" {
-		http.Error(w, "Destino obrigatório", 400)
+	ttl, err := resolveTTL(req)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
 		return
 	}
 
+	userID := currentUserID(r)
+
 	var alias string
+	senderLock := req.SenderLock
 	if req.Email != "" {
 		alias = req.Email
+		existing, err := store.Emails.FindByAddress(alias)
+		if err == nil && existing.OwnerID != userID {
+			http.Error(w, "Endereço em uso por outro usuário", http.StatusConflict)
+			return
+		}
+		if err == nil && existing.State == models.StateBurned {
+			http.Error(w, "Alias queimado: reative-o pelo histórico", http.StatusConflict)
+			return
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		// Recriar pelo histórico mantém o uso único (a trava do remetente continua gravada)
+		senderLock = senderLock || existing.SenderLock
 	} else {
 		for i := 0; i < 10; i++ {
 			candidato := fmt.Sprintf("%s@%s", gerarNomeEngracado(), cfg.Domain)
-			if !database.EmailExists(candidato) {
+			if exists, err := store.Emails.Exists(candidato); err == nil && !exists {
 				alias = candidato
 				break
 			}
@@ -233,132 +465,214 @@ func HandleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ruleID, err := services.CfCreateRule(cfg, alias, req.Destination)
+	ruleID, err := services.CF.CreateRule(cfg, alias, dests)
 	if err != nil {
-		http.Error(w, "Erro Cloudflare: "+err.Error(), 500)
+		cloudflareError(w, "Erro Cloudflare: ", err)
 		return
 	}
 
-	_, err = database.DB.Exec(`
-		INSERT INTO emails (id, email, destination, created_at, active, pinned) 
-		VALUES (?, ?, ?, ?, ?, 0)
-		ON CONFLICT(email) DO UPDATE SET 
-			id=excluded.id, 
-			destination=excluded.destination, 
-			created_at=excluded.created_at, 
-			active=excluded.active,
-			pinned=0
-	`, ruleID, alias, req.Destination, time.Now(), true)
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	err = store.Emails.Save(models.EmailEntry{
+		ID:           ruleID,
+		Email:        alias,
+		Destinations: dests,
+		CreatedAt:    now,
+		Active:       true,
+		ExpiresAt:    &expiresAt,
+		TTL:          int64(ttl.Seconds()),
+		OwnerID:      userID,
+		DomainID:     dom.ID,
+		SenderLock:   senderLock,
+	}, req.Tags)
+	if err != nil {
+		// Sem registro no banco a regra ficaria órfã: desfaz na Cloudflare
+		if err := services.CF.DeleteRule(cfg, ruleID); err != nil {
+			log.Printf("Erro ao remover regra %s na Cloudflare: %v", ruleID, err)
+		}
+		http.Error(w, "Erro ao salvar alias: "+err.Error(), 500)
+		return
+	}
 
-	database.DB.Exec("DELETE FROM email_tags WHERE email_id = ?", ruleID)
+	scheduler.Schedule(ruleID, expiresAt)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": ruleID, "email": alias, "domain": dom.Domain, "expires_at": expiresAt})
+}
+
+// resolveTTL calcula a validade pedida (expires_at absoluto ou ttl relativo) respeitando o máximo configurado
+func resolveTTL(req models.CreateRequest) (time.Duration, error) {
+	ttl := config.GetDefaultTTL()
+	if req.ExpiresAt != nil {
+		ttl = time.Until(*req.ExpiresAt)
+	} else if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			return 0, fmt.Errorf("TTL inválido: use o formato 30m, 2h, etc")
+		}
+		ttl = d
+	}
+
+	if ttl < time.Minute {
+		return 0, fmt.Errorf("a validade mínima é de 1 minuto")
+	}
+	if max := config.GetMaxTTL(); ttl > max {
+		return 0, fmt.Errorf("a validade máxima é de %s", max)
+	}
+	return ttl.Round(time.Second), nil
+}
 
-	for _, tagName := range req.Tags {
-		tagName = strings.TrimSpace(tagName)
-		if tagName == "" {
+// destinationList junta o destino único e a lista (nessa ordem), sem vazios nem repetidos
+func destinationList(single string, list []string) []string {
+	dests := []string{}
+	seen := make(map[string]bool)
+	for _, d := range append([]string{single}, list...) {
+		d = strings.TrimSpace(d)
+		if d == "" || seen[strings.ToLower(d)] {
 			continue
 		}
-		var tagID int64
-		var tagColor string
-		err := database.DB.QueryRow("SELECT id, color FROM tags WHERE name = ?", tagName).Scan(&tagID, &tagColor)
+		seen[strings.ToLower(d)] = true
+		dests = append(dests, d)
+	}
+	return dests
+}
 
-		if err == sql.ErrNoRows {
-			idx, _ := rand.Int(rand.Reader, big.NewInt(int64(len(coresTags))))
-			newColor := coresTags[idx.Int64()]
-			res, err := database.DB.Exec("INSERT INTO tags (name, color) VALUES (?, ?)", tagName, newColor)
-			if err != nil {
-				continue
-			}
-			tagID, _ = res.LastInsertId()
-		} else if err != nil {
+// senderPatterns normaliza uma lista de remetentes: endereços completos ou domínios (com ou
+// sem "@" na frente), em minúsculas, sem vazios nem repetidos e em ordem (como o banco devolve)
+func senderPatterns(list []string) ([]string, error) {
+	patterns := []string{}
+	seen := make(map[string]bool)
+	for _, raw := range list {
+		if strings.TrimSpace(raw) == "" {
 			continue
 		}
-		database.DB.Exec("INSERT INTO email_tags (email_id, tag_id) VALUES (?, ?)", ruleID, tagID)
+		p, ok := models.SenderPattern(raw)
+		if !ok {
+			return nil, fmt.Errorf("remetente inválido: %s (use um endereço ou um domínio)", strings.TrimSpace(raw))
+		}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		patterns = append(patterns, p)
 	}
+	sort.Strings(patterns)
+	return patterns, nil
+}
 
-	startTimer(ruleID, cfg)
-	json.NewEncoder(w).Encode(map[string]string{"id": ruleID, "email": alias})
+// describeSenders resume a lista para a auditoria
+func describeSenders(list []string) string {
+	if len(list) == 0 {
+		return "(nenhum)"
+	}
+	return strings.Join(list, ", ")
 }
 
-func HandleListActive(w http.ResponseWriter, r *http.Request) {
-	rows, _ := database.DB.Query("SELECT id, email, destination, created_at, active, pinned FROM emails WHERE active = 1 ORDER BY pinned DESC, created_at DESC")
-	if rows != nil {
-		defer rows.Close()
+func describeLock(on bool) string {
+	if on {
+		return "uso único ligado"
 	}
-	sendRowsWithTags(w, rows)
+	return "uso único desligado"
+}
+
+func HandleListActive(w http.ResponseWriter, r *http.Request) {
+	list, err := store.Emails.ListByOwner(currentUserID(r), true)
+	sendEntries(w, list, err)
 }
 
 func HandleHistory(w http.ResponseWriter, r *http.Request) {
-	rows, _ := database.DB.Query("SELECT id, email, destination, created_at, active, pinned FROM emails ORDER BY created_at DESC")
-	if rows != nil {
-		defer rows.Close()
-	}
-	sendRowsWithTags(w, rows)
+	list, err := store.Emails.ListByOwner(currentUserID(r), false)
+	sendEntries(w, list, err)
 }
 
 func HandleDelete(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
-	cfg, err := database.GetConfig()
+	e, err := store.Emails.Get(id)
+	if err != nil || e.OwnerID != currentUserID(r) {
+		http.Error(w, "Email não encontrado", 404)
+		return
+	}
+
+	dom, err := store.Domains.Get(e.DomainID)
 	if err != nil {
 		http.Error(w, "Erro config", 500)
 		return
 	}
-
-	services.CfDeleteRule(cfg, id)
-	database.DB.Exec("UPDATE emails SET active = 0 WHERE id = ?", id)
-
-	timerMu.Lock()
-	if t, ok := activeTimers[id]; ok {
-		t.Stop()
-		delete(activeTimers, id)
+	if err := services.CF.DeleteRule(dom.Config(), id); err != nil && !services.IsNotFound(err) {
+		log.Printf("Erro ao remover regra %s na Cloudflare: %v", id, err)
 	}
-	timerMu.Unlock()
+	if err := store.Emails.Deactivate(id); err != nil {
+		http.Error(w, "Erro ao atualizar DB", 500)
+		return
+	}
+	scheduler.Cancel(id)
 	w.WriteHeader(http.StatusOK)
 }
 
-func sendRowsWithTags(w http.ResponseWriter, rows *sql.Rows) {
-	var list []models.EmailEntry
-	if rows != nil {
-		for rows.Next() {
-			var e models.EmailEntry
-			rows.Scan(&e.ID, &e.Email, &e.Destination, &e.CreatedAt, &e.Active, &e.Pinned)
-			
-			tagRows, err := database.DB.Query(`
-				SELECT t.id, t.name, t.color 
-				FROM tags t 
-				JOIN email_tags et ON t.id = et.tag_id 
-				WHERE et.email_id = ?`, e.ID)
-			
-			if err == nil {
-				var tags []models.Tag
-				for tagRows.Next() {
-					var t models.Tag
-					tagRows.Scan(&t.ID, &t.Name, &t.Color)
-					tags = append(tags, t)
-				}
-				tagRows.Close()
-				e.Tags = tags
-			}
-			if e.Tags == nil { e.Tags = []models.Tag{} }
+// HandleSync expõe a reconciliação com a Cloudflare: GET devolve o último relatório,
+// POST executa agora (?dry_run=1 apenas reporta a divergência)
+func HandleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(scheduler.LastReport())
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
 
-			list = append(list, e)
-		}
+	dryRun := r.URL.Query().Get("dry_run") == "1"
+	report, err := scheduler.Reconcile(dryRun)
+	if err != nil {
+		http.Error(w, "Erro na reconciliação: "+err.Error(), 500)
+		return
 	}
-	if list == nil {
-		list = []models.EmailEntry{}
+	json.NewEncoder(w).Encode(report)
+}
+
+// HandleAnalyticsPull importa agora (POST) as métricas de Email Routing das zonas
+func HandleAnalyticsPull(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", 405)
+		return
 	}
-	json.NewEncoder(w).Encode(list)
+	imported, err := scheduler.PullAnalytics()
+	if err != nil && imported == 0 {
+		cloudflareError(w, "Erro ao importar métricas: ", err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]int{"imported": imported})
 }
 
-func startTimer(id string, cfg models.Config) {
-	timerMu.Lock()
-	activeTimers[id] = time.AfterFunc(5*time.Minute, func() {
-		services.CfDeleteRule(cfg, id)
-		database.DB.Exec("UPDATE emails SET active = 0 WHERE id = ?", id)
-		timerMu.Lock()
-		delete(activeTimers, id)
-		timerMu.Unlock()
-	})
-	timerMu.Unlock()
+// cloudflareError responde com a falha da Cloudflare usando o status equivalente
+// (ex: 400 para destino não verificado, 429 com Retry-After quando a API limitou)
+func cloudflareError(w http.ResponseWriter, prefix string, err error) {
+	var cfErr *services.CFError
+	if errors.As(err, &cfErr) && cfErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(cfErr.RetryAfter.Seconds()+0.5)))
+	}
+	http.Error(w, prefix+err.Error(), services.HTTPStatus(err))
+}
+
+// sendEntries completa a validade efetiva de cada alias e responde com a lista
+func sendEntries(w http.ResponseWriter, list []models.EmailEntry, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	for i := range list {
+		e := &list[i]
+		ttl := scheduler.TTLOf(e.TTL)
+		e.TTL = int64(ttl.Seconds())
+		if e.Active && !e.Pinned {
+			// Registros anteriores à coluna expires_at são calculados a partir da criação
+			if e.ExpiresAt == nil {
+				exp := e.CreatedAt.Add(ttl)
+				e.ExpiresAt = &exp
+			}
+		} else {
+			e.ExpiresAt = nil
+		}
+	}
+	json.NewEncoder(w).Encode(list)
 }
 
 func gerarNomeEngracado() string {
@@ -366,4 +680,4 @@ func gerarNomeEngracado() string {
 	nSub, _ := rand.Int(rand.Reader, big.NewInt(int64(len(substantivos))))
 	nNum, _ := rand.Int(rand.Reader, big.NewInt(1000))
 	return fmt.Sprintf("%s-%s-%d", substantivos[nSub.Int64()], adjetivos[nAdj.Int64()], nNum.Int64())
-}
\ No newline at end of file
+}
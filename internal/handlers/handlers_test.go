@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"tempmail/internal/database"
+	"tempmail/internal/models"
+	"tempmail/internal/scheduler"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+	"tempmail/internal/testutil"
+	"testing"
+)
+
+// call executa o handler com o corpo em JSON e o token informado (vazio = sem Authorization)
+func call(h http.HandlerFunc, method, target, token string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestCreateAndDeleteAlias(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	cfg := testutil.Domain(t, "exemplo.test").Config()
+	req := models.CreateRequest{Email: "compras@exemplo.test", Destination: "ana@dest.test", TTL: "1h"}
+
+	// A Cloudflare só aceita destinos cadastrados e confirmados
+	if w := call(HandleCreate, http.MethodPost, "/api/create", "", req); w.Code == http.StatusOK {
+		t.Fatalf("destino não confirmado foi aceito: %s", w.Body)
+	}
+	if n := len(cf.Rules(cfg.ZoneID)); n != 0 {
+		t.Fatalf("%d regras criadas com destino recusado", n)
+	}
+
+	testutil.Destination(t, cf, cfg, req.Destination)
+	w := call(HandleCreate, http.MethodPost, "/api/create", "", req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("criar alias: status %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+
+	rules := cf.Rules(cfg.ZoneID)
+	if len(rules) != 1 || rules[0].ID != created.ID || rules[0].Email != req.Email || strings.Join(rules[0].Destinations, ",") != req.Destination {
+		t.Fatalf("regras na zona: %+v", rules)
+	}
+	var active bool
+	if err := database.DB.QueryRow("SELECT active FROM emails WHERE id = ?", created.ID).Scan(&active); err != nil || !active {
+		t.Fatalf("alias gravado: active=%v, %v", active, err)
+	}
+
+	// O mesmo endereço não pode ter duas regras na zona
+	if w := call(HandleCreate, http.MethodPost, "/api/create", "", req); w.Code == http.StatusOK {
+		t.Fatalf("alias repetido foi aceito")
+	}
+
+	if w := call(HandleDelete, http.MethodDelete, "/api/delete?id="+created.ID, "", nil); w.Code != http.StatusOK {
+		t.Fatalf("destruir alias: status %d: %s", w.Code, w.Body)
+	}
+	if n := len(cf.Rules(cfg.ZoneID)); n != 0 {
+		t.Fatalf("regra continua na zona após destruir o alias")
+	}
+	database.DB.QueryRow("SELECT active FROM emails WHERE id = ?", created.ID).Scan(&active)
+	if active {
+		t.Fatal("alias continua ativo após destruir")
+	}
+}
+
+// createIn cria um alias e confere que a regra foi para a zona do domínio devolvido
+func createIn(t *testing.T, cf *services.FakeCloudflare, req models.CreateRequest) (id, domain string) {
+	t.Helper()
+	w := call(HandleCreate, http.MethodPost, "/api/create", "", req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("criar alias %+v: status %d: %s", req, w.Code, w.Body)
+	}
+	var created struct {
+		ID     string `json:"id"`
+		Email  string `json:"email"`
+		Domain string `json:"domain"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+	if !strings.HasSuffix(created.Email, "@"+created.Domain) {
+		t.Fatalf("endereço %s fora do domínio %s", created.Email, created.Domain)
+	}
+	found := false
+	for _, r := range cf.Rules("zone-" + created.Domain) {
+		found = found || r.ID == created.ID
+	}
+	if !found {
+		t.Fatalf("regra de %s não está na zona de %s", created.Email, created.Domain)
+	}
+	return created.ID, created.Domain
+}
+
+func TestCreateChoosesDomain(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	const destination = "ana@dest.test"
+
+	if w := call(HandleCreate, http.MethodPost, "/api/create", "", models.CreateRequest{Destination: destination}); w.Code != http.StatusBadRequest {
+		t.Fatalf("sem domínios: status %d, esperado 400", w.Code)
+	}
+
+	def := testutil.Domain(t, "exemplo.test")
+	other := testutil.Domain(t, "outro.test")
+	for _, d := range []models.Domain{def, other} {
+		testutil.Destination(t, cf, d.Config(), destination)
+	}
+
+	for _, tc := range []struct {
+		req  models.CreateRequest
+		want string
+	}{
+		{models.CreateRequest{}, def.Domain},
+		{models.CreateRequest{Domain: "outro.test"}, other.Domain},
+		{models.CreateRequest{Domain: "@OUTRO.test"}, other.Domain},
+		{models.CreateRequest{Email: "loja@outro.test"}, other.Domain},
+		// O domínio do endereço prevalece sobre o campo domain
+		{models.CreateRequest{Email: "loja@exemplo.test", Domain: "outro.test"}, def.Domain},
+	} {
+		tc.req.Destination = destination
+		if _, got := createIn(t, cf, tc.req); got != tc.want {
+			t.Errorf("%+v: domínio %s, esperado %s", tc.req, got, tc.want)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, got := createIn(t, cf, models.CreateRequest{Domain: "random", Destination: destination}); got != def.Domain && got != other.Domain {
+			t.Fatalf("domínio sorteado fora dos cadastrados: %s", got)
+		}
+	}
+
+	for _, req := range []models.CreateRequest{
+		{Domain: "nenhum.test", Destination: destination},
+		{Email: "loja@nenhum.test", Destination: destination},
+		{Email: "sem-arroba", Destination: destination},
+	} {
+		if w := call(HandleCreate, http.MethodPost, "/api/create", "", req); w.Code != http.StatusBadRequest {
+			t.Errorf("%+v: status %d, esperado 400", req, w.Code)
+		}
+	}
+}
+
+// Destruir um alias apaga a regra na zona dele, mesmo que não seja a do domínio padrão
+func TestDeleteAliasInItsZone(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	def := testutil.Domain(t, "exemplo.test")
+	other := testutil.Domain(t, "outro.test")
+	for _, d := range []models.Domain{def, other} {
+		testutil.Destination(t, cf, d.Config(), "ana@dest.test")
+	}
+	kept, _ := createIn(t, cf, models.CreateRequest{Destination: "ana@dest.test"})
+	id, _ := createIn(t, cf, models.CreateRequest{Domain: other.Domain, Destination: "ana@dest.test"})
+
+	if w := call(HandleDelete, http.MethodDelete, "/api/delete?id="+id, "", nil); w.Code != http.StatusOK {
+		t.Fatalf("destruir alias: status %d: %s", w.Code, w.Body)
+	}
+	if n := len(cf.Rules(other.ZoneID)); n != 0 {
+		t.Fatalf("regra continua na zona %s", other.ZoneID)
+	}
+	if rules := cf.Rules(def.ZoneID); len(rules) != 1 || rules[0].ID != kept {
+		t.Fatalf("zona padrão alterada: %+v", rules)
+	}
+
+	// Domínio com aliases ativos não pode ser removido
+	if err := store.Domains.Delete(def.ID); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("remover domínio com alias ativo: %v, esperado ErrConflict", err)
+	}
+	if err := store.Domains.Delete(other.ID); err != nil {
+		t.Fatalf("remover domínio sem aliases ativos: %v", err)
+	}
+}
+
+func TestBurnAndReactivate(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	d := testutil.Domain(t, "exemplo.test")
+	testutil.Destination(t, cf, d.Config(), "ana@dest.test")
+	id, _ := createIn(t, cf, models.CreateRequest{Email: "loja@exemplo.test", Destination: "ana@dest.test"})
+	t.Cleanup(func() { scheduler.Cancel(id) })
+
+	burn := func(burned bool) int {
+		return call(HandleBurn, http.MethodPost, "/api/burn", "", models.BurnRequest{ID: id, Burned: burned}).Code
+	}
+	ruleOf := func() services.FakeRule {
+		rules := cf.Rules(d.ZoneID)
+		if len(rules) != 1 || rules[0].ID != id {
+			t.Fatalf("regras na zona: %+v", rules)
+		}
+		return rules[0]
+	}
+
+	if code := burn(false); code != http.StatusConflict {
+		t.Fatalf("reativar alias ativo: status %d, esperado 409", code)
+	}
+	if code := burn(true); code != http.StatusOK {
+		t.Fatalf("queimar: status %d", code)
+	}
+	// A regra continua na zona, mas descartando
+	if r := ruleOf(); r.Action != models.ActionDrop || len(r.Destinations) != 0 {
+		t.Fatalf("regra queimada: %+v", r)
+	}
+	if e, _ := store.Emails.Get(id); e.State != models.StateBurned || e.Active || scheduler.IsScheduled(id) {
+		t.Fatalf("alias queimado: state=%s active=%v agendado=%v", e.State, e.Active, scheduler.IsScheduled(id))
+	}
+
+	if code := burn(true); code != http.StatusConflict {
+		t.Errorf("queimar de novo: status %d, esperado 409", code)
+	}
+	if w := call(HandlePin, http.MethodPost, "/api/pin", "", models.PinRequest{ID: id, Pinned: false}); w.Code != http.StatusConflict {
+		t.Errorf("desafixar alias queimado: status %d, esperado 409", w.Code)
+	}
+	// O endereço fica reservado e a regra não é tratada como órfã
+	if w := call(HandleCreate, http.MethodPost, "/api/create", "", models.CreateRequest{Email: "loja@exemplo.test", Destination: "ana@dest.test"}); w.Code != http.StatusConflict {
+		t.Errorf("recriar endereço queimado: status %d, esperado 409", w.Code)
+	}
+	if report, err := scheduler.Reconcile(true); err != nil || len(report.OrphansDeleted) != 0 || len(report.Vanished) != 0 {
+		t.Errorf("reconciliação com alias queimado: %+v, %v", report, err)
+	}
+
+	if code := burn(false); code != http.StatusOK {
+		t.Fatalf("reativar: status %d", code)
+	}
+	if r := ruleOf(); r.Action != models.ActionForward || strings.Join(r.Destinations, ",") != "ana@dest.test" {
+		t.Fatalf("regra reativada: %+v", r)
+	}
+	if e, _ := store.Emails.Get(id); e.State != models.StateActive || !e.Active || !scheduler.IsScheduled(id) {
+		t.Fatalf("alias reativado: state=%s active=%v agendado=%v", e.State, e.Active, scheduler.IsScheduled(id))
+	}
+	if w := call(HandlePin, http.MethodPost, "/api/pin", "", models.PinRequest{ID: id, Pinned: true}); w.Code != http.StatusOK {
+		t.Errorf("fixar alias reativado: status %d", w.Code)
+	}
+}
+
+func TestUpdateAliasInPlace(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	d := testutil.Domain(t, "exemplo.test")
+	testutil.Destination(t, cf, d.Config(), "ana@dest.test")
+	testutil.Destination(t, cf, d.Config(), "bia@dest.test")
+	id, _ := createIn(t, cf, models.CreateRequest{Email: "loja@exemplo.test", Destination: "ana@dest.test", Tags: []string{"compras"}})
+	t.Cleanup(func() { scheduler.Cancel(id) })
+
+	update := func(req models.UpdateAliasRequest) int {
+		req.ID = id
+		return call(HandleUpdate, http.MethodPut, "/api/update", "", req).Code
+	}
+	str := func(s string) *string { return &s }
+
+	if code := update(models.UpdateAliasRequest{Destination: str("  ")}); code != http.StatusBadRequest {
+		t.Errorf("destino vazio: status %d, esperado 400", code)
+	}
+	// A Cloudflare recusa destino não confirmado e nada muda no banco
+	if code := update(models.UpdateAliasRequest{Destination: str("eva@dest.test")}); code == http.StatusOK {
+		t.Error("destino não confirmado aceito")
+	}
+	if e, _ := store.Emails.Get(id); e.Destination != "ana@dest.test" || e.UpdatedAt != nil {
+		t.Fatalf("alias após recusa: %+v", e)
+	}
+
+	tags := []string{"viagem", "hotel"}
+	if code := update(models.UpdateAliasRequest{Destination: str("bia@dest.test"), Tags: &tags}); code != http.StatusOK {
+		t.Fatalf("alterar: status %d", code)
+	}
+	// A regra é a mesma, apenas com o novo destino
+	if rules := cf.Rules(d.ZoneID); len(rules) != 1 || rules[0].ID != id || strings.Join(rules[0].Destinations, ",") != "bia@dest.test" {
+		t.Fatalf("regras após alterar: %+v", rules)
+	}
+	list, err := store.Emails.ListByOwner(0, false)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("histórico: %+v, %v", list, err)
+	}
+	if e := list[0]; e.ID != id || e.Destination != "bia@dest.test" || len(e.Tags) != 2 || e.UpdatedAt == nil {
+		t.Fatalf("alias no histórico após alterar: %+v", e)
+	}
+	entries, err := store.Audit.List(10)
+	if err != nil || len(entries) != 1 || entries[0].Action != "alias.destination" || entries[0].Details != "ana@dest.test → bia@dest.test" {
+		t.Fatalf("auditoria: %+v, %v", entries, err)
+	}
+
+	// Queimado, o destino muda só no banco e vale quando o alias for reativado
+	if w := call(HandleBurn, http.MethodPost, "/api/burn", "", models.BurnRequest{ID: id, Burned: true}); w.Code != http.StatusOK {
+		t.Fatalf("queimar: status %d", w.Code)
+	}
+	if code := update(models.UpdateAliasRequest{Destination: str("ana@dest.test")}); code != http.StatusOK {
+		t.Fatalf("alterar alias queimado: status %d", code)
+	}
+	if r := cf.Rules(d.ZoneID)[0]; r.Action != models.ActionDrop {
+		t.Fatalf("regra de alias queimado voltou a encaminhar: %+v", r)
+	}
+	if w := call(HandleBurn, http.MethodPost, "/api/burn", "", models.BurnRequest{ID: id, Burned: false}); w.Code != http.StatusOK {
+		t.Fatalf("reativar: status %d", w.Code)
+	}
+	if r := cf.Rules(d.ZoneID)[0]; r.Action != models.ActionForward || strings.Join(r.Destinations, ",") != "ana@dest.test" {
+		t.Fatalf("regra reativada: %+v", r)
+	}
+}
+
+func TestMultipleDestinations(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	d := testutil.Domain(t, "exemplo.test")
+	for _, dest := range []string{"ana@dest.test", "bia@dest.test", "caio@dest.test"} {
+		testutil.Destination(t, cf, d.Config(), dest)
+	}
+
+	// Um destino não confirmado derruba a criação inteira
+	req := models.CreateRequest{Email: "time@exemplo.test", Destination: "ana@dest.test", Destinations: []string{"eva@dest.test"}}
+	if w := call(HandleCreate, http.MethodPost, "/api/create", "", req); w.Code == http.StatusOK {
+		t.Fatal("destino não confirmado aceito na lista")
+	}
+	if n := len(cf.Rules(d.ZoneID)); n != 0 {
+		t.Fatalf("%d regras criadas com destino recusado", n)
+	}
+
+	// destination vem primeiro; vazios e repetidos (sem diferenciar maiúsculas) saem
+	req.Destinations = []string{"bia@dest.test", " ", "ANA@dest.test", "caio@dest.test"}
+	id, _ := createIn(t, cf, req)
+	t.Cleanup(func() { scheduler.Cancel(id) })
+	want := "ana@dest.test,bia@dest.test,caio@dest.test"
+	if got := strings.Join(cf.Rules(d.ZoneID)[0].Destinations, ","); got != want {
+		t.Fatalf("destinos da regra: %s, esperado %s", got, want)
+	}
+	e, err := store.Emails.Get(id)
+	if err != nil || strings.Join(e.Destinations, ",") != want || e.Destination != "ana@dest.test" {
+		t.Fatalf("alias gravado: %+v, %v", e, err)
+	}
+
+	dests := []string{"caio@dest.test", "bia@dest.test"}
+	if w := call(HandleUpdate, http.MethodPut, "/api/update", "", models.UpdateAliasRequest{ID: id, Destinations: &dests}); w.Code != http.StatusOK {
+		t.Fatalf("alterar destinos: status %d: %s", w.Code, w.Body)
+	}
+	want = "caio@dest.test,bia@dest.test"
+	if got := strings.Join(cf.Rules(d.ZoneID)[0].Destinations, ","); got != want {
+		t.Fatalf("destinos da regra após alterar: %s, esperado %s", got, want)
+	}
+	if list, _ := store.Emails.ListByOwner(0, true); len(list) != 1 || strings.Join(list[0].Destinations, ",") != want || list[0].Destination != "caio@dest.test" {
+		t.Fatalf("aliases ativos após alterar: %+v", list)
+	}
+
+	// Ao reativar, a regra volta a encaminhar para todos os destinos
+	for _, burned := range []bool{true, false} {
+		if w := call(HandleBurn, http.MethodPost, "/api/burn", "", models.BurnRequest{ID: id, Burned: burned}); w.Code != http.StatusOK {
+			t.Fatalf("burned=%v: status %d", burned, w.Code)
+		}
+	}
+	if got := strings.Join(cf.Rules(d.ZoneID)[0].Destinations, ","); got != want {
+		t.Fatalf("destinos da regra reativada: %s, esperado %s", got, want)
+	}
+}
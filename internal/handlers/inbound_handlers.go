@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"tempmail/internal/inbox"
+	"tempmail/internal/models"
+	"tempmail/internal/store"
+	"time"
+)
+
+// inboundMaxSkew é a diferença máxima aceita entre o horário da assinatura e o do servidor.
+// Fora dela uma entrega capturada não vale mais; dentro dela quem barra o reenvio é a lista
+// de assinaturas já usadas, guardada no banco (vale para todas as instâncias) pelo dobro da
+// janela, para cobrir os dois lados da diferença de relógio.
+const inboundMaxSkew = 5 * time.Minute
+
+var (
+	inboundSecret  []byte
+	inboundMaxSize int64 = 10 << 20
+)
+
+// SetInbound define o segredo compartilhado com o Email Worker (INBOUND_SECRET) e o tamanho
+// máximo da mensagem. Sem segredo a rota /api/inbound responde 404.
+func SetInbound(secret string, maxSize int64) {
+	inboundSecret = []byte(secret)
+	if maxSize > 0 {
+		inboundMaxSize = maxSize
+	}
+}
+
+// HandleInbound recebe do Email Worker a mensagem bruta (RFC 822) no corpo do POST, com o
+// envelope em X-Envelope-From e X-Envelope-To. X-Tempmail-Timestamp traz o horário Unix e
+// X-Tempmail-Signature o HMAC-SHA256 em hex de "timestamp\nfrom\nto\ncorpo" com o segredo.
+// A mensagem vai para a caixa do alias (e para os destinos, se houver relay); 404 (alias
+// inexistente ou inativo) e 403 (remetente recusado pelas listas do alias) indicam que o
+// Worker deve rejeitar o email. Uma entrega repetida (mesma assinatura) recebe 409: o Worker
+// que reenvia após perder a resposta deve tratá-lo como entregue.
+func HandleInbound(w http.ResponseWriter, r *http.Request) {
+	if len(inboundSecret) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, inboundMaxSize+1))
+	if err != nil {
+		http.Error(w, "Erro ao ler mensagem", http.StatusBadRequest)
+		return
+	}
+	if int64(len(raw)) > inboundMaxSize {
+		http.Error(w, "Mensagem maior que o limite", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	from := r.Header.Get("X-Envelope-From")
+	to := r.Header.Get("X-Envelope-To")
+	if !validInboundSignature(r.Header.Get("X-Tempmail-Timestamp"), r.Header.Get("X-Tempmail-Signature"), from, to, raw) {
+		http.Error(w, "Assinatura inválida", http.StatusUnauthorized)
+		return
+	}
+	if to == "" {
+		http.Error(w, "X-Envelope-To obrigatório", http.StatusBadRequest)
+		return
+	}
+	if !inbox.ValidAddress(to) || (from != "" && !inbox.ValidAddress(from)) {
+		http.Error(w, "Endereço de envelope inválido", http.StatusBadRequest)
+		return
+	}
+	signature := strings.ToLower(strings.TrimPrefix(r.Header.Get("X-Tempmail-Signature"), "sha256="))
+	claimed, err := store.Signatures.Claim(signature, time.Now().Add(2*inboundMaxSkew))
+	if err != nil {
+		log.Println("Erro ao registrar assinatura do Worker:", err)
+		http.Error(w, "Erro ao guardar mensagem", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		http.Error(w, "Entrega repetida", http.StatusConflict)
+		return
+	}
+
+	err = inbox.Deliver(models.SourceWorker, from, []string{to}, raw)
+	switch {
+	case errors.Is(err, inbox.ErrNoMailbox), errors.Is(err, inbox.ErrUnknownDomain):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, inbox.ErrSenderRejected):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, inbox.ErrInvalidMessage):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		// Nada foi guardado: a mesma entrega pode ser tentada de novo
+		if err := store.Signatures.Release(signature); err != nil {
+			log.Println("Erro ao liberar assinatura do Worker:", err)
+		}
+		log.Println("Erro ao receber mensagem do Worker:", err)
+		http.Error(w, "Erro ao guardar mensagem", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"stored": true, "relayed": inbox.Relay != nil})
+}
+
+// StartInboundSweeper apaga periodicamente as assinaturas do Worker que já venceram
+func StartInboundSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if _, err := store.Signatures.Sweep(now); err != nil {
+				log.Println("Erro ao varrer assinaturas do Worker:", err)
+			}
+		}
+	}()
+}
+
+// validInboundSignature confere o HMAC e a janela de tempo da entrega
+func validInboundSignature(timestamp, signature, from, to string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > inboundMaxSkew || skew < -inboundMaxSkew {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, inboundSecret)
+	mac.Write([]byte(timestamp + "\n" + from + "\n" + to + "\n"))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"tempmail/internal/models"
+	"tempmail/internal/store"
+	"tempmail/internal/testutil"
+	"testing"
+	"time"
+)
+
+const testMessage = "From: Alguém <a@remetente.test>\r\nSubject: Olá\r\n\r\nCorpo da mensagem\r\n"
+
+func useInboundSecret(t *testing.T, secret string) {
+	t.Helper()
+	prev := inboundSecret
+	SetInbound(secret, 0)
+	t.Cleanup(func() { inboundSecret = prev })
+}
+
+// sign calcula a assinatura que o Worker enviaria com o segredo
+func sign(secret string, ts int64, from, to, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10) + "\n" + from + "\n" + to + "\n" + body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func inbound(ts int64, signature, from, to, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/inbound", strings.NewReader(body))
+	r.Header.Set("X-Envelope-From", from)
+	r.Header.Set("X-Envelope-To", to)
+	r.Header.Set("X-Tempmail-Timestamp", strconv.FormatInt(ts, 10))
+	r.Header.Set("X-Tempmail-Signature", signature)
+	w := httptest.NewRecorder()
+	HandleInbound(w, r)
+	return w
+}
+
+func TestInbound(t *testing.T) {
+	testutil.DB(t)
+	useInboundSecret(t, "segredo-worker")
+	owner := testutil.User(t, "dono", "segredo123", "user")
+	dom := testutil.Domain(t, "exemplo.test")
+	e := testutil.Alias(t, models.EmailEntry{Email: "caixa@exemplo.test", OwnerID: owner.ID, DomainID: dom.ID})
+
+	now := time.Now().Unix()
+	from := "a@remetente.test"
+	valid := sign("segredo-worker", now, from, e.Email, testMessage)
+
+	for _, tc := range []struct {
+		name      string
+		ts        int64
+		signature string
+	}{
+		{"outro segredo", now, sign("outro", now, from, e.Email, testMessage)},
+		{"corpo alterado", now, sign("segredo-worker", now, from, e.Email, testMessage+"x")},
+		{"sem assinatura", now, ""},
+		{"não hex", now, "zz" + valid[2:]},
+		{"horário antigo", now - 600, sign("segredo-worker", now-600, from, e.Email, testMessage)},
+		{"horário futuro", now + 600, sign("segredo-worker", now+600, from, e.Email, testMessage)},
+	} {
+		if w := inbound(tc.ts, tc.signature, from, e.Email, testMessage); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, esperado 401", tc.name, w.Code)
+		}
+	}
+	if list, _ := store.Messages.List(owner.ID, "", 10); len(list) != 0 {
+		t.Fatalf("entrega recusada foi guardada: %+v", list)
+	}
+
+	if w := inbound(now, "sha256="+valid, from, e.Email, testMessage); w.Code != http.StatusOK {
+		t.Fatalf("entrega válida: status %d: %s", w.Code, w.Body)
+	}
+	list, err := store.Messages.List(owner.ID, "", 10)
+	if err != nil || len(list) != 1 || list[0].Subject != "Olá" || list[0].MailFrom != from {
+		t.Fatalf("mensagem guardada: %+v, %v", list, err)
+	}
+	if got, _ := store.Emails.Get(e.ID); got.Hits != 1 {
+		t.Errorf("hits: %d, esperado 1", got.Hits)
+	}
+
+	// A mesma entrega, em qualquer grafia da assinatura, é recusada
+	for _, signature := range []string{valid, strings.ToUpper(valid)} {
+		if w := inbound(now, signature, from, e.Email, testMessage); w.Code != http.StatusConflict {
+			t.Errorf("reenvio: status %d, esperado 409", w.Code)
+		}
+	}
+	if list, _ := store.Messages.List(owner.ID, "", 10); len(list) != 1 {
+		t.Fatalf("reenvio guardado de novo: %d mensagens", len(list))
+	}
+
+	// Alias inexistente: o Worker deve rejeitar o email
+	to := "ninguem@exemplo.test"
+	if w := inbound(now, sign("segredo-worker", now, from, to, testMessage), from, to, testMessage); w.Code != http.StatusNotFound {
+		t.Errorf("alias inexistente: status %d, esperado 404", w.Code)
+	}
+
+	// Remetente recusado pelas listas do alias: o Worker também deve rejeitar
+	block := []string{"remetente.test"}
+	if err := store.Emails.Update(e.ID, owner.ID, store.EmailUpdate{BlockSenders: &block}); err != nil {
+		t.Fatal(err)
+	}
+	if w := inbound(now, sign("segredo-worker", now, from, e.Email, testMessage+"\r\n"), from, e.Email, testMessage+"\r\n"); w.Code != http.StatusForbidden {
+		t.Errorf("remetente bloqueado: status %d, esperado 403", w.Code)
+	}
+
+	// Sem segredo configurado a rota não existe
+	useInboundSecret(t, "")
+	if w := inbound(now, valid, from, e.Email, testMessage); w.Code != http.StatusNotFound {
+		t.Errorf("sem segredo: status %d, esperado 404", w.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"tempmail/internal/models"
+	"tempmail/internal/store"
+)
+
+// HandleMessages é a caixa de entrada dos aliases do usuário. GET sem ?id= lista os resumos
+// (?alias= filtra, ?limit= padrão 50); GET com ?id= devolve a mensagem completa e a marca
+// como lida; DELETE ?id= apaga a mensagem e os anexos.
+func HandleMessages(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(r)
+	q := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		if q.Get("id") == "" {
+			limit, err := strconv.Atoi(q.Get("limit"))
+			if err != nil || limit <= 0 || limit > 500 {
+				limit = 50
+			}
+			list, err := store.Messages.List(userID, q.Get("alias"), limit)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+
+		id, _ := strconv.ParseInt(q.Get("id"), 10, 64)
+		m, err := store.Messages.Get(id, userID)
+		if err != nil {
+			messageError(w, err)
+			return
+		}
+		if !m.Seen {
+			if err := store.Messages.MarkSeen(id, userID); err != nil {
+				messageError(w, err)
+				return
+			}
+			m.Seen = true
+		}
+		json.NewEncoder(w).Encode(m)
+
+	case http.MethodDelete:
+		if !hasScope(r, models.ScopeAliasWrite) {
+			http.Error(w, "Escopo insuficiente: "+models.ScopeAliasWrite, http.StatusForbidden)
+			return
+		}
+		id, _ := strconv.ParseInt(q.Get("id"), 10, 64)
+		if err := store.Messages.Delete(id, userID); err != nil {
+			messageError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleAttachment baixa um anexo (?id=) de uma mensagem do usuário
+func HandleAttachment(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	a, err := store.Messages.Attachment(id, currentUserID(r))
+	if err != nil {
+		messageError(w, err)
+		return
+	}
+
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// Sempre como download: o conteúdo vem de terceiros e não deve ser renderizado no painel
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.Itoa(len(a.Data)))
+	w.Write(a.Data)
+}
+
+func messageError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Mensagem não encontrada", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
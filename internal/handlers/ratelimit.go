@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"tempmail/internal/services"
+	"time"
+)
+
+// Tentativas de login: por IP o bloqueio começa cedo; por usuário a tolerância é maior,
+// para que um atacante não tranque facilmente a conta de outra pessoa.
+var (
+	loginIPGuard   = services.NewLoginGuard(5, time.Second, 15*time.Minute)
+	loginUserGuard = services.NewLoginGuard(10, time.Second, 15*time.Minute)
+)
+
+// trustedProxies são os proxies reversos autorizados a informar o IP real via X-Forwarded-For
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies define os proxies confiáveis (TRUSTED_PROXIES)
+func SetTrustedProxies(nets []*net.IPNet) {
+	trustedProxies = nets
+}
+
+// clientIP extrai o IP de origem da requisição. O X-Forwarded-For só é considerado quando
+// a conexão vem de um proxy confiável, e é lido da direita para a esquerda até o primeiro
+// endereço que não seja de um proxy (os anteriores podem ter sido forjados pelo cliente).
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		host = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return host
+}
+
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimit limita a frequência de chamadas à rota: por usuário quando autenticado
+// (deve ficar dentro do AuthMiddleware) e por IP nas rotas públicas
+func RateLimit(limiter *services.RateLimiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + clientIP(r)
+		if id := currentUserID(r); id != 0 {
+			key = fmt.Sprintf("user:%d", id)
+		}
+		if ok, wait := limiter.Allow(key); !ok {
+			tooManyRequests(w, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// tooManyRequests responde 429 informando em quantos segundos tentar de novo
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Muitas tentativas. Tente novamente em alguns instantes.", http.StatusTooManyRequests)
+}
+
+// loginKeys identifica a origem e a conta de uma tentativa de login
+func loginKeys(r *http.Request, username string) (string, string) {
+	return clientIP(r), strings.ToLower(strings.TrimSpace(username))
+}
+
+// loginLocked responde 429 se o IP ou o usuário estiverem bloqueados
+func loginLocked(w http.ResponseWriter, ip, username string) bool {
+	wait := loginIPGuard.Locked(ip)
+	if d := loginUserGuard.Locked(username); d > wait {
+		wait = d
+	}
+	if wait > 0 {
+		tooManyRequests(w, wait)
+		return true
+	}
+	return false
+}
+
+func loginFailed(ip, username string) {
+	loginIPGuard.Fail(ip)
+	loginUserGuard.Fail(username)
+}
+
+func loginSucceeded(ip, username string) {
+	loginIPGuard.Success(ip)
+	loginUserGuard.Success(username)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"tempmail/internal/config"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/testutil"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 127.0.0.1")
+	SetTrustedProxies(config.GetTrustedProxies())
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	for _, tc := range []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"peer não confiável ignora o cabeçalho", "203.0.113.9:5000", []string{"1.2.3.4"}, "203.0.113.9"},
+		{"proxy confiável sem cabeçalho", "10.0.0.1:5000", nil, "10.0.0.1"},
+		{"proxy confiável", "127.0.0.1:5000", []string{"1.2.3.4"}, "1.2.3.4"},
+		{"vários saltos internos", "10.0.0.1:5000", []string{"1.2.3.4, 10.0.0.2, 10.0.0.3"}, "1.2.3.4"},
+		{"entrada forjada à esquerda", "10.0.0.1:5000", []string{"6.6.6.6, 1.2.3.4, 10.0.0.2"}, "1.2.3.4"},
+		{"cabeçalho repetido", "10.0.0.1:5000", []string{"6.6.6.6", "1.2.3.4"}, "1.2.3.4"},
+		{"lixo interrompe a leitura", "10.0.0.1:5000", []string{"1.2.3.4, lixo, 10.0.0.2"}, "10.0.0.2"},
+		{"só proxies", "10.0.0.1:5000", []string{"10.0.0.2"}, "10.0.0.2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remote
+			for _, v := range tc.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := clientIP(r); got != tc.want {
+				t.Errorf("clientIP = %s, esperado %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// useLoginGuards troca os controles de tentativas por outros com 2 falhas toleradas
+func useLoginGuards(t *testing.T) {
+	prevIP, prevUser := loginIPGuard, loginUserGuard
+	loginIPGuard = services.NewLoginGuard(2, time.Minute, time.Hour)
+	loginUserGuard = services.NewLoginGuard(2, time.Minute, time.Hour)
+	t.Cleanup(func() { loginIPGuard, loginUserGuard = prevIP, prevUser })
+}
+
+func loginFrom(ip, username, password string) int {
+	w := call(func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = ip + ":5000"
+		HandleLogin(w, r)
+	}, http.MethodPost, "/api/login", "", models.LoginRequest{Username: username, Password: password})
+	return w.Code
+}
+
+func TestLoginLockoutPerUsername(t *testing.T) {
+	testutil.DB(t)
+	testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+	useLoginGuards(t)
+
+	// As falhas contra a conta vêm de IPs diferentes: quem tranca é a conta
+	for i, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		if code := loginFrom(ip, "ana", "errada"); code != http.StatusUnauthorized {
+			t.Fatalf("falha %d: status %d, esperado 401", i+1, code)
+		}
+	}
+	if code := loginFrom("203.0.113.4", "ana", "segredo123"); code != http.StatusTooManyRequests {
+		t.Fatalf("conta bloqueada: status %d, esperado 429", code)
+	}
+	if code := loginFrom("203.0.113.4", "Ana ", "segredo123"); code != http.StatusTooManyRequests {
+		t.Fatalf("o bloqueio da conta ignora maiúsculas e espaços: status %d", code)
+	}
+}
+
+func TestLoginLockoutPerIP(t *testing.T) {
+	testutil.DB(t)
+	testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+	useLoginGuards(t)
+
+	// O mesmo IP tenta contas diferentes: quem tranca é o IP
+	for i, user := range []string{"bia", "caio", "duda"} {
+		if code := loginFrom("203.0.113.1", user, "errada"); code != http.StatusUnauthorized {
+			t.Fatalf("falha %d: status %d, esperado 401", i+1, code)
+		}
+	}
+	if code := loginFrom("203.0.113.1", "ana", "segredo123"); code != http.StatusTooManyRequests {
+		t.Fatalf("IP bloqueado: status %d, esperado 429", code)
+	}
+	if code := loginFrom("203.0.113.2", "ana", "segredo123"); code != http.StatusOK {
+		t.Fatalf("outro IP: status %d, esperado 200", code)
+	}
+}
+
+func TestLoginSuccessResetsFailures(t *testing.T) {
+	testutil.DB(t)
+	testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+	useLoginGuards(t)
+
+	// Duas falhas, um acerto e mais duas falhas: sem o reset seriam quatro e haveria bloqueio
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 2; i++ {
+			if code := loginFrom("203.0.113.1", "ana", "errada"); code != http.StatusUnauthorized {
+				t.Fatalf("rodada %d, falha %d: status %d, esperado 401", round+1, i+1, code)
+			}
+		}
+		if code := loginFrom("203.0.113.1", "ana", "segredo123"); code != http.StatusOK {
+			t.Fatalf("rodada %d: status %d, esperado 200", round+1, code)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"tempmail/internal/store"
+)
+
+// HandleSessions lista as sessões ativas do usuário (GET) e permite revogá-las (DELETE):
+// ?id= encerra uma sessão específica e ?all=1 encerra todas, exceto a atual
+func HandleSessions(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(r)
+	current := currentSessionID(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := store.Sessions.List(userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range list {
+			list[i].Current = list[i].ID == current
+		}
+		json.NewEncoder(w).Encode(list)
+
+	case http.MethodDelete:
+		if r.URL.Query().Get("all") == "1" {
+			if err := store.Sessions.RevokeUser(userID, current); err != nil {
+				http.Error(w, "Erro ao encerrar sessões", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		id := r.URL.Query().Get("id")
+		session, err := store.Sessions.Get(id)
+		if err != nil || session.UserID != userID {
+			http.Error(w, "Sessão não encontrada", http.StatusNotFound)
+			return
+		}
+		if err := store.Sessions.Revoke(id); err != nil {
+			http.Error(w, "Erro ao encerrar sessão", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+	"time"
+)
+
+// totpIssuer é o nome exibido nos apps autenticadores
+const totpIssuer = "Mail Burner"
+
+// HandleTOTPStatus informa se o 2FA está ativo e quantos códigos de recuperação restam
+func HandleTOTPStatus(w http.ResponseWriter, r *http.Request) {
+	userID := currentUserID(r)
+	st, err := store.Users.TOTP(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	remaining, err := store.Users.RecoveryCodesLeft(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":             st.Enabled,
+		"recovery_codes_left": remaining,
+	})
+}
+
+// HandleTOTPSetup gera um novo segredo (ainda inativo) e devolve a URI para o QR code
+func HandleTOTPSetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID := currentUserID(r)
+	if totpEnabled(userID) {
+		http.Error(w, "2FA já está ativo; desative antes de gerar outro segredo", http.StatusConflict)
+		return
+	}
+
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Erro ao gerar segredo", http.StatusInternalServerError)
+		return
+	}
+	if err := store.Users.SetTOTPSecret(userID, secret); err != nil {
+		http.Error(w, "Erro ao salvar segredo", http.StatusInternalServerError)
+		return
+	}
+
+	username := r.Context().Value("username").(string)
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret": secret,
+		"uri":    services.TOTPProvisioningURI(secret, username, totpIssuer),
+	})
+}
+
+// HandleTOTPConfirm ativa o 2FA após o usuário provar que o app gera códigos válidos,
+// devolvendo os códigos de recuperação (exibidos uma única vez)
+func HandleTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+
+	userID := currentUserID(r)
+	st, err := store.Users.TOTP(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if st.Enabled {
+		http.Error(w, "2FA já está ativo", http.StatusConflict)
+		return
+	}
+	if st.Secret == "" {
+		http.Error(w, "Gere um segredo primeiro", http.StatusBadRequest)
+		return
+	}
+
+	step, ok := services.ValidateTOTP(st.Secret, req.Code, time.Now())
+	if !ok {
+		http.Error(w, "Código inválido", http.StatusUnauthorized)
+		return
+	}
+
+	codes, err := services.GenerateRecoveryCodes(10)
+	if err != nil {
+		http.Error(w, "Erro ao gerar códigos de recuperação", http.StatusInternalServerError)
+		return
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = services.HashRecoveryCode(c)
+	}
+	if err := store.Users.EnableTOTP(userID, step, hashes); err != nil {
+		http.Error(w, "Erro ao ativar 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"recovery_codes": codes})
+}
+
+// HandleTOTPDisable desativa o 2FA mediante senha e um código (TOTP ou de recuperação)
+func HandleTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+
+	username := r.Context().Value("username").(string)
+	ip, account := loginKeys(r, username)
+	if loginLocked(w, ip, account) {
+		return
+	}
+	user, err := store.Users.Get(username)
+	if err != nil || !services.CheckPasswordHash(req.Password, user.Password) {
+		loginFailed(ip, account)
+		http.Error(w, "Senha incorreta", http.StatusForbidden)
+		return
+	}
+	if !verifySecondFactor(user.ID, req.Code) {
+		loginFailed(ip, account)
+		http.Error(w, "Código inválido", http.StatusForbidden)
+		return
+	}
+
+	if err := store.Users.DisableTOTP(user.ID); err != nil {
+		http.Error(w, "Erro ao desativar 2FA", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "2FA desativado"})
+}
+
+// HandleLoginTOTP é a segunda etapa do login: troca o desafio + código por uma sessão
+func HandleLoginTOTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+
+	username, err := services.ValidateMFAToken(req.MFAToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	ip, account := loginKeys(r, username)
+	if loginLocked(w, ip, account) {
+		return
+	}
+	user, err := store.Users.Get(username)
+	if err != nil || user.Disabled {
+		http.Error(w, "Usuário desativado ou inexistente", http.StatusUnauthorized)
+		return
+	}
+	if !verifySecondFactor(user.ID, req.Code) {
+		loginFailed(ip, account)
+		http.Error(w, "Código inválido", http.StatusUnauthorized)
+		return
+	}
+
+	loginSucceeded(ip, account)
+	issueSession(w, r, user)
+}
+
+// totpEnabled indica se o usuário exige a segunda etapa no login
+func totpEnabled(userID int64) bool {
+	st, err := store.Users.TOTP(userID)
+	return err == nil && st.Enabled
+}
+
+// verifySecondFactor aceita um código TOTP ainda não usado ou um código de recuperação
+// (que é consumido)
+func verifySecondFactor(userID int64, code string) bool {
+	st, err := store.Users.TOTP(userID)
+	if err != nil || !st.Enabled || st.Secret == "" {
+		return false
+	}
+
+	if step, ok := services.ValidateTOTP(st.Secret, code, time.Now()); ok {
+		// O mesmo código não pode ser usado duas vezes
+		fresh, err := store.Users.ConsumeTOTPStep(userID, step)
+		return err == nil && fresh
+	}
+
+	used, err := store.Users.UseRecoveryCode(userID, services.HashRecoveryCode(code))
+	return err == nil && used
+}
+
+// mfaChallenge é a resposta do login quando a senha confere mas falta o segundo fator
+func mfaChallenge(w http.ResponseWriter, user models.User) {
+	token, err := services.GenerateMFAToken(user.Username)
+	if err != nil {
+		http.Error(w, "Erro ao iniciar 2FA", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mfa_required": true,
+		"mfa_token":    token,
+	})
+}
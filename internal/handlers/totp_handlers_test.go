@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/testutil"
+	"testing"
+	"time"
+)
+
+// totpAt gera o código do passo atual deslocado por offset
+func totpAt(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := services.TOTPCode(secret, services.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// enableTOTP ativa o 2FA da conta logada e devolve o segredo, o código usado na confirmação
+// e os códigos de recuperação
+func enableTOTP(t *testing.T, token string) (secret, used string, recovery []string) {
+	t.Helper()
+	w := call(AuthMiddleware(HandleTOTPSetup), http.MethodPost, "/api/2fa/setup", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("setup do 2FA: status %d: %s", w.Code, w.Body)
+	}
+	var setup struct {
+		Secret string `json:"secret"`
+	}
+	json.NewDecoder(w.Body).Decode(&setup)
+
+	used = totpAt(t, setup.Secret, 0)
+	w = call(AuthMiddleware(HandleTOTPConfirm), http.MethodPost, "/api/2fa/confirm", token, map[string]string{"code": used})
+	if w.Code != http.StatusOK {
+		t.Fatalf("confirmar 2FA: status %d: %s", w.Code, w.Body)
+	}
+	var confirm struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	json.NewDecoder(w.Body).Decode(&confirm)
+	if len(confirm.RecoveryCodes) == 0 {
+		t.Fatal("nenhum código de recuperação devolvido")
+	}
+	return setup.Secret, used, confirm.RecoveryCodes
+}
+
+// challenge faz a primeira etapa do login e devolve o desafio da segunda
+func challenge(t *testing.T, username, password string) string {
+	t.Helper()
+	w := call(HandleLogin, http.MethodPost, "/api/login", "", models.LoginRequest{Username: username, Password: password})
+	var resp struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+		Token       string `json:"token"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || !resp.MFARequired || resp.MFAToken == "" || resp.Token != "" {
+		t.Fatalf("login com 2FA ativo: status %d: %+v", w.Code, resp)
+	}
+	return resp.MFAToken
+}
+
+func loginTOTP(mfaToken, code string) *httptest.ResponseRecorder {
+	return call(HandleLoginTOTP, http.MethodPost, "/api/login/2fa", "", map[string]string{"mfa_token": mfaToken, "code": code})
+}
+
+func TestLoginWithTOTP(t *testing.T) {
+	testutil.DB(t)
+	testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+	secret, used, _ := enableTOTP(t, login(t, "ana", "segredo123").Token)
+
+	mfa := challenge(t, "ana", "segredo123")
+	// O desafio não vale como token de acesso
+	if w := call(whoami, http.MethodGet, "/api/me", mfa, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("desafio aceito como token de acesso: status %d", w.Code)
+	}
+	// O código usado na confirmação já foi consumido
+	if w := loginTOTP(mfa, used); w.Code != http.StatusUnauthorized {
+		t.Fatalf("código já usado na confirmação: status %d, esperado 401", w.Code)
+	}
+
+	// O passo seguinte está dentro da janela de tolerância
+	next := totpAt(t, secret, 1)
+	w := loginTOTP(mfa, next)
+	if w.Code != http.StatusOK {
+		t.Fatalf("segunda etapa: status %d: %s", w.Code, w.Body)
+	}
+	var tok models.TokenResponse
+	json.NewDecoder(w.Body).Decode(&tok)
+	if w := call(whoami, http.MethodGet, "/api/me", tok.Token, nil); w.Code != http.StatusOK {
+		t.Fatalf("token da segunda etapa: status %d", w.Code)
+	}
+
+	// O mesmo código não entra duas vezes
+	if w := loginTOTP(challenge(t, "ana", "segredo123"), next); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reuso do código: status %d, esperado 401", w.Code)
+	}
+}
+
+func TestRecoveryCodeIsConsumed(t *testing.T) {
+	testutil.DB(t)
+	testutil.User(t, "ana", "segredo123", models.RoleAdmin)
+	token := login(t, "ana", "segredo123").Token
+	_, _, codes := enableTOTP(t, token)
+
+	if w := loginTOTP(challenge(t, "ana", "segredo123"), codes[0]); w.Code != http.StatusOK {
+		t.Fatalf("código de recuperação: status %d: %s", w.Code, w.Body)
+	}
+	if w := loginTOTP(challenge(t, "ana", "segredo123"), codes[0]); w.Code != http.StatusUnauthorized {
+		t.Fatalf("código de recuperação reutilizado: status %d, esperado 401", w.Code)
+	}
+
+	w := call(AuthMiddleware(HandleTOTPStatus), http.MethodGet, "/api/2fa", token, nil)
+	var status struct {
+		Enabled bool `json:"enabled"`
+		Left    int  `json:"recovery_codes_left"`
+	}
+	json.NewDecoder(w.Body).Decode(&status)
+	if !status.Enabled || status.Left != len(codes)-1 {
+		t.Fatalf("status do 2FA: %+v, esperado %d códigos restantes", status, len(codes)-1)
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+)
+
+// HandleMe retorna os dados do usuário logado (usado pela UI para exibir recursos de admin)
+func HandleMe(w http.ResponseWriter, r *http.Request) {
+	username := r.Context().Value("username").(string)
+	user, err := store.Users.Get(username)
+	if err != nil {
+		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(user)
+}
+
+// HandleUsers é a gestão de usuários (somente admin):
+// GET lista, POST cria e PUT ?id= altera papel, status, nome ou redefine a senha
+func HandleUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listUsers(w)
+	case http.MethodPost:
+		createUser(w, r)
+	case http.MethodPut:
+		updateUser(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listUsers(w http.ResponseWriter) {
+	users, err := store.Users.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(users)
+}
+
+func createUser(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || len(req.Password) < 6 {
+		http.Error(w, "Usuário obrigatório e senha com pelo menos 6 caracteres", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+	if !validRole(req.Role) {
+		http.Error(w, "Papel inválido", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, _ := services.HashPassword(req.Password)
+	id, err := store.Users.Create(models.User{
+		Username: req.Username,
+		Password: hashedPassword,
+		FullName: req.FullName,
+		Role:     req.Role,
+	})
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "Nome de usuário já existe", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao criar usuário", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "username": req.Username})
+}
+
+func updateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "ID obrigatório", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Dados inválidos", http.StatusBadRequest)
+		return
+	}
+
+	target, err := store.Users.GetByID(id)
+	if err != nil {
+		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+		return
+	}
+
+	if req.Role != nil && !validRole(*req.Role) {
+		http.Error(w, "Papel inválido", http.StatusBadRequest)
+		return
+	}
+	if req.Password != nil && len(*req.Password) < 6 {
+		http.Error(w, "A senha deve ter pelo menos 6 caracteres", http.StatusBadRequest)
+		return
+	}
+
+	// O sistema nunca pode ficar sem um administrador ativo
+	losesAdmin := target.Role == models.RoleAdmin && !target.Disabled &&
+		((req.Role != nil && *req.Role != models.RoleAdmin) || (req.Disabled != nil && *req.Disabled))
+	if losesAdmin && countActiveAdmins() <= 1 {
+		http.Error(w, "Não é possível remover o último administrador ativo", http.StatusConflict)
+		return
+	}
+	if id == currentUserID(r) && req.Disabled != nil && *req.Disabled {
+		http.Error(w, "Você não pode desativar a si mesmo", http.StatusConflict)
+		return
+	}
+
+	update := store.UserUpdate{
+		Role:      req.Role,
+		Disabled:  req.Disabled,
+		FullName:  req.FullName,
+		ResetTOTP: req.Reset2FA,
+	}
+	if req.Password != nil {
+		hashedPassword, _ := services.HashPassword(*req.Password)
+		update.PasswordHash = &hashedPassword
+	}
+	if err := store.Users.Update(id, update); err != nil {
+		http.Error(w, "Erro ao atualizar usuário", http.StatusInternalServerError)
+		return
+	}
+
+	// Usuário desativado ou com senha redefinida perde todas as sessões abertas
+	if (req.Disabled != nil && *req.Disabled) || req.Password != nil {
+		if err := store.Sessions.RevokeUser(id, ""); err != nil {
+			http.Error(w, "Usuário atualizado, mas houve erro ao encerrar as sessões", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Usuário atualizado"})
+}
+
+// countActiveAdmins conta os administradores ativos; em caso de erro devolve 0, o que
+// bloqueia a alteração em vez de arriscar deixar o sistema sem administrador
+func countActiveAdmins() int {
+	count, err := store.Users.CountActiveAdmins()
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+func validRole(role string) bool {
+	return role == models.RoleAdmin || role == models.RoleUser
+}
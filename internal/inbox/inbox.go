@@ -0,0 +1,194 @@
+// Package inbox recebe emails para os aliases (SMTP embutido ou Email Worker) e os guarda na
+// caixa de entrada de cada um, para que um alias funcione como caixa descartável de verdade.
+package inbox
+
+import (
+	"errors"
+	"log"
+	"net/mail"
+	"strings"
+	"tempmail/internal/models"
+	"tempmail/internal/store"
+	"time"
+)
+
+var (
+	// ErrUnknownDomain indica que o domínio do destinatário não está cadastrado (não há relay)
+	ErrUnknownDomain = errors.New("domínio não atendido")
+	// ErrNoMailbox indica que o alias não existe ou não está ativo
+	ErrNoMailbox = errors.New("alias inexistente ou inativo")
+	// ErrInvalidMessage indica que o conteúdo não é uma mensagem RFC 822
+	ErrInvalidMessage = errors.New("mensagem inválida")
+	// ErrSenderRejected indica que as listas de remetentes do alias recusam o remetente
+	ErrSenderRejected = errors.New("remetente não aceito por este alias")
+)
+
+// Relay reenvia as mensagens recebidas aos destinos do alias; nil (padrão) apenas guarda.
+// Necessário quando a Cloudflare não encaminha mais (MX no SMTP embutido ou regra worker).
+var Relay *SMTPRelay
+
+// CheckRecipient confere se o endereço é um alias ativo de um domínio cadastrado e, quando o
+// remetente do envelope é conhecido, se as listas do alias o aceitam (a recusa é registrada)
+func CheckRecipient(source, addr, sender string) error {
+	e, err := findAlias(addr)
+	if err != nil || sender == "" {
+		return err
+	}
+	return checkSender(e, strings.ToLower(sender), source, time.Now())
+}
+
+// Deliver guarda uma cópia da mensagem na caixa de cada destinatário, conta o recebimento no
+// alias (source indica a origem: models.SourceSMTP ou models.SourceWorker) e, com Relay
+// configurado, reenvia aos destinos. Falhas no reenvio só vão para o log: a mensagem já
+// está guardada e devolver erro faria o remetente mandar de novo. Destinatários cujas
+// listas recusam o remetente ficam de fora; se nenhum aceitar, devolve ErrSenderRejected.
+func Deliver(source, mailFrom string, rcpts []string, raw []byte) error {
+	m, err := Parse(raw)
+	if err != nil {
+		return err
+	}
+	m.MailFrom = mailFrom
+	m.ReceivedAt = time.Now()
+	sender := senderOf(m)
+
+	rejected := 0
+	for _, rcpt := range rcpts {
+		e, err := findAlias(rcpt)
+		if err != nil {
+			return err
+		}
+		if err := checkSender(e, sender, source, m.ReceivedAt); err != nil {
+			rejected++
+			continue
+		}
+		m.Alias = e.Email
+		if _, err := store.Messages.Save(m); err != nil {
+			return err
+		}
+		if err := store.Emails.RecordHit(e.ID, m.ReceivedAt); err != nil {
+			log.Printf("Erro ao contar recebimento de %s: %v", e.Email, err)
+		}
+		recordDelivery(e.Email, sender, models.DeliveryDelivered, source, m.ReceivedAt)
+		if e.SenderLock && len(e.AllowSenders) == 0 {
+			lockSender(e, sender)
+		}
+		if Relay != nil {
+			if err := Relay.Send(mailFrom, e.Destinations, raw); err != nil {
+				log.Printf("Erro ao reenviar mensagem de %s: %v", e.Email, err)
+			}
+		}
+	}
+	if rejected > 0 && rejected == len(rcpts) {
+		return ErrSenderRejected
+	}
+	return nil
+}
+
+// checkSender aplica as listas de remetentes do alias; a recusa vai para o log e para as
+// entregas (status rejected), para o dono ver quem está escrevendo para o alias. Num alias
+// de uso único já travado, um remetente fora da lista também expira o alias.
+func checkSender(e models.EmailEntry, sender, source string, at time.Time) error {
+	if senderAllowed(e, sender) {
+		return nil
+	}
+	log.Printf("Mensagem de %q para %s recusada pela lista de remetentes", sender, e.Email)
+	recordDelivery(e.Email, sender, models.DeliveryRejected, source, at)
+	if e.SenderLock && len(e.AllowSenders) > 0 && !blockedSender(e, sender) && models.SenderDomainOf(sender) != "" {
+		burnAlias(e, sender)
+	}
+	return ErrSenderRejected
+}
+
+// senderAllowed diz se o remetente passa pelas listas: bloqueados nunca entram e, com lista
+// de permitidos, só eles entram (remetente desconhecido inclusive)
+func senderAllowed(e models.EmailEntry, sender string) bool {
+	if blockedSender(e, sender) {
+		return false
+	}
+	if len(e.AllowSenders) == 0 {
+		return true
+	}
+	for _, p := range e.AllowSenders {
+		if matchSender(p, sender) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockedSender(e models.EmailEntry, sender string) bool {
+	for _, p := range e.BlockSenders {
+		if matchSender(p, sender) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSender compara o remetente com um endereço (a@b.com) ou um domínio (b.com, que
+// também vale para x.b.com)
+func matchSender(pattern, sender string) bool {
+	if sender == "" {
+		return false
+	}
+	if strings.Contains(pattern, "@") {
+		return pattern == sender
+	}
+	domain := models.SenderDomainOf(sender)
+	return domain == pattern || strings.HasSuffix(domain, "."+pattern)
+}
+
+// senderOf devolve o remetente do envelope ou, se vazio (avisos de entrega), o do cabeçalho From
+func senderOf(m models.Message) string {
+	if m.MailFrom != "" {
+		return strings.ToLower(m.MailFrom)
+	}
+	if addr, err := mail.ParseAddress(m.From); err == nil {
+		return strings.ToLower(addr.Address)
+	}
+	return ""
+}
+
+func recordDelivery(alias, sender, status, source string, at time.Time) {
+	_, err := store.Deliveries.Record(models.Delivery{
+		Alias:        alias,
+		Sender:       sender,
+		SenderDomain: models.SenderDomainOf(sender),
+		Status:       status,
+		Source:       source,
+		ReceivedAt:   at,
+	})
+	if err != nil {
+		log.Printf("Erro ao registrar entrega para %s: %v", alias, err)
+	}
+}
+
+// findAlias localiza o alias ativo do endereço; o domínio não diferencia maiúsculas
+func findAlias(addr string) (models.EmailEntry, error) {
+	local, host, ok := strings.Cut(strings.TrimSpace(addr), "@")
+	if !ok || local == "" || host == "" {
+		return models.EmailEntry{}, ErrNoMailbox
+	}
+	host = strings.ToLower(host)
+	if _, err := store.Domains.FindByName(host); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return models.EmailEntry{}, ErrUnknownDomain
+		}
+		return models.EmailEntry{}, err
+	}
+
+	e, err := store.Emails.FindByAddress(local + "@" + host)
+	if errors.Is(err, store.ErrNotFound) && local != strings.ToLower(local) {
+		e, err = store.Emails.FindByAddress(strings.ToLower(local) + "@" + host)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return e, ErrNoMailbox
+	}
+	if err != nil {
+		return e, err
+	}
+	if e.State != models.StateActive {
+		return e, ErrNoMailbox
+	}
+	return e, nil
+}
@@ -0,0 +1,97 @@
+package inbox
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"strings"
+	"sync"
+	"tempmail/internal/models"
+	"tempmail/internal/scheduler"
+	"tempmail/internal/store"
+	"time"
+)
+
+// burnMu evita que duas entregas simultâneas de remetentes estranhos expirem (e avisem) duas vezes
+var burnMu sync.Mutex
+
+// lockSender trava o alias de uso único no domínio do primeiro remetente que entregou. O
+// domínio passa pela mesma validação das listas digitadas: o envelope é livre e o padrão
+// gravado aparece no painel.
+func lockSender(e models.EmailEntry, sender string) {
+	domain, ok := models.SenderPattern(models.SenderDomainOf(sender))
+	if !ok {
+		return
+	}
+	locked, err := store.Emails.LockSender(e.Email, domain)
+	if err != nil {
+		log.Printf("Erro ao travar %s no remetente %s: %v", e.Email, domain, err)
+		return
+	}
+	if locked {
+		log.Printf("🔒 %s agora só aceita mensagens de %s", e.Email, domain)
+	}
+}
+
+// burnAlias expira na hora o alias de uso único que recebeu de um remetente fora da lista e
+// avisa o dono: o motivo fica no histórico do alias e na auditoria. O aviso por email aos
+// destinos depende do SMTP de saída (RELAY_SMTP_ADDR): sem Relay ele não é enviado.
+func burnAlias(e models.EmailEntry, sender string) {
+	burnMu.Lock()
+	defer burnMu.Unlock()
+
+	burned, err := scheduler.ExpireNow(e.ID)
+	if err != nil {
+		log.Printf("Erro ao expirar %s após remetente inesperado: %v", e.Email, err)
+		return
+	}
+	if !burned {
+		return
+	}
+
+	details := fmt.Sprintf("remetente inesperado %s (permitidos: %s)", sender, strings.Join(e.AllowSenders, ", "))
+	log.Printf("🔥 %s expirado automaticamente: %s", e.Email, details)
+	if err := store.Emails.SetExpireReason(e.ID, "Uso único: "+details); err != nil {
+		log.Printf("Erro ao registrar o motivo da expiração de %s: %v", e.Email, err)
+	}
+	err = store.Audit.Record(models.AuditEntry{
+		UserID:   e.OwnerID,
+		Username: "sistema",
+		Action:   "alias.autoburn",
+		Target:   e.Email,
+		Details:  details,
+	})
+	if err != nil {
+		log.Printf("Erro ao registrar auditoria (alias.autoburn %s): %v", e.Email, err)
+	}
+
+	if Relay != nil {
+		if err := Relay.Send(noticeSender(e.Email), e.Destinations, burnNotice(e, sender)); err != nil {
+			log.Printf("Erro ao avisar os destinos de %s: %v", e.Email, err)
+		}
+	}
+}
+
+// noticeSender é o remetente dos avisos: o do relay ou o postmaster do domínio do alias
+func noticeSender(alias string) string {
+	if Relay.From != "" {
+		return Relay.From
+	}
+	return "postmaster@" + models.SenderDomainOf(alias)
+}
+
+// burnNotice monta o aviso enviado aos destinos do alias expirado
+func burnNotice(e models.EmailEntry, sender string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", noticeSender(e.Email))
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.Destinations, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Alias "+e.Email+" expirado"))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	fmt.Fprintf(&b, "O alias %s é de uso único e só aceitava mensagens de %s.\r\n", e.Email, strings.Join(e.AllowSenders, ", "))
+	fmt.Fprintf(&b, "Como chegou uma mensagem de %s, ele foi expirado e a regra removida da Cloudflare.\r\n", sender)
+	b.WriteString("\r\nO endereço pode ter vazado; recrie-o pelo histórico se ainda precisar dele.\r\n")
+	return []byte(b.String())
+}
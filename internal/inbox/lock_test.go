@@ -0,0 +1,60 @@
+package inbox
+
+import (
+	"strings"
+	"tempmail/internal/models"
+	"tempmail/internal/store"
+	"tempmail/internal/testutil"
+	"testing"
+)
+
+func TestSenderLockBurnsAlias(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	owner := testutil.User(t, "dono", "segredo123", "user")
+	dom := testutil.Domain(t, "exemplo.test")
+	account, _ := cf.GetAccountID(dom.Config())
+	cf.CreateDestination(dom.Config(), account, "dono@dest.test")
+	cf.VerifyDestination(account, "dono@dest.test")
+	id, err := cf.CreateRule(dom.Config(), "unico@exemplo.test", []string{"dono@dest.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := testutil.Alias(t, models.EmailEntry{ID: id, Email: "unico@exemplo.test", Destination: "dono@dest.test", OwnerID: owner.ID, DomainID: dom.ID, SenderLock: true})
+	c := dial(t, startServer(t, 1<<20))
+
+	// O primeiro remetente trava o alias no domínio dele
+	if code := send(t, c, "loja@bom.test", e.Email, "Subject: pedido\r\n\r\nok\r\n"); code != 250 {
+		t.Fatalf("primeiro remetente: %d, esperado 250", code)
+	}
+	got, _ := store.Emails.Get(e.ID)
+	if len(got.AllowSenders) != 1 || got.AllowSenders[0] != "bom.test" {
+		t.Fatalf("trava: %v, esperado [bom.test]", got.AllowSenders)
+	}
+	cmd(t, c, 250, "MAIL FROM:<outra@bom.test>")
+	cmd(t, c, 250, "RCPT TO:<%s>", e.Email)
+	cmd(t, c, 250, "RSET")
+
+	// Outro domínio expira o alias na hora e o motivo fica no histórico
+	cmd(t, c, 250, "MAIL FROM:<spam@ruim.test>")
+	cmd(t, c, 550, "RCPT TO:<%s>", e.Email)
+	got, _ = store.Emails.Get(e.ID)
+	if got.Active || !strings.Contains(got.ExpireReason, "spam@ruim.test") {
+		t.Fatalf("após remetente estranho: active=%v reason=%q", got.Active, got.ExpireReason)
+	}
+	if rules := cf.Rules(dom.ZoneID); len(rules) != 0 {
+		t.Fatalf("regra continua na Cloudflare: %+v", rules)
+	}
+	cmd(t, c, 250, "RSET")
+	cmd(t, c, 250, "MAIL FROM:<loja@bom.test>")
+	cmd(t, c, 550, "RCPT TO:<%s>", e.Email)
+
+	// Recriar o alias limpa o motivo
+	got.Active, got.State = true, models.StateActive
+	if err := store.Emails.Save(got, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = store.Emails.Get(e.ID); got.ExpireReason != "" {
+		t.Fatalf("motivo após recriar: %q", got.ExpireReason)
+	}
+}
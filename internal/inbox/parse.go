@@ -0,0 +1,157 @@
+package inbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"tempmail/internal/models"
+	"unicode/utf8"
+)
+
+// maxPartDepth limita o aninhamento de multipart (mensagens malformadas ou maliciosas)
+const maxPartDepth = 10
+
+// Parse lê uma mensagem RFC 822 e separa cabeçalhos, texto, HTML e anexos. Partes que não
+// puderem ser decodificadas são guardadas como estão, para não perder a mensagem.
+func Parse(raw []byte) (models.Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+
+	m := models.Message{
+		Headers: map[string][]string(msg.Header),
+		From:    decodeHeader(msg.Header.Get("From")),
+		Subject: decodeHeader(msg.Header.Get("Subject")),
+		Size:    int64(len(raw)),
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return m, err
+	}
+	walkPart(&m, msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Header.Get("Content-Disposition"), body, 0)
+	return m, nil
+}
+
+// walkPart percorre a árvore MIME: o primeiro text/plain e o primeiro text/html viram o
+// corpo da mensagem e o restante (ou o que vier marcado como anexo) vira anexo
+func walkPart(m *models.Message, contentType, encoding, disposition string, body []byte, depth int) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || contentType == "" {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" && depth < maxPartDepth {
+		mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err != nil {
+				return
+			}
+			data, err := io.ReadAll(part)
+			if err != nil {
+				return
+			}
+			walkPart(m, part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part.Header.Get("Content-Disposition"), data, depth+1)
+		}
+	}
+
+	data := decodeTransfer(encoding, body)
+	dispType, dispParams, _ := mime.ParseMediaType(disposition)
+	filename := decodeHeader(dispParams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+
+	if dispType != "attachment" && filename == "" {
+		switch {
+		case mediaType == "text/plain" && m.Text == "":
+			m.Text = toUTF8(data, params["charset"])
+			return
+		case mediaType == "text/html" && m.HTML == "":
+			m.HTML = toUTF8(data, params["charset"])
+			return
+		}
+	}
+
+	if filename == "" {
+		filename = "anexo"
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			filename += exts[0]
+		}
+	}
+	m.Attachments = append(m.Attachments, models.Attachment{
+		Filename:    filename,
+		ContentType: mediaType,
+		Size:        int64(len(data)),
+		Data:        data,
+	})
+}
+
+// decodeTransfer desfaz o Content-Transfer-Encoding (base64 ou quoted-printable)
+func decodeTransfer(encoding string, body []byte) []byte {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		clean := bytes.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, body)
+		out := make([]byte, base64.StdEncoding.DecodedLen(len(clean)))
+		n, err := base64.StdEncoding.Decode(out, clean)
+		if err != nil {
+			return body
+		}
+		return out[:n]
+	case "quoted-printable":
+		out, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body)))
+		if err != nil {
+			return body
+		}
+		return out
+	}
+	return body
+}
+
+// toUTF8 converte os charsets latinos mais comuns; os demais seguem como vieram. Texto que
+// já é UTF-8 válido fica como está (remetentes costumam declarar o charset errado).
+func toUTF8(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		if utf8.Valid(data) {
+			return string(data)
+		}
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(data)
+}
+
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(toUTF8(data, charset)), nil
+	},
+}
+
+// decodeHeader decodifica palavras codificadas (=?UTF-8?B?...?=) em cabeçalhos
+func decodeHeader(s string) string {
+	decoded, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return decoded
+}
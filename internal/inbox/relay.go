@@ -0,0 +1,36 @@
+package inbox
+
+import (
+	"net"
+	"net/smtp"
+)
+
+// SMTPRelay entrega as mensagens por um servidor SMTP de saída (ex: o do provedor de email),
+// com STARTTLS quando oferecido e autenticação opcional
+type SMTPRelay struct {
+	Addr     string // host:porta
+	Username string
+	Password string
+	// From substitui o remetente do envelope; sem ele vai o original, que costuma ser
+	// recusado pelo SPF do domínio remetente
+	From string
+}
+
+// Send reenvia a mensagem como veio (cabeçalhos e corpo intactos) para os destinos
+func (r *SMTPRelay) Send(mailFrom string, to []string, raw []byte) error {
+	if len(to) == 0 {
+		return nil
+	}
+	if r.From != "" {
+		mailFrom = r.From
+	}
+	var auth smtp.Auth
+	if r.Username != "" {
+		host, _, err := net.SplitHostPort(r.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", r.Username, r.Password, host)
+	}
+	return smtp.SendMail(r.Addr, auth, mailFrom, to, raw)
+}
@@ -0,0 +1,277 @@
+package inbox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"tempmail/internal/models"
+	"time"
+)
+
+const (
+	// maxRecipients limita os RCPT TO de uma transação
+	maxRecipients = 100
+	// commandTimeout é o tempo máximo de espera por cada comando (RFC 5321 sugere 5 minutos)
+	commandTimeout = 5 * time.Minute
+	// maxBadCommands derruba clientes que só mandam lixo
+	maxBadCommands = 10
+)
+
+// Server é um receptor SMTP mínimo (sem relay, sem autenticação e sem STARTTLS): só aceita
+// mensagens para aliases ativos dos domínios cadastrados
+type Server struct {
+	Hostname string // nome anunciado no 220 e no EHLO
+	MaxSize  int64  // tamanho máximo da mensagem em bytes
+}
+
+// ListenAndServe sobe o receptor SMTP no endereço indicado (ex: ":2525")
+func ListenAndServe(addr, hostname string, maxSize int64) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return (&Server{Hostname: hostname, MaxSize: maxSize}).Serve(ln)
+}
+
+// Serve atende as conexões do listener, uma goroutine por cliente
+func (s *Server) Serve(ln net.Listener) error {
+	defer ln.Close()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// session é o estado de uma conexão SMTP
+type session struct {
+	conn     net.Conn
+	text     *textproto.Conn
+	helo     string
+	mailFrom *string // nil = sem MAIL FROM (o remetente vazio "<>" é válido)
+	rcpts    []string
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	ss := &session{conn: conn, text: textproto.NewConn(conn)}
+	ss.reply(220, s.Hostname+" ESMTP tempmail")
+
+	bad := 0
+	for {
+		conn.SetDeadline(time.Now().Add(commandTimeout))
+		line, err := ss.text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			ss.helo = arg
+			ss.reset()
+			ss.reply(250, s.Hostname)
+		case "EHLO":
+			ss.helo = arg
+			ss.reset()
+			ss.reply(250, s.Hostname, "PIPELINING", "8BITMIME", "SIZE "+strconv.FormatInt(s.MaxSize, 10))
+		case "MAIL":
+			s.mail(ss, arg)
+		case "RCPT":
+			s.rcpt(ss, arg)
+		case "DATA":
+			s.data(ss)
+		case "RSET":
+			ss.reset()
+			ss.reply(250, "OK")
+		case "NOOP":
+			ss.reply(250, "OK")
+		case "VRFY":
+			ss.reply(252, "Não verificamos endereços")
+		case "QUIT":
+			ss.reply(221, "Até logo")
+			return
+		default:
+			bad++
+			if bad >= maxBadCommands {
+				ss.reply(421, "Comandos inválidos demais, encerrando")
+				return
+			}
+			ss.reply(502, "Comando não implementado")
+		}
+	}
+}
+
+func (s *Server) mail(ss *session, arg string) {
+	if ss.helo == "" {
+		ss.reply(503, "Envie HELO/EHLO primeiro")
+		return
+	}
+	if ss.mailFrom != nil {
+		ss.reply(503, "MAIL FROM já informado")
+		return
+	}
+	addr, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		ss.reply(501, "Sintaxe: MAIL FROM:<endereço> (endereço inválido)")
+		return
+	}
+	for _, p := range params {
+		k, v, _ := strings.Cut(p, "=")
+		if strings.EqualFold(k, "SIZE") {
+			if size, err := strconv.ParseInt(v, 10, 64); err == nil && size > s.MaxSize {
+				ss.reply(552, "Mensagem maior que o limite de "+strconv.FormatInt(s.MaxSize, 10)+" bytes")
+				return
+			}
+		}
+	}
+	ss.mailFrom = &addr
+	ss.reply(250, "OK")
+}
+
+func (s *Server) rcpt(ss *session, arg string) {
+	if ss.mailFrom == nil {
+		ss.reply(503, "Envie MAIL FROM primeiro")
+		return
+	}
+	addr, _, ok := parsePath(arg, "TO:")
+	if !ok || addr == "" {
+		ss.reply(501, "Sintaxe: RCPT TO:<endereço> (endereço inválido)")
+		return
+	}
+	if len(ss.rcpts) >= maxRecipients {
+		ss.reply(452, "Destinatários demais")
+		return
+	}
+	switch err := CheckRecipient(models.SourceSMTP, addr, *ss.mailFrom); {
+	case err == nil:
+		ss.rcpts = append(ss.rcpts, addr)
+		ss.reply(250, "OK")
+	case errors.Is(err, ErrUnknownDomain):
+		ss.reply(550, "Relay não permitido")
+	case errors.Is(err, ErrNoMailbox):
+		ss.reply(550, "Caixa inexistente")
+	case errors.Is(err, ErrSenderRejected):
+		ss.reply(550, "Remetente não aceito por este alias")
+	default:
+		log.Println("SMTP: erro ao verificar destinatário:", err)
+		ss.reply(451, "Erro temporário, tente mais tarde")
+	}
+}
+
+func (s *Server) data(ss *session) {
+	if ss.mailFrom == nil || len(ss.rcpts) == 0 {
+		ss.reply(503, "Envie MAIL FROM e RCPT TO primeiro")
+		return
+	}
+	ss.reply(354, "Envie a mensagem terminando com <CRLF>.<CRLF>")
+
+	// O leitor precisa ir até o "." final mesmo se a mensagem passar do limite,
+	// senão o resto dela seria lido como comandos
+	ss.conn.SetDeadline(time.Now().Add(commandTimeout))
+	dot := ss.text.DotReader()
+	raw, err := io.ReadAll(io.LimitReader(dot, s.MaxSize+1))
+	if err != nil {
+		return
+	}
+	if int64(len(raw)) > s.MaxSize {
+		io.Copy(io.Discard, dot)
+		ss.reset()
+		ss.reply(552, "Mensagem maior que o limite de "+strconv.FormatInt(s.MaxSize, 10)+" bytes")
+		return
+	}
+
+	err = Deliver(models.SourceSMTP, *ss.mailFrom, ss.rcpts, raw)
+	ss.reset()
+	if errors.Is(err, ErrSenderRejected) {
+		ss.reply(550, "Remetente não aceito por este alias")
+		return
+	}
+	if err != nil {
+		log.Println("SMTP: erro ao guardar mensagem:", err)
+		ss.reply(451, "Erro ao guardar a mensagem, tente mais tarde")
+		return
+	}
+	ss.reply(250, "Mensagem recebida")
+}
+
+func (ss *session) reset() {
+	ss.mailFrom = nil
+	ss.rcpts = nil
+}
+
+// reply envia a resposta; com várias linhas, todas menos a última levam "-" após o código
+func (ss *session) reply(code int, lines ...string) {
+	w := bufio.NewWriter(ss.conn)
+	for i, line := range lines {
+		sep := " "
+		if i < len(lines)-1 {
+			sep = "-"
+		}
+		fmt.Fprintf(w, "%d%s%s\r\n", code, sep, line)
+	}
+	w.Flush()
+}
+
+// parsePath lê "FROM:<endereço> PARAM=valor ..." (ou "TO:..."), aceitando o endereço com
+// ou sem os sinais de menor/maior
+func parsePath(arg, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	fields := strings.Fields(strings.TrimSpace(arg[len(prefix):]))
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	addr := fields[0]
+	if strings.HasPrefix(addr, "<") {
+		if !strings.HasSuffix(addr, ">") {
+			return "", nil, false
+		}
+		addr = addr[1 : len(addr)-1]
+	}
+	// Rotas de origem antigas (<@a,@b:user@c>) são ignoradas
+	if i := strings.LastIndex(addr, ":"); i >= 0 && strings.HasPrefix(addr, "@") {
+		addr = addr[i+1:]
+	}
+	// O remetente vazio (<>) é válido; qualquer outro endereço precisa ter a sintaxe certa,
+	// porque ele vai para as entregas, as travas de remetente e o painel
+	if addr != "" && !ValidAddress(addr) {
+		return "", nil, false
+	}
+	return addr, fields[1:], true
+}
+
+// ValidAddress confere a sintaxe de um endereço de envelope: parte local em dot-atom (sem
+// aspas, espaços ou sinais de menor/maior) e um nome de host como domínio
+func ValidAddress(addr string) bool {
+	local, domain, ok := strings.Cut(addr, "@")
+	if !ok || local == "" || len(local) > 64 || len(addr) > 254 || !models.IsHostname(domain) {
+		return false
+	}
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, c := range atom {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", c)) {
+				return false
+			}
+		}
+	}
+	return true
+}
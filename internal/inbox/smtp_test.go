@@ -0,0 +1,262 @@
+package inbox
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"tempmail/internal/database"
+	"tempmail/internal/models"
+	"tempmail/internal/store"
+	"tempmail/internal/testutil"
+	"testing"
+)
+
+// startServer sobe o receptor numa porta livre de 127.0.0.1 e devolve o endereço
+func startServer(t *testing.T, maxSize int64) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go (&Server{Hostname: "mx.test", MaxSize: maxSize}).Serve(ln)
+	return ln.Addr().String()
+}
+
+// dial conecta ao receptor, confere a saudação e já envia o EHLO
+func dial(t *testing.T, addr string) *textproto.Conn {
+	t.Helper()
+	c, err := textproto.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	expect(t, c, 220)
+	cmd(t, c, 250, "EHLO cliente.test")
+	return c
+}
+
+// cmd envia um comando e confere o código da resposta
+func cmd(t *testing.T, c *textproto.Conn, code int, format string, args ...interface{}) string {
+	t.Helper()
+	if err := c.PrintfLine(format, args...); err != nil {
+		t.Fatal(err)
+	}
+	return expect(t, c, code)
+}
+
+func expect(t *testing.T, c *textproto.Conn, code int) string {
+	t.Helper()
+	got, msg, err := c.ReadResponse(0)
+	if err != nil && got == 0 {
+		t.Fatalf("resposta: %v", err)
+	}
+	if got != code {
+		t.Fatalf("esperado %d, recebido %d %s", code, got, msg)
+	}
+	return msg
+}
+
+// send faz uma transação completa e devolve o código da resposta ao fim do DATA
+func send(t *testing.T, c *textproto.Conn, from, to, body string) int {
+	t.Helper()
+	cmd(t, c, 250, "MAIL FROM:<%s>", from)
+	cmd(t, c, 250, "RCPT TO:<%s>", to)
+	cmd(t, c, 354, "DATA")
+	w := c.DotWriter()
+	fmt.Fprint(w, body)
+	w.Close()
+	code, _, err := c.ReadResponse(0)
+	if err != nil && code == 0 {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// setup cria um dono, o domínio exemplo.test e o alias ativo caixa@exemplo.test
+func setup(t *testing.T) (models.User, models.EmailEntry) {
+	t.Helper()
+	testutil.DB(t)
+	owner := testutil.User(t, "dono", "segredo123", "user")
+	dom := testutil.Domain(t, "exemplo.test")
+	e := testutil.Alias(t, models.EmailEntry{Email: "caixa@exemplo.test", OwnerID: owner.ID, DomainID: dom.ID})
+	return owner, e
+}
+
+func messages(t *testing.T, ownerID int64, alias string) []models.Message {
+	t.Helper()
+	list, err := store.Messages.List(ownerID, alias, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestRcptRejectsUnknownAndInactive(t *testing.T) {
+	owner, e := setup(t)
+	testutil.Alias(t, models.EmailEntry{Email: "velho@exemplo.test", OwnerID: owner.ID, DomainID: e.DomainID, State: models.StateInactive})
+	c := dial(t, startServer(t, 1<<20))
+
+	cmd(t, c, 250, "MAIL FROM:<alguem@remetente.test>")
+	cmd(t, c, 550, "RCPT TO:<ninguem@exemplo.test>")
+	cmd(t, c, 550, "RCPT TO:<velho@exemplo.test>")
+	cmd(t, c, 550, "RCPT TO:<caixa@outro.test>")
+	cmd(t, c, 250, "RCPT TO:<CAIXA@Exemplo.Test>")
+}
+
+func TestMalformedPaths(t *testing.T) {
+	setup(t)
+	c := dial(t, startServer(t, 1<<20))
+
+	for _, path := range []string{
+		`MAIL FROM:<a@x"onmouseover="alert(1)>`,
+		`MAIL FROM:<a b@x.test>`,
+		`MAIL FROM:<<a@x.test>>`,
+		`MAIL FROM:<a@x.test`,
+		`MAIL FROM:semarroba`,
+	} {
+		cmd(t, c, 501, "%s", path)
+	}
+	cmd(t, c, 250, "MAIL FROM:<>")
+	cmd(t, c, 501, "RCPT TO:<>")
+	cmd(t, c, 501, `RCPT TO:<"caixa"@exemplo.test>`)
+	cmd(t, c, 250, "RCPT TO:<caixa@exemplo.test>")
+}
+
+func TestSizeLimit(t *testing.T) {
+	owner, e := setup(t)
+	c := dial(t, startServer(t, 1024))
+
+	cmd(t, c, 552, "MAIL FROM:<a@remetente.test> SIZE=4096")
+	cmd(t, c, 250, "MAIL FROM:<a@remetente.test> SIZE=512")
+	cmd(t, c, 250, "RSET")
+
+	big := "Subject: grande\r\n\r\n" + strings.Repeat("x", 2048) + "\r\n"
+	if code := send(t, c, "a@remetente.test", e.Email, big); code != 552 {
+		t.Fatalf("mensagem acima do limite: %d, esperado 552", code)
+	}
+	// O resto da mensagem não pode ter sido lido como comandos
+	cmd(t, c, 250, "NOOP")
+	if n := len(messages(t, owner.ID, e.Email)); n != 0 {
+		t.Fatalf("%d mensagens guardadas, esperado nenhuma", n)
+	}
+
+	if code := send(t, c, "a@remetente.test", e.Email, "Subject: pequena\r\n\r\nola\r\n"); code != 250 {
+		t.Fatalf("mensagem dentro do limite: %d, esperado 250", code)
+	}
+}
+
+func TestMultipartWithAttachment(t *testing.T) {
+	owner, e := setup(t)
+	c := dial(t, startServer(t, 1<<20))
+
+	body := strings.Join([]string{
+		"From: =?UTF-8?Q?Jo=C3=A3o?= <joao@remetente.test>",
+		"Subject: =?UTF-8?Q?Relat=C3=B3rio?=",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="ext"`,
+		"",
+		"--ext",
+		`Content-Type: multipart/alternative; boundary="alt"`,
+		"",
+		"--alt",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"segue o relatório",
+		"--alt",
+		"Content-Type: text/html; charset=utf-8",
+		"",
+		"<p>segue o relatório</p>",
+		"--alt--",
+		"--ext",
+		`Content-Type: application/pdf; name="relatorio.pdf"`,
+		"Content-Transfer-Encoding: base64",
+		`Content-Disposition: attachment; filename="relatorio.pdf"`,
+		"",
+		"JVBERi0xLjQK",
+		"--ext--",
+		"",
+	}, "\r\n")
+	if code := send(t, c, "joao@remetente.test", e.Email, body); code != 250 {
+		t.Fatalf("DATA: %d, esperado 250", code)
+	}
+
+	list := messages(t, owner.ID, e.Email)
+	if len(list) != 1 {
+		t.Fatalf("%d mensagens guardadas, esperado 1", len(list))
+	}
+	m, err := store.Messages.Get(list[0].ID, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "Relatório" || m.From != "João <joao@remetente.test>" || m.MailFrom != "joao@remetente.test" {
+		t.Errorf("cabeçalhos: subject=%q from=%q mail_from=%q", m.Subject, m.From, m.MailFrom)
+	}
+	if strings.TrimSpace(m.Text) != "segue o relatório" || strings.TrimSpace(m.HTML) != "<p>segue o relatório</p>" {
+		t.Errorf("corpo: text=%q html=%q", m.Text, m.HTML)
+	}
+	if len(m.Attachments) != 1 || m.Attachments[0].Filename != "relatorio.pdf" || m.Attachments[0].Size != int64(len("%PDF-1.4\n")) {
+		t.Fatalf("anexos: %+v", m.Attachments)
+	}
+	a, err := store.Messages.Attachment(m.Attachments[0].ID, owner.ID)
+	if err != nil || string(a.Data) != "%PDF-1.4\n" {
+		t.Fatalf("conteúdo do anexo: %q, %v", a.Data, err)
+	}
+
+	if got, _ := store.Emails.Get(e.ID); got.Hits != 1 {
+		t.Errorf("hits = %d, esperado 1", got.Hits)
+	}
+	aliases, err := store.Emails.ListByOwner(owner.ID, true)
+	if err != nil || len(aliases) != 1 || aliases[0].MessageCount != 1 || len(aliases[0].SenderDomains) != 1 || aliases[0].SenderDomains[0] != "remetente.test" {
+		t.Fatalf("entregas do alias: %+v, %v", aliases, err)
+	}
+}
+
+func TestSenderLists(t *testing.T) {
+	owner, e := setup(t)
+	allow, block := []string{"bom.test"}, []string{"spam@bom.test"}
+	if err := store.Emails.Update(e.ID, owner.ID, store.EmailUpdate{AllowSenders: &allow, BlockSenders: &block}); err != nil {
+		t.Fatal(err)
+	}
+	c := dial(t, startServer(t, 1<<20))
+
+	for _, tc := range []struct {
+		from string
+		code int
+	}{
+		{"alguem@bom.test", 250},
+		{"alguem@sub.bom.test", 250},
+		{"spam@bom.test", 550},    // bloqueado mesmo dentro do domínio permitido
+		{"alguem@ruim.test", 550}, // fora da lista de permitidos
+	} {
+		cmd(t, c, 250, "MAIL FROM:<%s>", tc.from)
+		cmd(t, c, tc.code, "RCPT TO:<%s>", e.Email)
+		cmd(t, c, 250, "RSET")
+	}
+
+	// Avisos de entrega (<>) são conferidos pelo From na entrega
+	cmd(t, c, 250, "MAIL FROM:<>")
+	cmd(t, c, 250, "RCPT TO:<%s>", e.Email)
+	cmd(t, c, 354, "DATA")
+	w := c.DotWriter()
+	fmt.Fprint(w, "From: spam@bom.test\r\nSubject: aviso\r\n\r\noi\r\n")
+	w.Close()
+	expect(t, c, 550)
+
+	if code := send(t, c, "alguem@bom.test", e.Email, "Subject: oi\r\n\r\nola\r\n"); code != 250 {
+		t.Fatalf("remetente permitido: %d, esperado 250", code)
+	}
+	if n := len(messages(t, owner.ID, e.Email)); n != 1 {
+		t.Fatalf("%d mensagens guardadas, esperado 1", n)
+	}
+
+	// As recusas ficam registradas, mas não contam como mensagens do alias
+	var rejected int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM deliveries WHERE alias = ? AND status = ?", e.Email, models.DeliveryRejected).Scan(&rejected); err != nil || rejected == 0 {
+		t.Fatalf("recusas registradas: %d, %v", rejected, err)
+	}
+	if list, err := store.Emails.ListByOwner(owner.ID, true); err != nil || list[0].MessageCount != 1 {
+		t.Fatalf("entregas do alias: %+v, %v", list, err)
+	}
+}
@@ -1,13 +1,24 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+// Papéis de usuário
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
 
 type User struct {
-	ID           int64  `json:"id"`
-	Username     string `json:"username"`
-	Password     string `json:"-"`
-	FullName     string `json:"full_name"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Password  string    `json:"-"`
+	FullName  string    `json:"full_name"`
+	CreatedAt time.Time `json:"created_at"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
 }
 
 type LoginRequest struct {
@@ -21,10 +32,46 @@ type SetupRequest struct {
 	FullName string `json:"full_name"`
 }
 
+// CreateUserRequest é usado pelo administrador para cadastrar outros usuários
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	FullName string `json:"full_name"`
+	Role     string `json:"role"`
+}
+
+// UpdateUserRequest altera apenas os campos informados
+type UpdateUserRequest struct {
+	Role     *string `json:"role,omitempty"`
+	Disabled *bool   `json:"disabled,omitempty"`
+	Password *string `json:"password,omitempty"`
+	FullName *string `json:"full_name,omitempty"`
+	Reset2FA bool    `json:"reset_2fa,omitempty"` // remove o 2FA de quem perdeu o dispositivo
+}
+
+// Config são as credenciais de uma zona, no formato usado pelo cliente da Cloudflare
 type Config struct {
 	CFToken string `json:"cf_token"`
 	ZoneID  string `json:"zone_id"`
 	Domain  string `json:"domain"`
+	Worker  string `json:"worker,omitempty"` // Email Worker que recebe os aliases (vazio = encaminhar)
+}
+
+// Domain é uma zona da Cloudflare onde os aliases podem ser criados
+type Domain struct {
+	ID        int64     `json:"id"`
+	Domain    string    `json:"domain"`
+	ZoneID    string    `json:"zone_id"`
+	AccountID string    `json:"account_id"`
+	CFToken   string    `json:"cf_token,omitempty"`
+	IsDefault bool      `json:"is_default"`
+	Worker    string    `json:"worker"` // script do Email Worker; vazio = regras encaminham direto
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Config devolve as credenciais do domínio para o cliente da Cloudflare
+func (d Domain) Config() Config {
+	return Config{CFToken: d.CFToken, ZoneID: d.ZoneID, Domain: d.Domain, Worker: d.Worker}
 }
 
 type Destination struct {
@@ -40,22 +87,294 @@ type Tag struct {
 }
 
 type EmailEntry struct {
-	ID          string    `json:"id"`
-	Email       string    `json:"email"`
-	Destination string    `json:"destination"`
-	CreatedAt   time.Time `json:"created_at"`
-	Active      bool      `json:"active"`
-	Pinned      bool      `json:"pinned"`
-	Tags        []Tag     `json:"tags"`
+	ID           string     `json:"id"`
+	Email        string     `json:"email"`
+	Destination  string     `json:"destination"`  // primeiro destino
+	Destinations []string   `json:"destinations"` // todos os destinos, em ordem
+	CreatedAt    time.Time  `json:"created_at"`
+	Active       bool       `json:"active"`
+	Pinned       bool       `json:"pinned"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	TTL          int64      `json:"ttl"` // segundos
+	Tags         []Tag      `json:"tags"`
+	OwnerID      int64      `json:"-"`
+	DomainID     int64      `json:"-"`
+	State        string     `json:"state"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"` // última troca de destino, tags ou remetentes
+	Hits         int64      `json:"hits"`                 // mensagens que chegaram ao sistema (SMTP ou Worker)
+	LastHitAt    *time.Time `json:"last_hit_at,omitempty"`
+
+	// Estatísticas de entrega (tabela deliveries, sem as rejeitadas); só nas listagens
+	MessageCount   int64      `json:"message_count"`
+	LastReceivedAt *time.Time `json:"last_received_at,omitempty"`
+	SenderDomains  []string   `json:"sender_domains"` // mais frequentes primeiro
+
+	// Listas de remetentes (endereços ou domínios) aplicadas na entrada (SMTP ou Worker).
+	// Com AllowSenders preenchida só eles entram; BlockSenders é sempre recusada.
+	AllowSenders []string `json:"allow_senders"`
+	BlockSenders []string `json:"block_senders"`
+	// SenderLock (uso único): o domínio do primeiro remetente entra em AllowSenders e um
+	// remetente fora da lista expira o alias na hora, com aviso ao dono
+	SenderLock bool `json:"sender_lock"`
+	// ExpireReason explica uma expiração automática (ex: remetente fora da trava de uso único)
+	ExpireReason string `json:"expire_reason,omitempty"`
 }
 
+// Tipos de regra de remetente
+const (
+	SenderAllow = "allow"
+	SenderBlock = "block"
+)
+
+// Estados de um alias
+const (
+	StateActive   = "active"   // regra encaminhando
+	StateBurned   = "burned"   // regra trocada por drop: nada é entregue e o endereço fica reservado
+	StateInactive = "inactive" // expirado ou destruído, sem regra na Cloudflare
+)
+
 type CreateRequest struct {
-	Destination string   `json:"destination"`
-	Email       string   `json:"email,omitempty"`
-	Tags        []string `json:"tags,omitempty"`
+	Destination  string     `json:"destination"`
+	Destinations []string   `json:"destinations,omitempty"` // vários destinos (somados a destination)
+	Email        string     `json:"email,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	Domain       string     `json:"domain,omitempty"`      // domínio do alias: vazio = padrão, "random" = sorteado
+	TTL          string     `json:"ttl,omitempty"`         // duração relativa, ex: "1h30m"
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`  // expiração absoluta (tem prioridade sobre ttl)
+	SenderLock   bool       `json:"sender_lock,omitempty"` // uso único: trava no primeiro remetente
 }
 
 type PinRequest struct {
 	ID     string `json:"id"`
 	Pinned bool   `json:"pinned"`
-}
\ No newline at end of file
+}
+
+// UpdateAliasRequest troca os destinos, as tags, as listas de remetentes e/ou o modo de uso
+// único de um alias sem recriá-lo (campos ausentes ficam como estão; destinations tem prioridade sobre destination)
+type UpdateAliasRequest struct {
+	ID           string    `json:"id"`
+	Destination  *string   `json:"destination,omitempty"`
+	Destinations *[]string `json:"destinations,omitempty"`
+	Tags         *[]string `json:"tags,omitempty"`
+	AllowSenders *[]string `json:"allow_senders,omitempty"`
+	BlockSenders *[]string `json:"block_senders,omitempty"`
+	SenderLock   *bool     `json:"sender_lock,omitempty"`
+}
+
+// BurnRequest queima (burned=true) ou reativa (burned=false) um alias
+type BurnRequest struct {
+	ID     string `json:"id"`
+	Burned bool   `json:"burned"`
+}
+
+// Ações de uma regra de roteamento
+const (
+	ActionForward = "forward"
+	ActionDrop    = "drop"
+	ActionWorker  = "worker" // entrega ao Email Worker da zona (usado no lugar de forward quando configurado)
+)
+
+// Ações da regra pega-tudo (catch-all) da zona
+const (
+	CatchAllForward  = "forward"  // entrega tudo que não casa com um alias em um destino
+	CatchAllDrop     = "drop"     // descarta
+	CatchAllDisabled = "disabled" // regra desligada: só os aliases conhecidos recebem
+)
+
+// CatchAll é a regra que recebe os emails enviados a endereços sem alias
+type CatchAll struct {
+	Domain      string `json:"domain"`
+	Action      string `json:"action"`
+	Destination string `json:"destination,omitempty"` // obrigatório para forward
+}
+
+// AuditEntry registra uma alteração administrativa ou de destino de alias
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Details   string    `json:"details"`
+	IP        string    `json:"ip"`
+}
+
+// RoutingRule é uma regra de Email Routing como existe na Cloudflare
+type RoutingRule struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Enabled bool   `json:"enabled"`
+}
+
+// SyncReport resume uma execução da reconciliação entre o SQLite e a Cloudflare
+type SyncReport struct {
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	DryRun         bool      `json:"dry_run"`
+	RulesSeen      int       `json:"rules_seen"`
+	OrphansDeleted []string  `json:"orphans_deleted"` // regras "Temp: …" sem alias ativo no banco
+	Vanished       []string  `json:"vanished"`        // aliases ativos cuja regra sumiu da Cloudflare
+	Errors         []string  `json:"errors"`
+}
+
+// Escopos de acesso. Sessões do painel têm todos; chaves de API só os que receberam
+// e nunca o de conta (gestão de usuários, chaves, senha e configuração).
+const (
+	ScopeAliasRead   = "alias:read"
+	ScopeAliasCreate = "alias:create"
+	ScopeAliasWrite  = "alias:write"
+	ScopeAccount     = "account"
+)
+
+// APIScopes são os escopos que podem ser atribuídos a uma chave de API
+var APIScopes = []string{ScopeAliasRead, ScopeAliasCreate, ScopeAliasWrite}
+
+// APIKey é uma chave de longa duração para scripts; o segredo só é exibido na criação
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Revoked    bool       `json:"revoked"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"` // vazio = todos os escopos de alias
+}
+
+// ParseScopes converte a coluna scopes (separada por vírgula) em lista
+func ParseScopes(s string) []string {
+	scopes := []string{}
+	for _, sc := range strings.Split(s, ",") {
+		if sc = strings.TrimSpace(sc); sc != "" {
+			scopes = append(scopes, sc)
+		}
+	}
+	return scopes
+}
+
+// Session é um login do painel (dispositivo), renovável via refresh token
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Revoked    bool      `json:"-"`
+	Current    bool      `json:"current"`
+}
+
+// TokenResponse é devolvido no login, no refresh e na troca de senha
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // segundos de validade do token de acesso
+}
+
+// Message é um email recebido por um alias (caixa de entrada). As listagens trazem só o
+// resumo; corpo, cabeçalhos e anexos vêm ao abrir a mensagem.
+type Message struct {
+	ID          int64               `json:"id"`
+	Alias       string              `json:"alias"`
+	MailFrom    string              `json:"mail_from"` // remetente do envelope (MAIL FROM)
+	From        string              `json:"from"`      // cabeçalho From
+	Subject     string              `json:"subject"`
+	Headers     map[string][]string `json:"headers,omitempty"`
+	Text        string              `json:"text,omitempty"`
+	HTML        string              `json:"html,omitempty"`
+	Size        int64               `json:"size"`
+	Seen        bool                `json:"seen"`
+	ReceivedAt  time.Time           `json:"received_at"`
+	Attachments []Attachment        `json:"attachments,omitempty"`
+}
+
+// Attachment é um anexo de mensagem; o conteúdo só é carregado no download
+type Attachment struct {
+	ID          int64  `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Data        []byte `json:"-"`
+}
+
+// IsHostname diz se s é um nome de host com ao menos um ponto: rótulos de letras, dígitos e
+// hífen (sem começar ou terminar com hífen), de até 63 caracteres
+func IsHostname(s string) bool {
+	if len(s) > 253 || !strings.Contains(s, ".") {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Delivery é um evento de entrega a um alias
+type Delivery struct {
+	ID           int64     `json:"id"`
+	Alias        string    `json:"alias"`
+	Sender       string    `json:"sender"`
+	SenderDomain string    `json:"sender_domain"`
+	Status       string    `json:"status"`
+	Source       string    `json:"source"`
+	ExternalID   string    `json:"-"` // identificador do evento na origem (importação da Cloudflare)
+	ReceivedAt   time.Time `json:"received_at"`
+}
+
+// SenderDomainOf devolve o domínio (em minúsculas) do endereço remetente, ou vazio se o que
+// vem depois do "@" não for um nome de host válido (o envelope é livre e vai para a tela)
+func SenderDomainOf(sender string) string {
+	i := strings.LastIndex(sender, "@")
+	if i < 0 {
+		return ""
+	}
+	domain := strings.ToLower(sender[i+1:])
+	if !IsHostname(domain) {
+		return ""
+	}
+	return domain
+}
+
+// SenderPattern normaliza uma entrada das listas de remetentes: um endereço (a@b.com) ou um
+// domínio (b.com ou @b.com), em minúsculas. false se não for nenhum dos dois.
+func SenderPattern(p string) (string, bool) {
+	p = strings.ToLower(strings.TrimSpace(p))
+	p = strings.TrimPrefix(p, "@")
+	local, domain, isAddr := strings.Cut(p, "@")
+	if !isAddr {
+		return p, IsHostname(p)
+	}
+	if local == "" || len(local) > 64 || strings.ContainsAny(local, " \t\"'<>(),;:@[]\\") {
+		return p, false
+	}
+	return p, IsHostname(domain)
+}
+
+// Origens de um evento de entrega
+const (
+	SourceSMTP       = "smtp"       // receptor SMTP embutido
+	SourceWorker     = "worker"     // Email Worker via /api/inbound
+	SourceCloudflare = "cloudflare" // métricas de Email Routing (GraphQL)
+)
+
+// Situações de um evento de entrega (eventos da Cloudflare podem trazer outras, como vieram)
+const (
+	DeliveryDelivered = "delivered"
+	DeliveryDropped   = "dropped"
+	DeliveryRejected  = "rejected"
+)
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+	"time"
+)
+
+const (
+	// analyticsPage é quantos eventos são pedidos por consulta à Cloudflare
+	analyticsPage = 1000
+	// analyticsBackfill é até quando a primeira importação de uma zona olha para trás
+	analyticsBackfill = 24 * time.Hour
+)
+
+var analyticsMu sync.Mutex
+
+// PullAnalytics importa para a tabela deliveries os eventos de Email Routing de cada zona,
+// a partir do último já importado. Zonas com Email Worker ficam de fora: as entregas delas
+// já são registradas pelo /api/inbound. Só entram eventos de aliases conhecidos.
+func PullAnalytics() (int, error) {
+	analyticsMu.Lock()
+	defer analyticsMu.Unlock()
+
+	domains, err := store.Domains.List()
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	var firstErr error
+	for _, d := range domains {
+		if d.Worker != "" {
+			continue
+		}
+		n, err := pullZone(d)
+		imported += n
+		if err != nil {
+			log.Printf("Erro ao importar métricas de %s: %v", d.Domain, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return imported, firstErr
+}
+
+func pullZone(d models.Domain) (int, error) {
+	since, err := store.Deliveries.Latest(models.SourceCloudflare, d.Domain)
+	if errors.Is(err, store.ErrNotFound) {
+		since = time.Now().Add(-analyticsBackfill)
+	} else if err != nil {
+		return 0, err
+	}
+
+	known := make(map[string]bool)
+	imported := 0
+	for {
+		// A consulta é inclusiva (>= since): os eventos do último instante voltam e são
+		// descartados pelo external_id
+		events, err := services.CF.EmailEvents(d.Config(), since, analyticsPage)
+		if err != nil {
+			return imported, err
+		}
+		for _, ev := range events {
+			alias := strings.ToLower(ev.Alias)
+			if _, checked := known[alias]; !checked {
+				exists, err := store.Emails.Exists(alias)
+				if err != nil {
+					return imported, err
+				}
+				known[alias] = exists
+			}
+			if !known[alias] {
+				continue
+			}
+			ev.Alias = alias
+			ev.SenderDomain = models.SenderDomainOf(ev.Sender)
+			added, err := store.Deliveries.Record(ev)
+			if err != nil {
+				return imported, err
+			}
+			if added {
+				imported++
+			}
+		}
+
+		if len(events) < analyticsPage {
+			return imported, nil
+		}
+		last := events[len(events)-1].ReceivedAt
+		if !last.After(since) {
+			// Uma página inteira no mesmo instante: avança para não repetir para sempre
+			last = since.Add(time.Second)
+		}
+		since = last
+	}
+}
+
+// StartAnalytics importa as métricas da Cloudflare periodicamente em background
+func StartAnalytics(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, _ := PullAnalytics()
+			if n > 0 {
+				log.Printf("📊 %d entrega(s) importada(s) das métricas da Cloudflare", n)
+			}
+		}
+	}()
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"tempmail/internal/models"
+	"tempmail/internal/store"
+	"tempmail/internal/testutil"
+	"testing"
+	"time"
+)
+
+func TestPullAnalytics(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	owner := testutil.User(t, "ana", "segredo123", "user")
+	d := testutil.Domain(t, "exemplo.test")
+	e := testutil.Alias(t, models.EmailEntry{Email: "caixa@exemplo.test", OwnerID: owner.ID, DomainID: d.ID})
+
+	// Zona com Email Worker: as entregas já chegam pelo /api/inbound
+	w := testutil.Domain(t, "worker.test")
+	w.Worker = "tempmail-inbound"
+	if _, err := store.Domains.Save(w); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Alias(t, models.EmailEntry{Email: "caixa@worker.test", OwnerID: owner.ID, DomainID: w.ID})
+
+	cf.AddEmailEvent(d.ZoneID, models.Delivery{Alias: "Caixa@exemplo.test", Sender: "joao@Remetente.test", Status: models.DeliveryDelivered, ReceivedAt: time.Now().Add(-time.Minute)})
+	cf.AddEmailEvent(d.ZoneID, models.Delivery{Alias: "caixa@exemplo.test", Sender: `x@"><script>`, Status: models.DeliveryDelivered})
+	cf.AddEmailEvent(d.ZoneID, models.Delivery{Alias: "caixa@exemplo.test", Sender: "spam@lixo.test", Status: models.DeliveryRejected})
+	cf.AddEmailEvent(d.ZoneID, models.Delivery{Alias: "desconhecido@exemplo.test", Sender: "joao@remetente.test", Status: models.DeliveryDelivered})
+	cf.AddEmailEvent(w.ZoneID, models.Delivery{Alias: "caixa@worker.test", Sender: "joao@remetente.test", Status: models.DeliveryDelivered})
+
+	if n, err := PullAnalytics(); err != nil || n != 3 {
+		t.Fatalf("primeira importação: %d, %v (esperado 3)", n, err)
+	}
+	// Os eventos do último instante voltam na consulta seguinte e são descartados
+	if n, err := PullAnalytics(); err != nil || n != 0 {
+		t.Fatalf("segunda importação: %d, %v (esperado 0)", n, err)
+	}
+
+	list, err := store.Emails.ListByOwner(owner.ID, true)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ListByOwner: %+v, %v", list, err)
+	}
+	for _, got := range list {
+		switch got.ID {
+		case e.ID:
+			// A rejeitada não conta e o domínio inválido não vai para a tela
+			if got.MessageCount != 2 || len(got.SenderDomains) != 1 || got.SenderDomains[0] != "remetente.test" || got.LastReceivedAt == nil {
+				t.Errorf("estatísticas de %s: count=%d domains=%v last=%v", got.Email, got.MessageCount, got.SenderDomains, got.LastReceivedAt)
+			}
+		default:
+			if got.MessageCount != 0 || len(got.SenderDomains) != 0 || got.LastReceivedAt != nil {
+				t.Errorf("zona com Worker importada: %+v", got)
+			}
+		}
+	}
+}
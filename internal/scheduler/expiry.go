@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"tempmail/internal/config"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+	"time"
+)
+
+var (
+	activeTimers = make(map[string]*time.Timer)
+	timerMu      sync.Mutex
+)
+
+// Schedule grava o horário de expiração do alias e arma o timer correspondente
+func Schedule(id string, expiresAt time.Time) {
+	if err := store.Emails.SetExpiry(id, &expiresAt); err != nil {
+		log.Printf("Erro ao gravar expiração de %s: %v", id, err)
+	}
+	arm(id, expiresAt)
+}
+
+// Cancel desarma o timer do alias e limpa a expiração persistida (ex: ao fixar)
+func Cancel(id string) {
+	timerMu.Lock()
+	if t, ok := activeTimers[id]; ok {
+		t.Stop()
+		delete(activeTimers, id)
+	}
+	timerMu.Unlock()
+	if err := store.Emails.SetExpiry(id, nil); err != nil {
+		log.Printf("Erro ao limpar expiração de %s: %v", id, err)
+	}
+}
+
+// IsScheduled informa se existe um timer armado para o alias
+func IsScheduled(id string) bool {
+	timerMu.Lock()
+	defer timerMu.Unlock()
+	_, ok := activeTimers[id]
+	return ok
+}
+
+// Restore recarrega, no boot, todos os aliases ativos e não fixados:
+// os vencidos são expirados na hora e os demais têm o timer rearmado.
+func Restore() error {
+	aliases, err := store.Emails.ListActive()
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		id        string
+		expiresAt time.Time
+	}
+	var list []pending
+	for _, e := range aliases {
+		if e.Pinned {
+			continue
+		}
+		// Registros anteriores à coluna expires_at são calculados a partir da criação
+		p := pending{id: e.ID, expiresAt: e.CreatedAt.Add(TTLOf(e.TTL))}
+		if e.ExpiresAt != nil {
+			p.expiresAt = *e.ExpiresAt
+		}
+		list = append(list, p)
+	}
+
+	expired, rearmed := 0, 0
+	for _, p := range list {
+		if !p.expiresAt.After(time.Now()) {
+			expire(p.id)
+			expired++
+			continue
+		}
+		Schedule(p.id, p.expiresAt)
+		rearmed++
+	}
+	log.Printf("⏱️ Expiração restaurada: %d expirados, %d rearmados", expired, rearmed)
+	return nil
+}
+
+// StartSweeper expira periodicamente os aliases vencidos que não têm timer nesta instância
+// (ex: criados por outra réplica que saiu do ar antes de expirá-los)
+func StartSweeper(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			aliases, err := store.Emails.ListActive()
+			if err != nil {
+				log.Println("Erro ao varrer expirações:", err)
+				continue
+			}
+			now := time.Now()
+			for _, e := range aliases {
+				if e.Pinned || IsScheduled(e.ID) {
+					continue
+				}
+				due := e.CreatedAt.Add(TTLOf(e.TTL))
+				if e.ExpiresAt != nil {
+					due = *e.ExpiresAt
+				}
+				if !due.After(now) {
+					expire(e.ID)
+				}
+			}
+		}
+	}()
+}
+
+// TTLOf converte a coluna ttl (segundos) em duração, usando o padrão quando ausente
+func TTLOf(seconds int64) time.Duration {
+	if seconds <= 0 {
+		return config.GetDefaultTTL()
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func arm(id string, expiresAt time.Time) {
+	timerMu.Lock()
+	defer timerMu.Unlock()
+	if t, ok := activeTimers[id]; ok {
+		t.Stop()
+	}
+	activeTimers[id] = time.AfterFunc(time.Until(expiresAt), func() {
+		expire(id)
+	})
+}
+
+// expire remove a regra na Cloudflare e marca o alias como inativo
+func expire(id string) {
+	timerMu.Lock()
+	delete(activeTimers, id)
+	timerMu.Unlock()
+
+	// Com várias réplicas o alias pode ter sido fixado, removido ou renovado em outra
+	// instância depois que este timer foi armado: vale o que está no banco
+	e, err := store.Emails.Get(id)
+	if err != nil {
+		log.Printf("Erro ao expirar %s: %v", id, err)
+		return
+	}
+	if !e.Active || e.Pinned {
+		return
+	}
+	if e.ExpiresAt != nil && e.ExpiresAt.After(time.Now()) {
+		arm(id, *e.ExpiresAt)
+		return
+	}
+
+	if err := retire(e); err != nil {
+		log.Printf("Erro ao expirar %s: %v", id, err)
+	}
+}
+
+// ExpireNow expira o alias na hora, mesmo fixado ou com validade restante (ex: alias de uso
+// único que recebeu de outro remetente). false se ele já não estava ativo.
+func ExpireNow(id string) (bool, error) {
+	timerMu.Lock()
+	if t, ok := activeTimers[id]; ok {
+		t.Stop()
+		delete(activeTimers, id)
+	}
+	timerMu.Unlock()
+
+	e, err := store.Emails.Get(id)
+	if err != nil {
+		return false, err
+	}
+	if !e.Active {
+		return false, nil
+	}
+	return true, retire(e)
+}
+
+// retire remove a regra na Cloudflare e marca o alias como inativo
+func retire(e models.EmailEntry) error {
+	dom, err := store.Domains.Get(e.DomainID)
+	if err != nil {
+		return fmt.Errorf("domínio indisponível: %w", err)
+	}
+	// Se a remoção falhar, a regra fica órfã até a próxima reconciliação
+	if err := services.CF.DeleteRule(dom.Config(), e.ID); err != nil && !services.IsNotFound(err) {
+		log.Printf("Erro ao remover regra %s na Cloudflare: %v", e.ID, err)
+	}
+	return store.Emails.Deactivate(e.ID)
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"tempmail/internal/database"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/testutil"
+	"testing"
+	"time"
+)
+
+const dest = "dono@dest.test"
+
+// aliasWithRule cria a regra no FakeCloudflare e o alias correspondente no banco
+func aliasWithRule(t *testing.T, cf *services.FakeCloudflare, d models.Domain, email string, expiresAt time.Time, pinned bool) string {
+	t.Helper()
+	id, err := cf.CreateRule(d.Config(), email, []string{dest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = database.DB.Exec("INSERT INTO emails (id, email, destination, created_at, active, pinned, expires_at, domain_id, state) VALUES (?, ?, ?, ?, 1, ?, ?, ?, 'active')",
+		id, email, dest, time.Now(), pinned, expiresAt, d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func hasRule(cf *services.FakeCloudflare, zoneID, id string) bool {
+	for _, r := range cf.Rules(zoneID) {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func isActive(t *testing.T, id string) bool {
+	t.Helper()
+	var active bool
+	if err := database.DB.QueryRow("SELECT active FROM emails WHERE id = ?", id).Scan(&active); err != nil {
+		t.Fatal(err)
+	}
+	return active
+}
+
+func TestRestoreExpiresDueAliases(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	d := testutil.Domain(t, "exemplo.test")
+	cfg := d.Config()
+	testutil.Destination(t, cf, cfg, dest)
+
+	due := aliasWithRule(t, cf, d, "vencido@exemplo.test", time.Now().Add(-time.Minute), false)
+	later := aliasWithRule(t, cf, d, "depois@exemplo.test", time.Now().Add(time.Hour), false)
+	pinned := aliasWithRule(t, cf, d, "fixo@exemplo.test", time.Now().Add(-time.Minute), true)
+	t.Cleanup(func() { Cancel(later) })
+
+	if err := Restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	if hasRule(cf, cfg.ZoneID, due) || isActive(t, due) {
+		t.Error("alias vencido não foi expirado no boot")
+	}
+	if !hasRule(cf, cfg.ZoneID, later) || !isActive(t, later) || !IsScheduled(later) {
+		t.Error("alias com validade restante deveria continuar ativo com o timer armado")
+	}
+	if !hasRule(cf, cfg.ZoneID, pinned) || !isActive(t, pinned) || IsScheduled(pinned) {
+		t.Error("alias fixado não expira")
+	}
+}
+
+// A regra é apagada na zona do próprio alias, não na do domínio padrão
+func TestExpireRemovesRule(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	def := testutil.Domain(t, "exemplo.test")
+	d := testutil.Domain(t, "outro.test")
+	cfg := d.Config()
+	testutil.Destination(t, cf, cfg, dest)
+	testutil.Destination(t, cf, def.Config(), dest)
+	other := aliasWithRule(t, cf, def, "curto@exemplo.test", time.Now().Add(time.Hour), true)
+
+	id := aliasWithRule(t, cf, d, "curto@outro.test", time.Now().Add(50*time.Millisecond), false)
+	Schedule(id, time.Now().Add(50*time.Millisecond))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for isActive(t, id) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if isActive(t, id) || hasRule(cf, cfg.ZoneID, id) {
+		t.Fatal("o timer não expirou o alias")
+	}
+	if !hasRule(cf, def.ZoneID, other) {
+		t.Fatal("regra do domínio padrão apagada na expiração de outro domínio")
+	}
+}
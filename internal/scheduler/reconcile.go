@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"tempmail/internal/models"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+	"time"
+)
+
+// rulePrefix identifica as regras criadas por este sistema na Cloudflare
+const rulePrefix = "Temp: "
+
+var (
+	reconcileMu sync.Mutex
+	lastReport  *models.SyncReport
+)
+
+// Reconcile compara as regras de cada zona com a tabela emails: apaga regras "Temp: …"
+// sem alias ativo ou queimado (órfãs) e desativa aliases cuja regra não existe mais.
+// Uma zona que não responde entra nos erros e seus aliases ficam intocados.
+// Com dryRun, apenas reporta a divergência sem alterar nada.
+func Reconcile(dryRun bool) (models.SyncReport, error) {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+
+	report := models.SyncReport{
+		StartedAt:      time.Now(),
+		DryRun:         dryRun,
+		OrphansDeleted: []string{},
+		Vanished:       []string{},
+		Errors:         []string{},
+	}
+
+	domains, err := store.Domains.List()
+	if err != nil {
+		return report, err
+	}
+
+	// As regras são listadas antes de ler o banco: um alias criado no meio do caminho
+	// aparece no banco e não é confundido com órfão. No sentido contrário ele não está na
+	// lista da zona, por isso aliases criados depois do início não são dados como sumidos.
+	rules := make(map[int64][]models.RoutingRule) // domínio -> regras da zona
+	var firstErr error
+	for _, d := range domains {
+		list, err := services.CF.ListRules(d.Config())
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			report.Errors = append(report.Errors, d.Domain+": "+err.Error())
+			continue
+		}
+		rules[d.ID] = list
+		report.RulesSeen += len(list)
+	}
+	if len(rules) == 0 && firstErr != nil {
+		return report, firstErr
+	}
+
+	aliases, err := store.Emails.ListWithRule()
+	if err != nil {
+		return report, err
+	}
+	owned := make(map[string]bool)
+	for _, e := range aliases {
+		owned[e.ID] = true
+	}
+
+	inCloudflare := make(map[string]bool)
+	for _, d := range domains {
+		for _, r := range rules[d.ID] {
+			inCloudflare[r.ID] = true
+			if owned[r.ID] || !strings.HasPrefix(r.Name, rulePrefix) {
+				continue
+			}
+			// Confere de novo para não apagar uma regra recém-criada
+			if stillOwned, err := store.Emails.HasRule(r.ID); err != nil || stillOwned {
+				continue
+			}
+
+			label := strings.TrimPrefix(r.Name, rulePrefix)
+			if !dryRun {
+				if err := services.CF.DeleteRule(d.Config(), r.ID); err != nil {
+					report.Errors = append(report.Errors, label+": "+err.Error())
+					continue
+				}
+			}
+			report.OrphansDeleted = append(report.OrphansDeleted, label)
+		}
+	}
+
+	for _, e := range aliases {
+		// Sem a lista da zona não dá para afirmar que a regra sumiu
+		if _, listed := rules[e.DomainID]; !listed || inCloudflare[e.ID] || !e.CreatedAt.Before(report.StartedAt) {
+			continue
+		}
+		if !dryRun {
+			Cancel(e.ID)
+			if err := store.Emails.Deactivate(e.ID); err != nil {
+				report.Errors = append(report.Errors, e.Email+": "+err.Error())
+				continue
+			}
+		}
+		report.Vanished = append(report.Vanished, e.Email)
+	}
+
+	report.FinishedAt = time.Now()
+	if !dryRun {
+		lastReport = &report
+	}
+	return report, nil
+}
+
+// LastReport devolve o resultado da última reconciliação efetiva (nil se nunca rodou)
+func LastReport() *models.SyncReport {
+	reconcileMu.Lock()
+	defer reconcileMu.Unlock()
+	return lastReport
+}
+
+// StartReconciler roda a reconciliação periodicamente em background
+func StartReconciler(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := store.Domains.Default(); err != nil {
+				continue
+			}
+			report, err := Reconcile(false)
+			if err != nil {
+				log.Println("Erro na reconciliação:", err)
+				continue
+			}
+			if len(report.OrphansDeleted) > 0 || len(report.Vanished) > 0 || len(report.Errors) > 0 {
+				log.Printf("🔄 Reconciliação: %d órfãs removidas, %d aliases desativados, %d erros",
+					len(report.OrphansDeleted), len(report.Vanished), len(report.Errors))
+			}
+		}
+	}()
+}
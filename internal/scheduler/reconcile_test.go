@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"tempmail/internal/database"
+	"tempmail/internal/testutil"
+	"testing"
+	"time"
+)
+
+func TestReconcile(t *testing.T) {
+	testutil.DB(t)
+	cf := testutil.Cloudflare(t)
+	d := testutil.Domain(t, "exemplo.test")
+	cfg := d.Config()
+	testutil.Destination(t, cf, cfg, dest)
+
+	live := aliasWithRule(t, cf, d, "vivo@exemplo.test", time.Now().Add(time.Hour), true)
+
+	// Regra "Temp: …" sem alias no banco: órfã
+	orphan, err := cf.CreateRule(cfg, "orfao@exemplo.test", []string{dest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Alias cuja regra foi apagada direto no painel da Cloudflare
+	gone := aliasWithRule(t, cf, d, "sumido@exemplo.test", time.Now().Add(time.Hour), true)
+	if err := cf.DeleteRule(cfg, gone); err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Exec("UPDATE emails SET created_at = ? WHERE id = ?", time.Now().Add(-time.Hour), gone)
+	// Alias gravado depois que as regras foram listadas (criação concorrente)
+	_, err = database.DB.Exec("INSERT INTO emails (id, email, destination, created_at, active, pinned, domain_id, state) VALUES ('regra-nova', 'novo@exemplo.test', ?, ?, 1, 1, ?, 'active')",
+		dest, time.Now().Add(time.Minute), d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Reconcile(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.OrphansDeleted) != 1 || len(report.Vanished) != 1 || !hasRule(cf, cfg.ZoneID, orphan) {
+		t.Fatalf("simulação: %+v", report)
+	}
+	if !isActive(t, gone) {
+		t.Fatal("a simulação não pode alterar o banco")
+	}
+
+	report, err = Reconcile(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.OrphansDeleted) != 1 || report.OrphansDeleted[0] != "orfao@exemplo.test" {
+		t.Errorf("órfãs: %v", report.OrphansDeleted)
+	}
+	if len(report.Vanished) != 1 || report.Vanished[0] != "sumido@exemplo.test" {
+		t.Errorf("sumidos: %v", report.Vanished)
+	}
+	if hasRule(cf, cfg.ZoneID, orphan) || !hasRule(cf, cfg.ZoneID, live) {
+		t.Errorf("regras após a reconciliação: %+v", cf.Rules(cfg.ZoneID))
+	}
+	for id, want := range map[string]bool{live: true, gone: false, "regra-nova": true} {
+		if got := isActive(t, id); got != want {
+			t.Errorf("%s: active=%v, esperado %v", id, got, want)
+		}
+	}
+}
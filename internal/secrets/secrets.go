@@ -0,0 +1,188 @@
+// Package secrets cifra os segredos guardados no banco (token da Cloudflare, segredos TOTP)
+// com envelope: cada valor recebe uma chave de dados aleatória (AES-256-GCM) e essa chave é
+// cifrada com a chave mestra, que fica fora do banco (variável de ambiente ou arquivo).
+// Uma cópia do data.db sozinha não revela nada.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Formato gravado: "enc:v1:<id da chave>:" + base64(nonce | chave de dados cifrada | nonce | valor cifrado).
+// O id (início do SHA-256 da chave mestra) permite avisar quando o valor foi cifrado com outra chave.
+const prefix = "enc:v1:"
+
+// KeySize é o tamanho da chave mestra (AES-256)
+const KeySize = 32
+
+var (
+	// ErrNoKey indica que a chave mestra ainda não foi carregada
+	ErrNoKey = errors.New("chave mestra não configurada")
+	// ErrWrongKey indica que o valor foi cifrado com outra chave mestra
+	ErrWrongKey = errors.New("segredo cifrado com outra chave mestra")
+)
+
+var masterKey []byte
+
+// SetMasterKey define a chave mestra usada por Encrypt e Decrypt
+func SetMasterKey(key []byte) error {
+	if len(key) != KeySize {
+		return fmt.Errorf("a chave mestra deve ter %d bytes, tem %d", KeySize, len(key))
+	}
+	masterKey = key
+	return nil
+}
+
+// ParseKey aceita a chave em base64 (padrão ou URL) ou em hexadecimal
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, dec := range []func(string) ([]byte, error){
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+		hex.DecodeString,
+	} {
+		if key, err := dec(s); err == nil && len(key) == KeySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("a chave mestra deve ter %d bytes em base64 ou hexadecimal", KeySize)
+}
+
+// GenerateKey cria uma chave mestra aleatória em base64
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// IsEncrypted indica se o valor já está no formato cifrado
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt cifra o valor com a chave mestra. Vazio continua vazio (não há o que proteger).
+func Encrypt(plain string) (string, error) {
+	return EncryptWith(masterKey, plain)
+}
+
+// EncryptWith cifra o valor com a chave mestra informada (usado na rotação)
+func EncryptWith(key []byte, plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	if key == nil {
+		return "", ErrNoKey
+	}
+
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(key, dataKey)
+	if err != nil {
+		return "", err
+	}
+	body, err := seal(dataKey, []byte(plain))
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + keyID(key) + ":" + base64.StdEncoding.EncodeToString(append(wrapped, body...)), nil
+}
+
+// Decrypt abre um valor cifrado com a chave mestra. Valores em texto puro (gravados antes
+// da cifragem) são devolvidos como estão.
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if masterKey == nil {
+		return "", ErrNoKey
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", errors.New("segredo cifrado malformado")
+	}
+	if id != keyID(masterKey) {
+		return "", ErrWrongKey
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.New("segredo cifrado malformado")
+	}
+
+	// A chave de dados cifrada ocupa nonce + chave + tag
+	wrappedLen := gcmNonceSize + KeySize + gcmTagSize
+	if len(raw) < wrappedLen {
+		return "", errors.New("segredo cifrado malformado")
+	}
+	dataKey, err := open(masterKey, raw[:wrappedLen])
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(dataKey, raw[wrappedLen:])
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+const (
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+)
+
+// seal cifra com AES-GCM e devolve nonce | texto cifrado
+func seal(key, plain []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("segredo cifrado malformado")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("segredo cifrado adulterado ou chave incorreta")
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyID identifica a chave mestra sem revelá-la
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
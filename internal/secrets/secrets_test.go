@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
+
+func useKey(t *testing.T, key []byte) {
+	t.Helper()
+	prev := masterKey
+	if err := SetMasterKey(key); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { masterKey = prev })
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	useKey(t, testKey(1))
+
+	sealed, err := Encrypt("token-secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "token-secreto") {
+		t.Fatalf("valor cifrado expõe o texto: %q", sealed)
+	}
+	if again, _ := Encrypt("token-secreto"); again == sealed {
+		t.Fatal("duas cifragens do mesmo valor deram o mesmo resultado")
+	}
+	if plain, err := Decrypt(sealed); err != nil || plain != "token-secreto" {
+		t.Fatalf("Decrypt: %q, %v", plain, err)
+	}
+
+	// Vazio continua vazio e texto puro antigo passa direto
+	if sealed, err := Encrypt(""); err != nil || sealed != "" {
+		t.Fatalf("Encrypt vazio: %q, %v", sealed, err)
+	}
+	if plain, err := Decrypt("texto-puro"); err != nil || plain != "texto-puro" {
+		t.Fatalf("Decrypt de texto puro: %q, %v", plain, err)
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	sealed, err := EncryptWith(testKey(1), "token-secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useKey(t, testKey(2))
+	if _, err := Decrypt(sealed); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("Decrypt com outra chave: %v, esperado ErrWrongKey", err)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	useKey(t, testKey(1))
+	sealed, err := Encrypt("token-secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head := sealed[:strings.LastIndex(sealed, ":")+1]
+	raw, err := base64.StdEncoding.DecodeString(sealed[len(head):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pos := range []int{0, gcmNonceSize + 1, len(raw) - 1} {
+		tampered := append([]byte(nil), raw...)
+		tampered[pos] ^= 0x01
+		if _, err := Decrypt(head + base64.StdEncoding.EncodeToString(tampered)); err == nil {
+			t.Errorf("byte %d alterado e o valor ainda abriu", pos)
+		}
+	}
+	if _, err := Decrypt(head + base64.StdEncoding.EncodeToString(raw[:10])); err == nil {
+		t.Error("valor truncado abriu")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	if err := SetMasterKey(make([]byte, 16)); err == nil {
+		t.Fatal("chave de 16 bytes aceita")
+	}
+
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(encoded)
+	if err != nil || len(key) != KeySize {
+		t.Fatalf("ParseKey da chave gerada: %v", err)
+	}
+	if _, err := ParseKey(strings.Repeat("ab", KeySize)); err != nil {
+		t.Fatalf("chave em hexadecimal: %v", err)
+	}
+	if _, err := ParseKey("curta"); err == nil {
+		t.Fatal("chave inválida aceita")
+	}
+}
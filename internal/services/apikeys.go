@@ -0,0 +1,39 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix identifica tokens que são chaves de API (e não JWT)
+const APIKeyPrefix = "tm_"
+
+// GenerateAPIKey cria uma nova chave e devolve o segredo completo, o prefixo exibível e o hash a ser salvo
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 24)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	secret := hex.EncodeToString(b)
+	key = APIKeyPrefix + secret
+	return key, key[:len(APIKeyPrefix)+8], HashAPIKey(key), nil
+}
+
+// HashAPIKey gera o hash guardado no banco. SHA-256 basta: o segredo é aleatório e longo,
+// e a verificação roda a cada requisição (bcrypt seria lento demais aqui).
+func HashAPIKey(key string) string {
+	return hashSecret(key)
+}
+
+// hashSecret gera o SHA-256 (hex) de segredos aleatórios guardados no banco
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey indica se o token recebido tem o formato de chave de API
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
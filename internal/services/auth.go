@@ -1,8 +1,12 @@
 package services
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"os"
+	"tempmail/internal/store"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -21,26 +25,102 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func GenerateToken(username string) (string, error) {
-	expirationTime := time.Now().Add(15 * time.Minute)
+// AccessTokenTTL é a validade do JWT de acesso; a sessão é estendida via refresh token
+const AccessTokenTTL = 15 * time.Minute
+
+// GenerateToken emite o JWT de acesso vinculado à sessão (claim jti)
+func GenerateToken(username, sessionID string) (string, error) {
+	now := time.Now()
 	claims := &jwt.RegisteredClaims{
 		Subject:   username,
-		ExpiresAt: jwt.NewNumericDate(expirationTime),
+		ID:        sessionID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtKey)
 }
 
-func ValidateToken(tokenString string) (string, error) {
+// ValidateToken confere assinatura e validade do JWT e rejeita tokens cuja sessão foi
+// revogada ou que foram emitidos antes da última troca de senha.
+// Retorna o username e o ID da sessão.
+func ValidateToken(tokenString string) (string, string, error) {
 	claims := &jwt.RegisteredClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return jwtKey, nil
 	})
 
-	if err != nil || !token.Valid {
-		return "", errors.New("token inválido")
+	if err != nil || !token.Valid || claims.ID == "" || isMFAToken(claims) {
+		return "", "", errors.New("token inválido")
+	}
+
+	session, err := store.Sessions.Get(claims.ID)
+	if err != nil || session.Revoked || time.Now().After(session.ExpiresAt) {
+		return "", "", errors.New("sessão encerrada")
+	}
+
+	changedAt, err := store.Users.PasswordChangedAt(claims.Subject)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return "", "", fmt.Errorf("erro ao validar sessão: %w", err)
+	}
+	if changedAt != nil {
+		if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(changedAt.Truncate(time.Second)) {
+			return "", "", errors.New("senha alterada após a emissão do token")
+		}
+	}
+
+	return claims.Subject, claims.ID, nil
+}
+
+// GenerateRefreshToken cria um refresh token opaco e o hash a ser guardado na sessão
+func GenerateRefreshToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
+}
+
+func HashRefreshToken(token string) string {
+	return hashSecret(token)
+}
+
+// mfaAudience marca o token intermediário do login em duas etapas
+const mfaAudience = "mfa"
+
+// GenerateMFAToken emite o token curto que autoriza apenas a segunda etapa do login
+func GenerateMFAToken(username string) (string, error) {
+	now := time.Now()
+	claims := &jwt.RegisteredClaims{
+		Subject:   username,
+		Audience:  jwt.ClaimStrings{mfaAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
 	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtKey)
+}
+
+// ValidateMFAToken confere o token da segunda etapa e devolve o username
+func ValidateMFAToken(tokenString string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	}, jwt.WithAudience(mfaAudience))
 
+	if err != nil || !token.Valid {
+		return "", errors.New("desafio de 2FA inválido ou expirado")
+	}
 	return claims.Subject, nil
-}
\ No newline at end of file
+}
+
+func isMFAToken(claims *jwt.RegisteredClaims) bool {
+	for _, aud := range claims.Audience {
+		if aud == mfaAudience {
+			return true
+		}
+	}
+	return false
+}
@@ -1,152 +1,218 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"tempmail/internal/models"
+	"time"
 )
 
-func CfCreateRule(cfg models.Config, email, destination string) (string, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/email/routing/rules", cfg.ZoneID)
-	payload := map[string]interface{}{
+// DefaultCloudflareURL é a base da API v4 da Cloudflare
+const DefaultCloudflareURL = "https://api.cloudflare.com/client/v4"
+
+// CloudflareClient reúne as operações de Email Routing usadas pelo sistema
+type CloudflareClient interface {
+	// CreateRule cria a regra que encaminha o endereço para todos os destinos (ou para o
+	// Email Worker, se cfg.Worker estiver configurado)
+	CreateRule(cfg models.Config, email string, destinations []string) (string, error)
+	DeleteRule(cfg models.Config, id string) error
+	// UpdateRule troca a ação da regra: models.ActionForward (para destinations, ou o Worker
+	// da zona) ou models.ActionDrop
+	UpdateRule(cfg models.Config, id, email, action string, destinations []string) error
+	ListRules(cfg models.Config) ([]models.RoutingRule, error)
+	GetAccountID(cfg models.Config) (string, error)
+	GetVerifiedDestinations(cfg models.Config, accountID string) ([]models.Destination, error)
+	CreateDestination(cfg models.Config, accountID, email string) error
+	DeleteDestination(cfg models.Config, accountID, destID string) error
+	GetCatchAll(cfg models.Config) (models.CatchAll, error)
+	SetCatchAll(cfg models.Config, rule models.CatchAll) error
+	// EmailEvents lê até limit eventos de entrega da zona a partir de since (API GraphQL de
+	// métricas; o token precisa da permissão Analytics Read)
+	EmailEvents(cfg models.Config, since time.Time, limit int) ([]models.Delivery, error)
+}
+
+// CF é o cliente usado pelos handlers; trocado no boot (URL customizada ou modo demo)
+var CF CloudflareClient = NewCloudflare(DefaultCloudflareURL, nil)
+
+// HTTPCloudflare fala com a API real (ou com qualquer servidor compatível em baseURL).
+// Todas as chamadas passam por call: tempo limite, novas tentativas e erros tipados (CFError).
+type HTTPCloudflare struct {
+	baseURL    string
+	graphqlURL string
+	client     *http.Client
+}
+
+// NewCloudflare cria o cliente; sem client usa NewHTTPClient com o tempo limite padrão
+func NewCloudflare(baseURL string, client *http.Client) *HTTPCloudflare {
+	if client == nil {
+		client = NewHTTPClient(DefaultCloudflareTimeout)
+	}
+	return &HTTPCloudflare{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+// ruleAction troca forward por worker nas zonas roteadas para um Email Worker: quem entrega
+// aos destinos passa a ser o sistema (via /api/inbound)
+func ruleAction(cfg models.Config, action string) string {
+	if action == models.ActionForward && cfg.Worker != "" {
+		return models.ActionWorker
+	}
+	return action
+}
+
+// rulePayload monta o corpo de uma regra "Temp: " para o endereço
+func rulePayload(cfg models.Config, email, action string, destinations []string) map[string]interface{} {
+	action = ruleAction(cfg, action)
+	act := map[string]interface{}{"type": action}
+	switch action {
+	case models.ActionForward:
+		act["value"] = destinations
+	case models.ActionWorker:
+		act["value"] = []string{cfg.Worker}
+	}
+	return map[string]interface{}{
 		"enabled": true, "name": "Temp: " + email,
 		"matchers": []interface{}{map[string]string{"type": "literal", "field": "to", "value": email}},
-		"actions":  []interface{}{map[string]interface{}{"type": "forward", "value": []string{destination}}},
+		"actions":  []interface{}{act},
 	}
-	body, _ := json.Marshal(payload)
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	req.Header.Set("Authorization", "Bearer "+cfg.CFToken)
-	req.Header.Set("Content-Type", "application/json")
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+func (c *HTTPCloudflare) CreateRule(cfg models.Config, email string, destinations []string) (string, error) {
+	payload := rulePayload(cfg, email, models.ActionForward, destinations)
+	var result struct {
+		ID string `json:"id"`
+	}
+	if _, err := c.call(cfg, "POST", fmt.Sprintf("/zones/%s/email/routing/rules", cfg.ZoneID), payload, &result, "erro ao criar regra"); err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	return result.ID, nil
+}
 
-	var res struct {
-		Success bool `json:"success"`
-		Result  struct {
-			ID string `json:"id"`
-		} `json:"result"`
-		Errors []struct {
-			Message string `json:"message"`
-		} `json:"errors"`
-	}
-	json.NewDecoder(resp.Body).Decode(&res)
-	if !res.Success {
-		if len(res.Errors) > 0 {
-			return "", fmt.Errorf("%s", res.Errors[0].Message)
-		}
-		return "", fmt.Errorf("erro CF create rule")
-	}
-	return res.Result.ID, nil
+func (c *HTTPCloudflare) UpdateRule(cfg models.Config, id, email, action string, destinations []string) error {
+	payload := rulePayload(cfg, email, action, destinations)
+	_, err := c.call(cfg, "PUT", fmt.Sprintf("/zones/%s/email/routing/rules/%s", cfg.ZoneID, id), payload, nil, "erro ao atualizar regra")
+	return err
 }
 
-func CfDeleteRule(cfg models.Config, id string) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s/email/routing/rules/%s", cfg.ZoneID, id)
-	req, _ := http.NewRequest("DELETE", url, nil)
-	req.Header.Set("Authorization", "Bearer "+cfg.CFToken)
-	req.Header.Set("Content-Type", "application/json")
-	http.DefaultClient.Do(req)
+func (c *HTTPCloudflare) DeleteRule(cfg models.Config, id string) error {
+	_, err := c.call(cfg, "DELETE", fmt.Sprintf("/zones/%s/email/routing/rules/%s", cfg.ZoneID, id), nil, nil, "erro ao deletar regra")
+	return err
 }
 
-func CfGetAccountID(cfg models.Config) (string, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/zones/%s", cfg.ZoneID)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "Bearer "+cfg.CFToken)
+// ListRules percorre todas as páginas de regras da zona
+func (c *HTTPCloudflare) ListRules(cfg models.Config) ([]models.RoutingRule, error) {
+	var rules []models.RoutingRule
+	for page := 1; ; page++ {
+		var result []struct {
+			ID       string `json:"id"`
+			Name     string `json:"name"`
+			Enabled  bool   `json:"enabled"`
+			Matchers []struct {
+				Type  string `json:"type"`
+				Field string `json:"field"`
+				Value string `json:"value"`
+			} `json:"matchers"`
+		}
+		res, err := c.call(cfg, "GET", fmt.Sprintf("/zones/%s/email/routing/rules?page=%d&per_page=50", cfg.ZoneID, page), nil, &result, fmt.Sprintf("erro ao listar regras (página %d)", page))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range result {
+			rule := models.RoutingRule{ID: r.ID, Name: r.Name, Enabled: r.Enabled}
+			for _, m := range r.Matchers {
+				if m.Type == "literal" && m.Field == "to" {
+					rule.Email = m.Value
+				}
+			}
+			rules = append(rules, rule)
+		}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
+		if len(result) == 0 || page >= res.ResultInfo.TotalPages {
+			return rules, nil
+		}
 	}
-	defer resp.Body.Close()
-
-	var res struct {
-		Success bool `json:"success"`
-		Result  struct {
-			Account struct {
-				ID string `json:"id"`
-			} `json:"account"`
-		} `json:"result"`
+}
+
+func (c *HTTPCloudflare) GetAccountID(cfg models.Config) (string, error) {
+	var result struct {
+		Account struct {
+			ID string `json:"id"`
+		} `json:"account"`
+	}
+	if _, err := c.call(cfg, "GET", fmt.Sprintf("/zones/%s", cfg.ZoneID), nil, &result, "não foi possível obter Account ID"); err != nil {
+		return "", err
 	}
-	json.NewDecoder(resp.Body).Decode(&res)
-	if !res.Success || res.Result.Account.ID == "" {
+	if result.Account.ID == "" {
 		return "", fmt.Errorf("não foi possível obter Account ID")
 	}
-	return res.Result.Account.ID, nil
+	return result.Account.ID, nil
 }
 
-func CfGetVerifiedDestinations(cfg models.Config, accountID string) ([]models.Destination, error) {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/email/routing/addresses", accountID)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "Bearer "+cfg.CFToken)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+func (c *HTTPCloudflare) GetVerifiedDestinations(cfg models.Config, accountID string) ([]models.Destination, error) {
+	result := []models.Destination{}
+	if _, err := c.call(cfg, "GET", fmt.Sprintf("/accounts/%s/email/routing/addresses", accountID), nil, &result, "erro ao listar emails"); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var res struct {
-		Success bool `json:"success"`
-		Result  []models.Destination `json:"result"`
-	}
-	json.NewDecoder(resp.Body).Decode(&res)
-	if !res.Success {
-		return nil, fmt.Errorf("erro ao listar emails")
-	}
-	return res.Result, nil
+	return result, nil
 }
 
-func CfCreateDestination(cfg models.Config, accountID, email string) error {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/email/routing/addresses", accountID)
+func (c *HTTPCloudflare) CreateDestination(cfg models.Config, accountID, email string) error {
 	payload := map[string]string{"email": email}
-	body, _ := json.Marshal(payload)
+	_, err := c.call(cfg, "POST", fmt.Sprintf("/accounts/%s/email/routing/addresses", accountID), payload, nil, "erro ao adicionar destino")
+	return err
+}
 
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	req.Header.Set("Authorization", "Bearer "+cfg.CFToken)
-	req.Header.Set("Content-Type", "application/json")
+func (c *HTTPCloudflare) DeleteDestination(cfg models.Config, accountID, destID string) error {
+	_, err := c.call(cfg, "DELETE", fmt.Sprintf("/accounts/%s/email/routing/addresses/%s", accountID, destID), nil, nil, "erro ao deletar")
+	return err
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+// cfCatchAll é o formato da regra pega-tudo na API
+type cfCatchAll struct {
+	Enabled  bool                `json:"enabled"`
+	Name     string              `json:"name,omitempty"`
+	Matchers []map[string]string `json:"matchers"`
+	Actions  []struct {
+		Type  string   `json:"type"`
+		Value []string `json:"value,omitempty"`
+	} `json:"actions"`
+}
 
-	var res struct {
-		Success bool `json:"success"`
-		Errors  []struct {
-			Message string `json:"message"`
-		} `json:"errors"`
+func (c *HTTPCloudflare) GetCatchAll(cfg models.Config) (models.CatchAll, error) {
+	var result cfCatchAll
+	if _, err := c.call(cfg, "GET", fmt.Sprintf("/zones/%s/email/routing/rules/catch_all", cfg.ZoneID), nil, &result, "erro ao ler catch-all"); err != nil {
+		return models.CatchAll{}, err
 	}
-	json.NewDecoder(resp.Body).Decode(&res)
 
-	if !res.Success {
-		if len(res.Errors) > 0 {
-			return fmt.Errorf("%s", res.Errors[0].Message)
+	rule := models.CatchAll{Domain: cfg.Domain, Action: models.CatchAllDisabled}
+	if result.Enabled && len(result.Actions) > 0 {
+		a := result.Actions[0]
+		rule.Action = a.Type
+		if len(a.Value) > 0 {
+			rule.Destination = a.Value[0]
 		}
-		return fmt.Errorf("erro ao adicionar destino")
 	}
-	return nil
+	return rule, nil
 }
 
-func CfDeleteDestination(cfg models.Config, accountID, destID string) error {
-	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/email/routing/addresses/%s", accountID, destID)
-	req, _ := http.NewRequest("DELETE", url, nil)
-	req.Header.Set("Authorization", "Bearer "+cfg.CFToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+func (c *HTTPCloudflare) SetCatchAll(cfg models.Config, rule models.CatchAll) error {
+	payload := cfCatchAll{
+		Enabled:  rule.Action != models.CatchAllDisabled,
+		Name:     "Catch-all",
+		Matchers: []map[string]string{{"type": "all"}},
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("erro ao deletar (status %d)", resp.StatusCode)
+	// A API exige uma ação mesmo com a regra desligada
+	action := struct {
+		Type  string   `json:"type"`
+		Value []string `json:"value,omitempty"`
+	}{Type: models.CatchAllDrop}
+	if rule.Action == models.CatchAllForward {
+		action.Type = models.CatchAllForward
+		action.Value = []string{rule.Destination}
 	}
-	return nil
-}
\ No newline at end of file
+	payload.Actions = append(payload.Actions, action)
+
+	_, err := c.call(cfg, "PUT", fmt.Sprintf("/zones/%s/email/routing/rules/catch_all", cfg.ZoneID), payload, nil, "erro ao atualizar catch-all")
+	return err
+}
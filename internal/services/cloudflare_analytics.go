@@ -0,0 +1,159 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"tempmail/internal/models"
+	"time"
+)
+
+// emailEventsQuery lê os eventos de Email Routing da zona (dataset emailRoutingAdaptive),
+// do mais antigo para o mais recente a partir de since
+const emailEventsQuery = `query EmailEvents($zoneTag: string!, $since: Time!, $limit: uint64!) {
+  viewer {
+    zones(filter: {zoneTag: $zoneTag}) {
+      emailRoutingAdaptive(filter: {datetime_geq: $since}, limit: $limit, orderBy: [datetime_ASC]) {
+        datetime
+        from
+        to
+        status
+        sessionId
+      }
+    }
+  }
+}`
+
+// SetGraphQLURL troca o endpoint da API de métricas (padrão: <baseURL>/graphql)
+func (c *HTTPCloudflare) SetGraphQLURL(url string) {
+	c.graphqlURL = strings.TrimRight(url, "/")
+}
+
+func (c *HTTPCloudflare) EmailEvents(cfg models.Config, since time.Time, limit int) ([]models.Delivery, error) {
+	var data struct {
+		Viewer struct {
+			Zones []struct {
+				Events []struct {
+					Datetime  time.Time `json:"datetime"`
+					From      string    `json:"from"`
+					To        string    `json:"to"`
+					Status    string    `json:"status"`
+					SessionID string    `json:"sessionId"`
+				} `json:"emailRoutingAdaptive"`
+			} `json:"zones"`
+		} `json:"viewer"`
+	}
+	vars := map[string]interface{}{"zoneTag": cfg.ZoneID, "since": since.UTC().Format(time.RFC3339), "limit": limit}
+	if err := c.graphql(cfg, emailEventsQuery, vars, &data, "erro ao ler métricas de Email Routing"); err != nil {
+		return nil, err
+	}
+
+	events := []models.Delivery{}
+	for _, z := range data.Viewer.Zones {
+		for _, ev := range z.Events {
+			to := strings.ToLower(ev.To)
+			id := ev.SessionID
+			if id == "" {
+				id = ev.Datetime.UTC().Format(time.RFC3339Nano) + "/" + strings.ToLower(ev.From)
+			}
+			events = append(events, models.Delivery{
+				Alias:      to,
+				Sender:     strings.ToLower(ev.From),
+				Status:     deliveryStatus(ev.Status),
+				Source:     models.SourceCloudflare,
+				ExternalID: "cf:" + id + "/" + to,
+				ReceivedAt: ev.Datetime,
+			})
+		}
+	}
+	return events, nil
+}
+
+// deliveryStatus normaliza a situação do evento; as desconhecidas seguem como vieram
+func deliveryStatus(status string) string {
+	switch strings.ToLower(status) {
+	case "delivered", "forwarded":
+		return models.DeliveryDelivered
+	case "dropped":
+		return models.DeliveryDropped
+	case "rejected":
+		return models.DeliveryRejected
+	}
+	return strings.ToLower(status)
+}
+
+// graphql executa a consulta com a mesma política de novas tentativas da API v4 (consultas
+// não alteram nada, então falhas de rede e 5xx também são repetidas)
+func (c *HTTPCloudflare) graphql(cfg models.Config, query string, vars map[string]interface{}, out interface{}, what string) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	url := c.graphqlURL
+	if url == "" {
+		url = c.baseURL + "/graphql"
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, wait, err := c.graphqlAttempt(cfg, url, body, out, what)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= maxRetries {
+			return err
+		}
+		if wait == 0 {
+			wait = backoff(attempt)
+		}
+		time.Sleep(wait)
+	}
+}
+
+func (c *HTTPCloudflare) graphqlAttempt(cfg models.Config, url string, body []byte, out interface{}, what string) (retry bool, wait time.Duration, err error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.CFToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return true, 0, err
+	}
+	defer resp.Body.Close()
+
+	var parsed struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&parsed)
+
+	// Erros de consulta (ex: token sem permissão de Analytics) vêm com status 200
+	if decodeErr == nil && resp.StatusCode < 300 && len(parsed.Errors) == 0 {
+		if err := json.Unmarshal(parsed.Data, out); err != nil {
+			return false, 0, fmt.Errorf("%s: resposta inválida da Cloudflare", what)
+		}
+		return false, 0, nil
+	}
+
+	cfErr := &CFError{Status: resp.StatusCode, Message: fmt.Sprintf("%s (status %d)", what, resp.StatusCode)}
+	if len(parsed.Errors) > 0 {
+		cfErr.Message = what + ": " + parsed.Errors[0].Message
+		if resp.StatusCode < 300 {
+			cfErr.Status = http.StatusBadRequest
+		}
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		cfErr.RetryAfter = retryAfter(resp.Header.Get("Retry-After"))
+		return cfErr.RetryAfter <= maxRetryAfter, cfErr.RetryAfter, cfErr
+	case resp.StatusCode >= 500:
+		return true, 0, cfErr
+	}
+	return false, 0, cfErr
+}
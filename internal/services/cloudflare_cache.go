@@ -0,0 +1,73 @@
+package services
+
+import (
+	"sync"
+	"tempmail/internal/models"
+	"time"
+)
+
+// CachedCloudflare guarda por ttl a lista de destinos de cada conta, que a UI pede a cada
+// modal aberto. Adicionar ou remover um destino descarta a lista da conta na hora.
+// As demais chamadas vão direto para o cliente embutido.
+type CachedCloudflare struct {
+	CloudflareClient
+
+	ttl          time.Duration
+	mu           sync.Mutex
+	destinations map[string]cachedDestinations // accountID -> lista
+}
+
+type cachedDestinations struct {
+	list    []models.Destination
+	expires time.Time
+}
+
+func NewCachedCloudflare(inner CloudflareClient, ttl time.Duration) *CachedCloudflare {
+	return &CachedCloudflare{
+		CloudflareClient: inner,
+		ttl:              ttl,
+		destinations:     make(map[string]cachedDestinations),
+	}
+}
+
+func (c *CachedCloudflare) GetVerifiedDestinations(cfg models.Config, accountID string) ([]models.Destination, error) {
+	c.mu.Lock()
+	cached, ok := c.destinations[accountID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return append([]models.Destination(nil), cached.list...), nil
+	}
+
+	list, err := c.CloudflareClient.GetVerifiedDestinations(cfg, accountID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.destinations[accountID] = cachedDestinations{list: list, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return append([]models.Destination(nil), list...), nil
+}
+
+func (c *CachedCloudflare) CreateDestination(cfg models.Config, accountID, email string) error {
+	defer c.Forget(accountID)
+	return c.CloudflareClient.CreateDestination(cfg, accountID, email)
+}
+
+func (c *CachedCloudflare) DeleteDestination(cfg models.Config, accountID, destID string) error {
+	defer c.Forget(accountID)
+	return c.CloudflareClient.DeleteDestination(cfg, accountID, destID)
+}
+
+// Forget descarta a lista de destinos da conta (ex: o destino foi confirmado pelo email)
+func (c *CachedCloudflare) Forget(accountID string) {
+	c.mu.Lock()
+	delete(c.destinations, accountID)
+	c.mu.Unlock()
+}
+
+// ForgetDestinations descarta a lista em cache da conta quando CF usa cache
+func ForgetDestinations(accountID string) {
+	if cached, ok := CF.(*CachedCloudflare); ok {
+		cached.Forget(accountID)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"tempmail/internal/models"
+	"testing"
+	"time"
+)
+
+// countingCloudflare conta as listagens de destinos que chegam ao cliente de verdade
+type countingCloudflare struct {
+	*FakeCloudflare
+	lists int
+	fail  error
+}
+
+func (c *countingCloudflare) GetVerifiedDestinations(cfg models.Config, accountID string) ([]models.Destination, error) {
+	c.lists++
+	if c.fail != nil {
+		return nil, c.fail
+	}
+	return c.FakeCloudflare.GetVerifiedDestinations(cfg, accountID)
+}
+
+func verified(t *testing.T, c CloudflareClient, account string) []models.Destination {
+	t.Helper()
+	list, err := c.GetVerifiedDestinations(testCfg, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestCachedDestinations(t *testing.T) {
+	inner := &countingCloudflare{FakeCloudflare: NewFakeCloudflare(false)}
+	cache := NewCachedCloudflare(inner, time.Hour)
+	account, _ := inner.GetAccountID(testCfg)
+
+	if err := cache.CreateDestination(testCfg, account, "ana@dest.test"); err != nil {
+		t.Fatal(err)
+	}
+	inner.VerifyDestination(account, "ana@dest.test")
+
+	list := verified(t, cache, account)
+	if len(list) != 1 || inner.lists != 1 {
+		t.Fatalf("primeira listagem: %+v, %d chamadas", list, inner.lists)
+	}
+	// Quem recebe a lista pode alterá-la sem mexer no cache
+	list[0].Email = "alterado@dest.test"
+	if again := verified(t, cache, account); inner.lists != 1 || again[0].Email != "ana@dest.test" {
+		t.Fatalf("segunda listagem: %+v, %d chamadas", again, inner.lists)
+	}
+
+	// Adicionar e remover descartam a lista da conta
+	if err := cache.CreateDestination(testCfg, account, "bia@dest.test"); err != nil {
+		t.Fatal(err)
+	}
+	inner.VerifyDestination(account, "bia@dest.test")
+	if list := verified(t, cache, account); inner.lists != 2 || len(list) != 2 {
+		t.Fatalf("após adicionar: %+v, %d chamadas", list, inner.lists)
+	}
+	if err := cache.DeleteDestination(testCfg, account, list[0].Tag); err != nil {
+		t.Fatal(err)
+	}
+	if list := verified(t, cache, account); inner.lists != 3 || len(list) != 1 || list[0].Email != "bia@dest.test" {
+		t.Fatalf("após remover: %+v, %d chamadas", list, inner.lists)
+	}
+
+	// A confirmação chega por email, fora do cliente: ForgetDestinations descarta a lista
+	prev := CF
+	CF = cache
+	t.Cleanup(func() { CF = prev })
+	cache.CreateDestination(testCfg, account, "caio@dest.test")
+	verified(t, cache, account)
+	inner.VerifyDestination(account, "caio@dest.test")
+	isVerified := func() bool {
+		for _, d := range verified(t, cache, account) {
+			if d.Email == "caio@dest.test" {
+				return d.Verified != ""
+			}
+		}
+		t.Fatal("destino caio@dest.test não listado")
+		return false
+	}
+	if isVerified() {
+		t.Fatal("confirmação apareceu antes de descartar o cache")
+	}
+	ForgetDestinations(account)
+	if !isVerified() {
+		t.Fatal("confirmação não apareceu após ForgetDestinations")
+	}
+}
+
+func TestCachedDestinationsExpire(t *testing.T) {
+	inner := &countingCloudflare{FakeCloudflare: NewFakeCloudflare(false)}
+	cache := NewCachedCloudflare(inner, 20*time.Millisecond)
+
+	verified(t, cache, "conta")
+	verified(t, cache, "conta")
+	verified(t, cache, "outra")
+	if inner.lists != 2 {
+		t.Fatalf("%d chamadas, esperado 2 (uma por conta)", inner.lists)
+	}
+	time.Sleep(30 * time.Millisecond)
+	verified(t, cache, "conta")
+	if inner.lists != 3 {
+		t.Fatalf("lista expirada não foi buscada de novo: %d chamadas", inner.lists)
+	}
+
+	// Erros não ficam em cache
+	time.Sleep(30 * time.Millisecond)
+	inner.fail = errors.New("indisponível")
+	if _, err := cache.GetVerifiedDestinations(testCfg, "conta"); err == nil {
+		t.Fatal("erro do cliente não repassado")
+	}
+	inner.fail = nil
+	verified(t, cache, "conta")
+	if inner.lists != 5 {
+		t.Fatalf("%d chamadas, esperado 5", inner.lists)
+	}
+}
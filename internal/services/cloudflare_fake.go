@@ -0,0 +1,320 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"tempmail/internal/models"
+	"time"
+)
+
+// FakeRule é uma regra de roteamento mantida em memória pelo FakeCloudflare
+type FakeRule struct {
+	ID           string
+	ZoneID       string
+	Name         string
+	Email        string
+	Action       string
+	Destinations []string
+	Worker       string // script das regras com ação worker
+}
+
+// FakeCloudflare emula o Email Routing em memória, para testes e para o modo demo.
+// Segue as mesmas regras da API: token obrigatório, destino precisa estar verificado
+// e um endereço não pode ter duas regras na mesma zona.
+type FakeCloudflare struct {
+	// AutoVerify marca novos destinos como verificados na hora (sem o email de confirmação)
+	AutoVerify bool
+
+	mu           sync.Mutex
+	rules        map[string]FakeRule
+	destinations map[string]map[string]models.Destination // accountID -> tag -> destino
+	catchAll     map[string]models.CatchAll               // zoneID -> regra pega-tudo
+	events       map[string][]models.Delivery             // zoneID -> eventos de entrega, em ordem
+}
+
+func NewFakeCloudflare(autoVerify bool) *FakeCloudflare {
+	return &FakeCloudflare{
+		AutoVerify:   autoVerify,
+		rules:        make(map[string]FakeRule),
+		destinations: make(map[string]map[string]models.Destination),
+		catchAll:     make(map[string]models.CatchAll),
+		events:       make(map[string][]models.Delivery),
+	}
+}
+
+func (f *FakeCloudflare) CreateRule(cfg models.Config, email string, destinations []string) (string, error) {
+	if err := fakeAuth(cfg); err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	action := ruleAction(cfg, models.ActionForward)
+	if action == models.ActionForward {
+		if err := f.checkDestinations(cfg.ZoneID, destinations); err != nil {
+			return "", err
+		}
+	} else {
+		destinations = nil
+	}
+	for _, r := range f.rules {
+		if r.ZoneID == cfg.ZoneID && strings.EqualFold(r.Email, email) {
+			return "", fakeError(http.StatusConflict, "rule with the same matcher already exists")
+		}
+	}
+
+	id := fakeID()
+	f.rules[id] = FakeRule{ID: id, ZoneID: cfg.ZoneID, Name: "Temp: " + email, Email: email, Action: action, Destinations: destinations, Worker: fakeWorker(action, cfg)}
+	return id, nil
+}
+
+func (f *FakeCloudflare) UpdateRule(cfg models.Config, id, email, action string, destinations []string) error {
+	if err := fakeAuth(cfg); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r, ok := f.rules[id]
+	if !ok || r.ZoneID != cfg.ZoneID {
+		return fakeError(http.StatusNotFound, "rule not found")
+	}
+	action = ruleAction(cfg, action)
+	if action == models.ActionForward {
+		if err := f.checkDestinations(cfg.ZoneID, destinations); err != nil {
+			return err
+		}
+	} else {
+		destinations = nil
+	}
+	r.Email, r.Action, r.Destinations, r.Worker = email, action, destinations, fakeWorker(action, cfg)
+	f.rules[id] = r
+	return nil
+}
+
+func fakeWorker(action string, cfg models.Config) string {
+	if action == models.ActionWorker {
+		return cfg.Worker
+	}
+	return ""
+}
+
+// checkDestinations exige ao menos um destino e todos verificados na conta da zona
+func (f *FakeCloudflare) checkDestinations(zoneID string, destinations []string) error {
+	if len(destinations) == 0 {
+		return fakeError(http.StatusBadRequest, "forward action requires at least one destination")
+	}
+	for _, d := range destinations {
+		if !f.isVerified(fakeAccountID(zoneID), d) {
+			return fakeError(http.StatusBadRequest, "destination address not verified")
+		}
+	}
+	return nil
+}
+
+func (f *FakeCloudflare) DeleteRule(cfg models.Config, id string) error {
+	if err := fakeAuth(cfg); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r, ok := f.rules[id]
+	if !ok || r.ZoneID != cfg.ZoneID {
+		return fakeError(http.StatusNotFound, "erro ao deletar regra (status 404)")
+	}
+	delete(f.rules, id)
+	return nil
+}
+
+func (f *FakeCloudflare) ListRules(cfg models.Config) ([]models.RoutingRule, error) {
+	if err := fakeAuth(cfg); err != nil {
+		return nil, err
+	}
+	list := []models.RoutingRule{}
+	for _, r := range f.Rules(cfg.ZoneID) {
+		list = append(list, models.RoutingRule{ID: r.ID, Name: r.Name, Email: r.Email, Enabled: true})
+	}
+	return list, nil
+}
+
+func (f *FakeCloudflare) GetAccountID(cfg models.Config) (string, error) {
+	if err := fakeAuth(cfg); err != nil {
+		return "", err
+	}
+	return fakeAccountID(cfg.ZoneID), nil
+}
+
+func (f *FakeCloudflare) GetVerifiedDestinations(cfg models.Config, accountID string) ([]models.Destination, error) {
+	if err := fakeAuth(cfg); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	list := []models.Destination{}
+	for _, d := range f.destinations[accountID] {
+		list = append(list, d)
+	}
+	return list, nil
+}
+
+func (f *FakeCloudflare) CreateDestination(cfg models.Config, accountID, email string) error {
+	if err := fakeAuth(cfg); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.destinations[accountID] == nil {
+		f.destinations[accountID] = make(map[string]models.Destination)
+	}
+	for _, d := range f.destinations[accountID] {
+		if strings.EqualFold(d.Email, email) {
+			return fakeError(http.StatusConflict, "destination address already exists")
+		}
+	}
+
+	d := models.Destination{Tag: fakeID(), Email: email}
+	if f.AutoVerify {
+		d.Verified = time.Now().UTC().Format(time.RFC3339)
+	}
+	f.destinations[accountID][d.Tag] = d
+	return nil
+}
+
+func (f *FakeCloudflare) DeleteDestination(cfg models.Config, accountID, destID string) error {
+	if err := fakeAuth(cfg); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.destinations[accountID][destID]; !ok {
+		return fakeError(http.StatusNotFound, "erro ao deletar (status 404)")
+	}
+	delete(f.destinations[accountID], destID)
+	return nil
+}
+
+func (f *FakeCloudflare) GetCatchAll(cfg models.Config) (models.CatchAll, error) {
+	if err := fakeAuth(cfg); err != nil {
+		return models.CatchAll{}, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rule, ok := f.catchAll[cfg.ZoneID]
+	if !ok {
+		rule = models.CatchAll{Action: models.CatchAllDisabled}
+	}
+	rule.Domain = cfg.Domain
+	return rule, nil
+}
+
+func (f *FakeCloudflare) SetCatchAll(cfg models.Config, rule models.CatchAll) error {
+	if err := fakeAuth(cfg); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if rule.Action == models.CatchAllForward && !f.isVerified(fakeAccountID(cfg.ZoneID), rule.Destination) {
+		return fakeError(http.StatusBadRequest, "destination address not verified")
+	}
+	if rule.Action != models.CatchAllForward {
+		rule.Destination = ""
+	}
+	f.catchAll[cfg.ZoneID] = rule
+	return nil
+}
+
+func (f *FakeCloudflare) EmailEvents(cfg models.Config, since time.Time, limit int) ([]models.Delivery, error) {
+	if err := fakeAuth(cfg); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events := []models.Delivery{}
+	for _, ev := range f.events[cfg.ZoneID] {
+		if !ev.ReceivedAt.Before(since) && len(events) < limit {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// AddEmailEvent simula um email roteado pela zona, que aparece nas métricas (EmailEvents)
+func (f *FakeCloudflare) AddEmailEvent(zoneID string, ev models.Delivery) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ev.ReceivedAt.IsZero() {
+		ev.ReceivedAt = time.Now()
+	}
+	if ev.ExternalID == "" {
+		ev.ExternalID = "cf:" + fakeID()
+	}
+	ev.Source = models.SourceCloudflare
+	f.events[zoneID] = append(f.events[zoneID], ev)
+}
+
+// VerifyDestination simula o clique no link de confirmação enviado pela Cloudflare
+func (f *FakeCloudflare) VerifyDestination(accountID, email string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for tag, d := range f.destinations[accountID] {
+		if strings.EqualFold(d.Email, email) {
+			d.Verified = time.Now().UTC().Format(time.RFC3339)
+			f.destinations[accountID][tag] = d
+		}
+	}
+}
+
+// Rules devolve as regras existentes na zona
+func (f *FakeCloudflare) Rules(zoneID string) []FakeRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var list []FakeRule
+	for _, r := range f.rules {
+		if r.ZoneID == zoneID {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+func (f *FakeCloudflare) isVerified(accountID, email string) bool {
+	for _, d := range f.destinations[accountID] {
+		if strings.EqualFold(d.Email, email) {
+			return d.Verified != ""
+		}
+	}
+	return false
+}
+
+// fakeError imita a resposta de erro da API com o status correspondente
+func fakeError(status int, message string) error {
+	return &CFError{Status: status, Message: message}
+}
+
+func fakeAuth(cfg models.Config) error {
+	if cfg.CFToken == "" || cfg.ZoneID == "" {
+		return &CFError{Status: http.StatusForbidden, Code: 10000, Message: "Authentication error"}
+	}
+	return nil
+}
+
+// fakeAccountID deriva um Account ID estável a partir da zona
+func fakeAccountID(zoneID string) string {
+	return "fake-account-" + zoneID
+}
+
+func fakeID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
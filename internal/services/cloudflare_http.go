@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"tempmail/internal/models"
+	"time"
+)
+
+// DefaultCloudflareTimeout limita cada tentativa (conexão, envio e leitura da resposta)
+const DefaultCloudflareTimeout = 15 * time.Second
+
+// Política de novas tentativas: até maxRetries repetições com espera exponencial a partir de
+// retryBaseDelay (limitada a retryMaxDelay). Um Retry-After maior que maxRetryAfter não é
+// esperado: o erro volta na hora para quem chamou. São variáveis para os testes encurtarem as esperas.
+var (
+	maxRetries     = 3
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
+	maxRetryAfter  = 30 * time.Second
+)
+
+// NewHTTPClient cria o cliente HTTP usado com a Cloudflare: transporte próprio (conexões
+// reaproveitadas entre as chamadas) e tempo limite por requisição
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = DefaultCloudflareTimeout
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          20,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// CFError é uma falha devolvida pela API da Cloudflare
+type CFError struct {
+	Status     int           // status HTTP da resposta
+	Code       int           // código de erro da Cloudflare (0 quando a resposta não trouxe)
+	Message    string        // mensagem da Cloudflare ou descrição da operação
+	RetryAfter time.Duration // espera pedida pela API em respostas 429
+}
+
+func (e *CFError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("%s (código %d)", e.Message, e.Code)
+	}
+	return e.Message
+}
+
+// HTTPStatus traduz uma falha da Cloudflare no status que o handler deve devolver:
+// erros de validação (destino não verificado, regra repetida) são do cliente, limite de
+// requisições vira 429 e o resto (token inválido, instabilidade, rede) é 502 ou 504
+func HTTPStatus(err error) int {
+	var cfErr *CFError
+	if errors.As(err, &cfErr) {
+		switch cfErr.Status {
+		case http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests:
+			return cfErr.Status
+		}
+		return http.StatusBadGateway
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// IsNotFound indica que o recurso não existe (mais) na Cloudflare
+func IsNotFound(err error) bool {
+	var cfErr *CFError
+	return errors.As(err, &cfErr) && cfErr.Status == http.StatusNotFound
+}
+
+// cfResponse é o envelope comum das respostas da API v4
+type cfResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
+}
+
+// call executa a requisição com novas tentativas e decodifica o resultado em out (se não nil).
+// what descreve a operação na mensagem de erro quando a Cloudflare não manda nenhuma.
+func (c *HTTPCloudflare) call(cfg models.Config, method, path string, payload, out interface{}, what string) (*cfResponse, error) {
+	var body []byte
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, retry, wait, err := c.attempt(cfg, method, path, body, what)
+		if err == nil {
+			if out != nil && len(res.Result) > 0 {
+				if err := json.Unmarshal(res.Result, out); err != nil {
+					return nil, fmt.Errorf("%s: resposta inválida da Cloudflare", what)
+				}
+			}
+			return res, nil
+		}
+		if !retry || attempt >= maxRetries {
+			return nil, err
+		}
+		if wait == 0 {
+			wait = backoff(attempt)
+		}
+		time.Sleep(wait)
+	}
+}
+
+// attempt faz uma tentativa. Em caso de erro, retry indica se vale repetir e wait traz a
+// espera pedida pela API (0 = usar o backoff). POST só é repetido em 429 (a Cloudflare não
+// processou o pedido), já que uma falha de rede no meio pode ter criado o recurso.
+func (c *HTTPCloudflare) attempt(cfg models.Config, method, path string, body []byte, what string) (res *cfResponse, retry bool, wait time.Duration, err error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, false, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.CFToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	idempotent := method != http.MethodPost
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, idempotent, 0, err
+	}
+	defer resp.Body.Close()
+
+	var parsed cfResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&parsed)
+	if decodeErr == nil && parsed.Success && resp.StatusCode < 300 {
+		return &parsed, false, 0, nil
+	}
+
+	cfErr := &CFError{Status: resp.StatusCode, Message: fmt.Sprintf("%s (status %d)", what, resp.StatusCode)}
+	if len(parsed.Errors) > 0 {
+		cfErr.Code = parsed.Errors[0].Code
+		cfErr.Message = parsed.Errors[0].Message
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		cfErr.RetryAfter = retryAfter(resp.Header.Get("Retry-After"))
+		return nil, cfErr.RetryAfter <= maxRetryAfter, cfErr.RetryAfter, cfErr
+	case resp.StatusCode >= 500:
+		return nil, idempotent, 0, cfErr
+	}
+	return nil, false, 0, cfErr
+}
+
+// backoff devolve a espera da tentativa n (exponencial com variação aleatória de até 50%)
+func backoff(n int) time.Duration {
+	d := retryBaseDelay << n
+	if d > retryMaxDelay || d <= 0 {
+		d = retryMaxDelay
+	}
+	return d/2 + time.Duration(rand.Int64N(int64(d/2)+1))
+}
+
+// retryAfter interpreta o cabeçalho Retry-After (segundos ou data HTTP)
+func retryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"tempmail/internal/models"
+	"testing"
+	"time"
+)
+
+var testCfg = models.Config{CFToken: "token", ZoneID: "zona", Domain: "exemplo.test"}
+
+// fastRetries encurta as esperas entre tentativas até o fim do teste
+func fastRetries(t *testing.T) {
+	base, max, after := retryBaseDelay, retryMaxDelay, maxRetryAfter
+	retryBaseDelay, retryMaxDelay = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { retryBaseDelay, retryMaxDelay, maxRetryAfter = base, max, after })
+}
+
+// fakeAPI responde cada tentativa com fn(n), onde n começa em 1, e conta as tentativas
+func fakeAPI(t *testing.T, fn func(w http.ResponseWriter, r *http.Request, n int32)) (*HTTPCloudflare, *atomic.Int32) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fn(w, r, attempts.Add(1))
+	}))
+	t.Cleanup(srv.Close)
+	return NewCloudflare(srv.URL, nil), &attempts
+}
+
+func writeOK(w http.ResponseWriter, result string) {
+	fmt.Fprintf(w, `{"success":true,"errors":[],"result":%s}`, result)
+}
+
+func writeFail(w http.ResponseWriter, status int) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"success":false,"errors":[{"code":%d,"message":"falha %d"}]}`, 7000+status, status)
+}
+
+func TestCallRetriesServerErrorsAndRateLimit(t *testing.T) {
+	fastRetries(t)
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		cf, attempts := fakeAPI(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+			if n <= 2 {
+				writeFail(w, status)
+				return
+			}
+			writeOK(w, `{"account":{"id":"conta"}}`)
+		})
+		account, err := cf.GetAccountID(testCfg)
+		if err != nil || account != "conta" {
+			t.Fatalf("status %d: %q, %v", status, account, err)
+		}
+		if n := attempts.Load(); n != 3 {
+			t.Errorf("status %d: %d tentativas, esperado 3", status, n)
+		}
+	}
+}
+
+func TestCallGivesUpAfterMaxRetries(t *testing.T) {
+	fastRetries(t)
+	cf, attempts := fakeAPI(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		writeFail(w, http.StatusBadGateway)
+	})
+	err := cf.DeleteRule(testCfg, "regra")
+	var cfErr *CFError
+	if !errors.As(err, &cfErr) || cfErr.Status != http.StatusBadGateway {
+		t.Fatalf("erro: %v", err)
+	}
+	if n := attempts.Load(); n != int32(maxRetries)+1 {
+		t.Errorf("%d tentativas, esperado %d", n, maxRetries+1)
+	}
+}
+
+// Um POST que falhou no servidor pode ter criado a regra: não é repetido, exceto em 429
+func TestPostRetriedOnlyOnRateLimit(t *testing.T) {
+	fastRetries(t)
+	cf, attempts := fakeAPI(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if r.Method != http.MethodPost {
+			t.Errorf("método %s", r.Method)
+		}
+		writeFail(w, http.StatusInternalServerError)
+	})
+	if _, err := cf.CreateRule(testCfg, "a@exemplo.test", []string{"b@dest.test"}); HTTPStatus(err) != http.StatusBadGateway {
+		t.Fatalf("erro: %v", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("POST com 500: %d tentativas, esperado 1", n)
+	}
+
+	cf, attempts = fakeAPI(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if n == 1 {
+			writeFail(w, http.StatusTooManyRequests)
+			return
+		}
+		writeOK(w, `{"id":"regra-1"}`)
+	})
+	if id, err := cf.CreateRule(testCfg, "a@exemplo.test", []string{"b@dest.test"}); err != nil || id != "regra-1" {
+		t.Fatalf("POST com 429: %q, %v", id, err)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("POST com 429: %d tentativas, esperado 2", n)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	fastRetries(t)
+	maxRetryAfter = 2 * time.Second
+
+	// Dentro do limite a espera pedida é respeitada
+	cf, attempts := fakeAPI(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		if n == 1 {
+			w.Header().Set("Retry-After", "1")
+			writeFail(w, http.StatusTooManyRequests)
+			return
+		}
+		writeOK(w, `[]`)
+	})
+	start := time.Now()
+	if _, err := cf.ListRules(testCfg); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); attempts.Load() != 2 || elapsed < time.Second {
+		t.Errorf("%d tentativas em %v, esperado 2 após 1s", attempts.Load(), elapsed)
+	}
+
+	// Acima do limite o erro volta na hora, com a espera pedida
+	cf, attempts = fakeAPI(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		w.Header().Set("Retry-After", "120")
+		writeFail(w, http.StatusTooManyRequests)
+	})
+	_, err := cf.ListRules(testCfg)
+	var cfErr *CFError
+	if !errors.As(err, &cfErr) || cfErr.RetryAfter != 120*time.Second || HTTPStatus(err) != http.StatusTooManyRequests {
+		t.Fatalf("erro: %#v", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("Retry-After acima do limite: %d tentativas, esperado 1", n)
+	}
+
+	if d := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); d < 58*time.Second || d > time.Minute {
+		t.Errorf("Retry-After em data HTTP: %v", d)
+	}
+	if d := retryAfter("amanhã"); d != 0 {
+		t.Errorf("Retry-After inválido: %v", d)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	fastRetries(t)
+	release := make(chan struct{})
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		select {
+		case <-release:
+		case <-time.After(time.Second):
+		}
+		writeOK(w, `[]`)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	cf := NewCloudflare(srv.URL, NewHTTPClient(50*time.Millisecond))
+
+	start := time.Now()
+	_, err := cf.ListRules(testCfg)
+	if HTTPStatus(err) != http.StatusGatewayTimeout {
+		t.Fatalf("erro: %v (status %d), esperado 504", err, HTTPStatus(err))
+	}
+	if n := attempts.Load(); n != int32(maxRetries)+1 {
+		t.Errorf("GET com timeout: %d tentativas, esperado %d", n, maxRetries+1)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("o tempo limite não foi aplicado: %v", elapsed)
+	}
+
+	// Sem resposta não dá para saber se o POST criou a regra: não repete
+	attempts.Store(0)
+	if _, err := cf.CreateRule(testCfg, "a@exemplo.test", []string{"b@dest.test"}); HTTPStatus(err) != http.StatusGatewayTimeout {
+		t.Fatalf("POST: %v, esperado 504", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("POST com timeout: %d tentativas, esperado 1", n)
+	}
+}
+
+func TestCFErrorStatus(t *testing.T) {
+	fastRetries(t)
+	cf, _ := fakeAPI(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		writeFail(w, http.StatusBadRequest)
+	})
+	err := cf.CreateDestination(testCfg, "conta", "b@dest.test")
+	if err == nil || err.Error() != "falha 400 (código 7400)" {
+		t.Fatalf("erro sem a mensagem da Cloudflare: %v", err)
+	}
+
+	// Sem o envelope da API a mensagem descreve a operação
+	cf, _ = fakeAPI(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	err = cf.DeleteRule(testCfg, "regra")
+	if err == nil || err.Error() != "erro ao deletar regra (status 404)" || !IsNotFound(err) {
+		t.Fatalf("erro: %v", err)
+	}
+
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{&CFError{Status: http.StatusBadRequest}, http.StatusBadRequest},
+		{&CFError{Status: http.StatusNotFound}, http.StatusNotFound},
+		{&CFError{Status: http.StatusConflict}, http.StatusConflict},
+		{&CFError{Status: http.StatusTooManyRequests}, http.StatusTooManyRequests},
+		// Token inválido é problema da instalação, não de quem chamou
+		{&CFError{Status: http.StatusForbidden}, http.StatusBadGateway},
+		{&CFError{Status: http.StatusServiceUnavailable}, http.StatusBadGateway},
+		{fmt.Errorf("criar regra: %w", &CFError{Status: http.StatusConflict}), http.StatusConflict},
+		{errors.New("connection refused"), http.StatusBadGateway},
+	} {
+		if got := HTTPStatus(tc.err); got != tc.want {
+			t.Errorf("HTTPStatus(%v) = %d, esperado %d", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter é um token bucket por chave (IP, usuário...): cada chave acumula até
+// burst requisições e recupera perMinute fichas por minuto.
+type RateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // fichas por segundo
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter cria um limitador; perMinute <= 0 desativa o limite
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow consome uma ficha da chave. Quando não há fichas, devolve false e quanto
+// tempo falta para a próxima.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if l == nil || l.rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep descarta, no máximo uma vez por minuto, os buckets que já voltaram a ficar cheios
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// LoginGuard conta falhas de autenticação por chave e aplica bloqueio exponencial:
+// após free tentativas erradas, cada nova falha dobra a espera (base, 2×base, 4×base...)
+// até o teto max. Um sucesso zera a contagem.
+type LoginGuard struct {
+	mu      sync.Mutex
+	free    int
+	base    time.Duration
+	max     time.Duration
+	entries map[string]*loginAttempts
+}
+
+type loginAttempts struct {
+	failures    int
+	lockedUntil time.Time
+	last        time.Time
+}
+
+// loginForget é o tempo sem falhas após o qual a contagem de uma chave é esquecida
+const loginForget = time.Hour
+
+// NewLoginGuard cria um controle de tentativas com free falhas toleradas antes do bloqueio
+func NewLoginGuard(free int, base, max time.Duration) *LoginGuard {
+	return &LoginGuard{
+		free:    free,
+		base:    base,
+		max:     max,
+		entries: make(map[string]*loginAttempts),
+	}
+}
+
+// Locked devolve o maior tempo de bloqueio restante entre as chaves (0 = liberado)
+func (g *LoginGuard) Locked(keys ...string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, k := range keys {
+		if a, ok := g.entries[k]; ok && a.lockedUntil.After(now) {
+			if d := a.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// Fail registra uma tentativa errada para cada chave
+func (g *LoginGuard) Fail(keys ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for k, a := range g.entries {
+		if now.Sub(a.last) > loginForget && !a.lockedUntil.After(now) {
+			delete(g.entries, k)
+		}
+	}
+
+	for _, k := range keys {
+		a, ok := g.entries[k]
+		if !ok {
+			a = &loginAttempts{}
+			g.entries[k] = a
+		}
+		a.failures++
+		a.last = now
+		if a.failures > g.free {
+			lock := g.base << uint(min(a.failures-g.free-1, 30))
+			if lock > g.max || lock <= 0 {
+				lock = g.max
+			}
+			a.lockedUntil = now.Add(lock)
+		}
+	}
+}
+
+// Success zera a contagem das chaves
+func (g *LoginGuard) Success(keys ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, k := range keys {
+		delete(g.entries, k)
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginGuardBackoff(t *testing.T) {
+	g := NewLoginGuard(2, time.Minute, 4*time.Minute)
+
+	// Cada falha além das toleradas dobra a espera, até o teto
+	for i, want := range []time.Duration{0, 0, time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		g.Fail("ana")
+		got := g.Locked("ana")
+		if got > want || got < want-time.Second {
+			t.Errorf("falha %d: bloqueio de %v, esperado %v", i+1, got, want)
+		}
+	}
+
+	if g.Locked("bia") != 0 {
+		t.Error("o bloqueio de uma chave não pode atingir outra")
+	}
+	if got := g.Locked("bia", "ana"); got < 3*time.Minute {
+		t.Errorf("Locked com várias chaves devolveu %v, esperado o maior bloqueio", got)
+	}
+
+	g.Success("ana")
+	if got := g.Locked("ana"); got != 0 {
+		t.Errorf("após o sucesso o bloqueio continua: %v", got)
+	}
+	g.Fail("ana")
+	if got := g.Locked("ana"); got != 0 {
+		t.Errorf("a contagem não foi zerada pelo sucesso: bloqueio de %v", got)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(60, 3)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("ana"); !ok {
+			t.Fatalf("requisição %d recusada dentro do burst", i+1)
+		}
+	}
+	ok, wait := l.Allow("ana")
+	if ok || wait <= 0 || wait > time.Second {
+		t.Fatalf("após o burst: ok=%v, espera %v", ok, wait)
+	}
+	if ok, _ := l.Allow("bia"); !ok {
+		t.Fatal("o limite de uma chave não pode atingir outra")
+	}
+
+	off := NewRateLimiter(0, 1)
+	for i := 0; i < 10; i++ {
+		if ok, _ := off.Allow("ana"); !ok {
+			t.Fatal("limite desativado recusou uma requisição")
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// Vetores do Apêndice B da RFC 6238 (SHA1). Os códigos de 8 dígitos da RFC terminam
+// nos 6 dígitos gerados aqui, pois o truncamento é o mesmo módulo de uma potência de 10.
+func TestTOTPCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	} {
+		got, err := TOTPCode(secret, TOTPStep(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tc.code[len(tc.code)-totpDigits:]; got != want {
+			t.Errorf("T=%d: código %s, esperado %s", tc.unix, got, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	current := TOTPStep(now)
+
+	for offset := int64(-3); offset <= 3; offset++ {
+		code, err := TOTPCode(secret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := ValidateTOTP(secret, code, now)
+		if want := offset >= -totpSkew && offset <= totpSkew; ok != want {
+			t.Errorf("passo %+d: aceito=%v, esperado %v", offset, ok, want)
+		}
+		if ok && step != current+offset {
+			t.Errorf("passo %+d: devolveu o passo %d, esperado %d", offset, step, current+offset)
+		}
+	}
+
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("código com dígitos a menos foi aceito")
+	}
+}
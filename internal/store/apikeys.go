@@ -0,0 +1,70 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"tempmail/internal/models"
+	"time"
+)
+
+type sqlAPIKeyStore struct {
+	db *sql.DB
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, scopes, created_at, last_used_at, revoked"
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var k models.APIKey
+	var scopes sql.NullString
+	var lastUsed sql.NullTime
+	var revoked sql.NullBool
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &scopes, &k.CreatedAt, &lastUsed, &revoked)
+	if lastUsed.Valid {
+		k.LastUsedAt = &lastUsed.Time
+	}
+	k.Scopes = models.ParseScopes(scopes.String)
+	k.Revoked = revoked.Bool
+	return k, err
+}
+
+func (s *sqlAPIKeyStore) GetByHash(hash string) (models.APIKey, error) {
+	k, err := scanAPIKey(s.db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ? AND revoked = FALSE", hash))
+	return k, notFound(err)
+}
+
+func (s *sqlAPIKeyStore) List(userID int64) ([]models.APIKey, error) {
+	rows, err := s.db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *sqlAPIKeyStore) Create(k models.APIKey, hash string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, created_at, revoked)
+		VALUES (?, ?, ?, ?, ?, ?, FALSE) RETURNING id`,
+		k.UserID, k.Name, k.Prefix, hash, strings.Join(k.Scopes, ","), k.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func (s *sqlAPIKeyStore) Revoke(id string, userID int64) error {
+	return mustAffect(s.db.Exec("UPDATE api_keys SET revoked = TRUE WHERE id = ? AND user_id = ?", id, userID))
+}
+
+func (s *sqlAPIKeyStore) Touch(id int64) error {
+	now := time.Now()
+	_, err := s.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)", now, id, now.Add(-time.Minute))
+	return err
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"database/sql"
+	"tempmail/internal/models"
+	"time"
+)
+
+type sqlAuditStore struct {
+	db *sql.DB
+}
+
+func (s *sqlAuditStore) Record(e models.AuditEntry) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO audit_log (created_at, user_id, username, action, target, details, ip)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.CreatedAt, e.UserID, e.Username, e.Action, e.Target, e.Details, e.IP)
+	return err
+}
+
+func (s *sqlAuditStore) List(limit int) ([]models.AuditEntry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, created_at, user_id, username, action, target, details, ip
+		FROM audit_log ORDER BY created_at DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var userID sql.NullInt64
+		var username, action, target, details, ip sql.NullString
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &userID, &username, &action, &target, &details, &ip); err != nil {
+			return nil, err
+		}
+		e.UserID = userID.Int64
+		e.Username = username.String
+		e.Action = action.String
+		e.Target = target.String
+		e.Details = details.String
+		e.IP = ip.String
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
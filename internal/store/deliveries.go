@@ -0,0 +1,112 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"tempmail/internal/models"
+	"time"
+)
+
+type sqlDeliveryStore struct {
+	db *sql.DB
+}
+
+// maxSenderDomains limita os domínios remetentes devolvidos por alias
+const maxSenderDomains = 5
+
+func (s *sqlDeliveryStore) Record(d models.Delivery) (bool, error) {
+	if d.ReceivedAt.IsZero() {
+		d.ReceivedAt = time.Now()
+	}
+	// O domínio vai para o painel: só nomes de host válidos são guardados
+	if !models.IsHostname(d.SenderDomain) {
+		d.SenderDomain = ""
+	}
+	var externalID interface{}
+	if d.ExternalID != "" {
+		externalID = d.ExternalID
+	}
+	res, err := s.db.Exec(`
+		INSERT INTO deliveries (alias, sender, sender_domain, status, source, external_id, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (external_id) DO NOTHING`,
+		d.Alias, d.Sender, d.SenderDomain, d.Status, d.Source, externalID, d.ReceivedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sqlDeliveryStore) Latest(source, domain string) (time.Time, error) {
+	// Compara o sufixo exato ("@dominio"): com LIKE, "_" e "%" do nome valeriam como curingas
+	suffix := "@" + strings.ToLower(domain)
+	var t time.Time
+	err := s.db.QueryRow(
+		"SELECT received_at FROM deliveries WHERE source = ? AND LOWER(SUBSTR(alias, LENGTH(alias) - ?)) = ? ORDER BY received_at DESC LIMIT 1",
+		source, len(suffix)-1, suffix).Scan(&t)
+	return t, notFound(err)
+}
+
+// attachDeliveryStats completa contagem, último recebimento e domínios remetentes dos aliases
+// do dono. As rejeitadas não contam: o alias não recebeu nada.
+func attachDeliveryStats(db *sql.DB, ownerID int64, list []models.EmailEntry) error {
+	if len(list) == 0 {
+		return nil
+	}
+	const owned = "d.status <> '" + models.DeliveryRejected + "' AND EXISTS(SELECT 1 FROM emails e WHERE e.email = d.alias AND e.owner_id = ?)"
+	byAlias := make(map[string]*models.EmailEntry, len(list))
+	for i := range list {
+		list[i].SenderDomains = []string{}
+		byAlias[list[i].Email] = &list[i]
+	}
+
+	rows, err := db.Query("SELECT d.alias, d.sender_domain, COUNT(*) FROM deliveries d WHERE "+owned+" GROUP BY d.alias, d.sender_domain ORDER BY d.alias, COUNT(*) DESC, d.sender_domain", ownerID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias string
+		var domain sql.NullString
+		var count int64
+		if err := rows.Scan(&alias, &domain, &count); err != nil {
+			return err
+		}
+		e, ok := byAlias[alias]
+		if !ok {
+			continue
+		}
+		e.MessageCount += count
+		if domain.String != "" && len(e.SenderDomains) < maxSenderDomains {
+			e.SenderDomains = append(e.SenderDomains, domain.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// O MAX de uma data volta como texto no SQLite: a linha mais recente de cada alias é
+	// buscada diretamente, para o driver converter a coluna
+	latest, err := db.Query(`
+		SELECT d.alias, d.received_at FROM deliveries d
+		WHERE `+owned+` AND NOT EXISTS (
+			SELECT 1 FROM deliveries n
+			WHERE n.alias = d.alias AND n.status <> ? AND n.received_at > d.received_at
+		)`, ownerID, models.DeliveryRejected)
+	if err != nil {
+		return err
+	}
+	defer latest.Close()
+	for latest.Next() {
+		var alias string
+		var t time.Time
+		if err := latest.Scan(&alias, &t); err != nil {
+			return err
+		}
+		if e, ok := byAlias[alias]; ok {
+			e.LastReceivedAt = &t
+		}
+	}
+	return latest.Err()
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"database/sql"
+	"tempmail/internal/models"
+	"tempmail/internal/secrets"
+	"time"
+)
+
+type sqlDomainStore struct {
+	db *sql.DB
+}
+
+const domainColumns = "id, domain, zone_id, account_id, cf_token, is_default, created_at, worker"
+
+// scanDomain lê um domínio já com o token aberto
+func scanDomain(row rowScanner) (models.Domain, error) {
+	var d models.Domain
+	var accountID, token, worker sql.NullString
+	var isDefault sql.NullBool
+	var createdAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.Domain, &d.ZoneID, &accountID, &token, &isDefault, &createdAt, &worker); err != nil {
+		return d, err
+	}
+	d.AccountID = accountID.String
+	d.Worker = worker.String
+	d.IsDefault = isDefault.Bool
+	d.CreatedAt = createdAt.Time
+	var err error
+	d.CFToken, err = secrets.Decrypt(token.String)
+	return d, err
+}
+
+func (s *sqlDomainStore) List() ([]models.Domain, error) {
+	rows, err := s.db.Query("SELECT " + domainColumns + " FROM domains ORDER BY is_default DESC, domain")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Domain{}
+	for rows.Next() {
+		d, err := scanDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlDomainStore) Get(id int64) (models.Domain, error) {
+	d, err := scanDomain(s.db.QueryRow("SELECT "+domainColumns+" FROM domains WHERE id = ?", id))
+	return d, notFound(err)
+}
+
+func (s *sqlDomainStore) FindByName(domain string) (models.Domain, error) {
+	d, err := scanDomain(s.db.QueryRow("SELECT "+domainColumns+" FROM domains WHERE domain = ?", domain))
+	return d, notFound(err)
+}
+
+func (s *sqlDomainStore) Default() (models.Domain, error) {
+	d, err := scanDomain(s.db.QueryRow("SELECT " + domainColumns + " FROM domains WHERE is_default = TRUE"))
+	return d, notFound(err)
+}
+
+func (s *sqlDomainStore) Save(d models.Domain) (int64, error) {
+	token, err := secrets.Encrypt(d.CFToken)
+	if err != nil {
+		return 0, err
+	}
+
+	if d.ID != 0 {
+		err := mustAffect(s.db.Exec(
+			"UPDATE domains SET domain = ?, zone_id = ?, account_id = ?, cf_token = ?, worker = ? WHERE id = ?",
+			d.Domain, d.ZoneID, d.AccountID, token, d.Worker, d.ID))
+		if err != nil && isUniqueViolation(err) {
+			return 0, ErrConflict
+		}
+		return d.ID, err
+	}
+
+	var id int64
+	err = withTx(s.db, func(tx *sql.Tx) error {
+		// O primeiro domínio cadastrado já nasce como padrão
+		var first bool
+		if err := tx.QueryRow("SELECT NOT EXISTS(SELECT 1 FROM domains)").Scan(&first); err != nil {
+			return err
+		}
+		return tx.QueryRow(`
+			INSERT INTO domains (domain, zone_id, account_id, cf_token, is_default, created_at, worker)
+			VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			d.Domain, d.ZoneID, d.AccountID, token, first, time.Now(), d.Worker).Scan(&id)
+	})
+	if err != nil && isUniqueViolation(err) {
+		return 0, ErrConflict
+	}
+	return id, err
+}
+
+func (s *sqlDomainStore) SetDefault(id int64) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		if err := mustAffect(tx.Exec("UPDATE domains SET is_default = TRUE WHERE id = ?", id)); err != nil {
+			return err
+		}
+		_, err := tx.Exec("UPDATE domains SET is_default = FALSE WHERE id <> ?", id)
+		return err
+	})
+}
+
+func (s *sqlDomainStore) SetAccountID(id int64, accountID string) error {
+	return mustAffect(s.db.Exec("UPDATE domains SET account_id = ? WHERE id = ?", accountID, id))
+}
+
+func (s *sqlDomainStore) Delete(id int64) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		var inUse bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM emails WHERE domain_id = ? AND state IN (?, ?))", id, models.StateActive, models.StateBurned).Scan(&inUse); err != nil {
+			return err
+		}
+		if inUse {
+			return ErrConflict
+		}
+
+		// O histórico continua listado, só perde o vínculo com a zona
+		if _, err := tx.Exec("UPDATE emails SET domain_id = NULL WHERE domain_id = ?", id); err != nil {
+			return err
+		}
+		if err := mustAffect(tx.Exec("DELETE FROM domains WHERE id = ?", id)); err != nil {
+			return err
+		}
+
+		// Sem o padrão, o domínio mais antigo assume
+		_, err := tx.Exec(`
+			UPDATE domains SET is_default = TRUE
+			WHERE id = (SELECT MIN(id) FROM domains)
+			AND NOT EXISTS (SELECT 1 FROM domains WHERE is_default = TRUE)`)
+		return err
+	})
+}
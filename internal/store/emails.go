@@ -0,0 +1,370 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"tempmail/internal/models"
+	"time"
+)
+
+type sqlEmailStore struct {
+	db *sql.DB
+}
+
+const emailColumns = "id, email, destination, created_at, active, pinned, expires_at, ttl, owner_id, domain_id, state, updated_at, hits, last_hit_at, sender_lock, expire_reason"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEmail(row rowScanner) (models.EmailEntry, error) {
+	var e models.EmailEntry
+	var pinned, senderLock sql.NullBool
+	var expiresAt, updatedAt, lastHitAt sql.NullTime
+	var ttl, ownerID, domainID, hits sql.NullInt64
+	var state, expireReason sql.NullString
+	err := row.Scan(&e.ID, &e.Email, &e.Destination, &e.CreatedAt, &e.Active, &pinned, &expiresAt, &ttl, &ownerID, &domainID, &state, &updatedAt, &hits, &lastHitAt, &senderLock, &expireReason)
+	e.Pinned = pinned.Bool
+	e.SenderLock = senderLock.Bool
+	e.ExpireReason = expireReason.String
+	if expiresAt.Valid {
+		e.ExpiresAt = &expiresAt.Time
+	}
+	if updatedAt.Valid {
+		e.UpdatedAt = &updatedAt.Time
+	}
+	e.Hits = hits.Int64
+	if lastHitAt.Valid {
+		e.LastHitAt = &lastHitAt.Time
+	}
+	e.TTL = ttl.Int64
+	e.OwnerID = ownerID.Int64
+	e.DomainID = domainID.Int64
+	e.State = state.String
+	if e.State == "" {
+		e.State = stateOf(e.Active)
+	}
+	e.Tags = []models.Tag{}
+	// Completado pelas consultas que carregam email_destinations
+	e.Destinations = []string{}
+	if e.Destination != "" {
+		e.Destinations = []string{e.Destination}
+	}
+	e.AllowSenders = []string{}
+	e.BlockSenders = []string{}
+	return e, err
+}
+
+func (s *sqlEmailStore) Get(id string) (models.EmailEntry, error) {
+	return s.getOne("SELECT "+emailColumns+" FROM emails WHERE id = ?", id)
+}
+
+func (s *sqlEmailStore) FindByAddress(email string) (models.EmailEntry, error) {
+	return s.getOne("SELECT "+emailColumns+" FROM emails WHERE email = ?", email)
+}
+
+func (s *sqlEmailStore) getOne(query string, arg interface{}) (models.EmailEntry, error) {
+	e, err := scanEmail(s.db.QueryRow(query, arg))
+	if err != nil {
+		return e, notFound(err)
+	}
+	byEmail, err := s.destinations("SELECT email_id, destination FROM email_destinations WHERE email_id = ? ORDER BY position", e.ID)
+	if err != nil {
+		return e, err
+	}
+	if dests, ok := byEmail[e.ID]; ok {
+		e.Destinations = dests
+	}
+	list := []models.EmailEntry{e}
+	if err := attachSenderRules(s.db, list, "SELECT alias, kind, pattern FROM sender_rules WHERE alias = ? ORDER BY pattern", e.Email); err != nil {
+		return e, err
+	}
+	return list[0], nil
+}
+
+// destinations agrupa por alias o resultado de uma consulta (email_id, destination) já ordenada
+func (s *sqlEmailStore) destinations(query string, args ...interface{}) (map[string][]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byEmail := make(map[string][]string)
+	for rows.Next() {
+		var emailID, dest string
+		if err := rows.Scan(&emailID, &dest); err != nil {
+			return nil, err
+		}
+		byEmail[emailID] = append(byEmail[emailID], dest)
+	}
+	return byEmail, rows.Err()
+}
+
+func (s *sqlEmailStore) Exists(email string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM emails WHERE email = ?)", email).Scan(&exists)
+	return exists, err
+}
+
+func (s *sqlEmailStore) Save(e models.EmailEntry, tags []string) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		// Ao recriar um endereço a regra (e o ID) muda: os vínculos do ID antigo saem antes,
+		// senão as chaves estrangeiras de email_tags e email_destinations impediriam a troca
+		if _, err := tx.Exec("DELETE FROM email_tags WHERE email_id IN (SELECT id FROM emails WHERE email = ?)", e.Email); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM email_destinations WHERE email_id IN (SELECT id FROM emails WHERE email = ?)", e.Email); err != nil {
+			return err
+		}
+
+		dests := e.Destinations
+		if len(dests) == 0 {
+			dests = []string{e.Destination}
+		}
+		e.Destination = dests[0]
+
+		_, err := tx.Exec(`
+			INSERT INTO emails (id, email, destination, created_at, active, pinned, expires_at, ttl, owner_id, domain_id, state, updated_at, sender_lock)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(email) DO UPDATE SET
+				id=excluded.id,
+				destination=excluded.destination,
+				created_at=excluded.created_at,
+				active=excluded.active,
+				pinned=excluded.pinned,
+				expires_at=excluded.expires_at,
+				ttl=excluded.ttl,
+				domain_id=excluded.domain_id,
+				state=excluded.state,
+				updated_at=excluded.updated_at,
+				sender_lock=excluded.sender_lock,
+				expire_reason=NULL
+		`, e.ID, e.Email, e.Destination, e.CreatedAt, e.Active, e.Pinned, e.ExpiresAt, e.TTL, e.OwnerID, e.DomainID, stateOf(e.Active), e.UpdatedAt, e.SenderLock)
+		if err != nil {
+			return err
+		}
+
+		if err := replaceDestinations(tx, e.ID, dests); err != nil {
+			return err
+		}
+		return replaceTags(tx, e.ID, e.OwnerID, tags)
+	})
+}
+
+func (s *sqlEmailStore) Update(id string, ownerID int64, u EmailUpdate) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		var alias string
+		if err := tx.QueryRow("SELECT email FROM emails WHERE id = ? AND owner_id = ?", id, ownerID).Scan(&alias); err != nil {
+			return notFound(err)
+		}
+
+		if u.Destinations != nil && len(*u.Destinations) > 0 {
+			dests := *u.Destinations
+			if _, err := tx.Exec("UPDATE emails SET destination = ? WHERE id = ?", dests[0], id); err != nil {
+				return err
+			}
+			if err := replaceDestinations(tx, id, dests); err != nil {
+				return err
+			}
+		}
+		if u.SenderLock != nil {
+			if _, err := tx.Exec("UPDATE emails SET sender_lock = ? WHERE id = ?", *u.SenderLock, id); err != nil {
+				return err
+			}
+		}
+		if u.AllowSenders != nil {
+			if err := replaceSenderRules(tx, alias, models.SenderAllow, *u.AllowSenders); err != nil {
+				return err
+			}
+		}
+		if u.BlockSenders != nil {
+			if err := replaceSenderRules(tx, alias, models.SenderBlock, *u.BlockSenders); err != nil {
+				return err
+			}
+		}
+		if u.Destinations == nil && u.Tags == nil && u.SenderLock == nil && u.AllowSenders == nil && u.BlockSenders == nil {
+			return nil
+		}
+		if _, err := tx.Exec("UPDATE emails SET updated_at = ? WHERE id = ?", time.Now(), id); err != nil {
+			return err
+		}
+		if u.Tags != nil {
+			return replaceTags(tx, id, ownerID, *u.Tags)
+		}
+		return nil
+	})
+}
+
+// replaceDestinations grava a lista de destinos do alias na ordem recebida
+func replaceDestinations(tx *sql.Tx, emailID string, dests []string) error {
+	if _, err := tx.Exec("DELETE FROM email_destinations WHERE email_id = ?", emailID); err != nil {
+		return err
+	}
+	for i, dest := range dests {
+		if _, err := tx.Exec("INSERT INTO email_destinations (email_id, destination, position) VALUES (?, ?, ?)", emailID, dest, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceTags troca os vínculos de tags do alias, criando as tags que ainda não existem
+func replaceTags(tx *sql.Tx, emailID string, ownerID int64, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM email_tags WHERE email_id = ?", emailID); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range tags {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		tagID, err := ensureTag(tx, ownerID, name)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO email_tags (email_id, tag_id) VALUES (?, ?)", emailID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlEmailStore) ListByOwner(ownerID int64, activeOnly bool) ([]models.EmailEntry, error) {
+	query := "SELECT " + emailColumns + " FROM emails WHERE owner_id = ? ORDER BY created_at DESC"
+	if activeOnly {
+		query = "SELECT " + emailColumns + " FROM emails WHERE owner_id = ? AND active = TRUE ORDER BY pinned DESC, created_at DESC"
+	}
+	list, err := s.query(query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// As tags do dono são carregadas de uma vez e distribuídas pelos aliases
+	rows, err := s.db.Query(`
+		SELECT et.email_id, t.id, t.name, t.color
+		FROM email_tags et
+		JOIN tags t ON t.id = et.tag_id
+		WHERE t.owner_id = ?
+		ORDER BY t.name`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byEmail := make(map[string][]models.Tag)
+	for rows.Next() {
+		var emailID string
+		var t models.Tag
+		if err := rows.Scan(&emailID, &t.ID, &t.Name, &t.Color); err != nil {
+			return nil, err
+		}
+		byEmail[emailID] = append(byEmail[emailID], t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	dests, err := s.destinations(`
+		SELECT ed.email_id, ed.destination
+		FROM email_destinations ed
+		JOIN emails e ON e.id = ed.email_id
+		WHERE e.owner_id = ?
+		ORDER BY ed.email_id, ed.position`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		if tags, ok := byEmail[list[i].ID]; ok {
+			list[i].Tags = tags
+		}
+		if d, ok := dests[list[i].ID]; ok {
+			list[i].Destinations = d
+		}
+	}
+	err = attachSenderRules(s.db, list, `
+		SELECT r.alias, r.kind, r.pattern
+		FROM sender_rules r
+		JOIN emails e ON e.email = r.alias
+		WHERE e.owner_id = ?
+		ORDER BY r.pattern`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return list, attachDeliveryStats(s.db, ownerID, list)
+}
+
+func (s *sqlEmailStore) ListActive() ([]models.EmailEntry, error) {
+	return s.query("SELECT " + emailColumns + " FROM emails WHERE active = TRUE")
+}
+
+func (s *sqlEmailStore) ListWithRule() ([]models.EmailEntry, error) {
+	return s.query("SELECT "+emailColumns+" FROM emails WHERE state IN (?, ?)", models.StateActive, models.StateBurned)
+}
+
+func (s *sqlEmailStore) query(query string, args ...interface{}) ([]models.EmailEntry, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.EmailEntry{}
+	for rows.Next() {
+		e, err := scanEmail(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlEmailStore) HasRule(id string) (bool, error) {
+	var has bool
+	err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM emails WHERE id = ? AND state IN (?, ?))", id, models.StateActive, models.StateBurned).Scan(&has)
+	return has, err
+}
+
+func (s *sqlEmailStore) SetPinned(id string, ownerID int64, pinned bool) error {
+	return mustAffect(s.db.Exec("UPDATE emails SET pinned = ? WHERE id = ? AND owner_id = ?", pinned, id, ownerID))
+}
+
+func (s *sqlEmailStore) SetCreatedAt(id string, t time.Time) error {
+	return mustAffect(s.db.Exec("UPDATE emails SET created_at = ? WHERE id = ?", t, id))
+}
+
+func (s *sqlEmailStore) RecordHit(id string, at time.Time) error {
+	return mustAffect(s.db.Exec("UPDATE emails SET hits = COALESCE(hits, 0) + 1, last_hit_at = ? WHERE id = ?", at, id))
+}
+
+func (s *sqlEmailStore) SetExpiry(id string, expiresAt *time.Time) error {
+	_, err := s.db.Exec("UPDATE emails SET expires_at = ? WHERE id = ?", expiresAt, id)
+	return err
+}
+
+func (s *sqlEmailStore) SetState(id string, state string) error {
+	return mustAffect(s.db.Exec("UPDATE emails SET state = ?, active = ? WHERE id = ?", state, state == models.StateActive, id))
+}
+
+func (s *sqlEmailStore) SetExpireReason(id, reason string) error {
+	return mustAffect(s.db.Exec("UPDATE emails SET expire_reason = ? WHERE id = ?", reason, id))
+}
+
+func (s *sqlEmailStore) Deactivate(id string) error {
+	_, err := s.db.Exec("UPDATE emails SET active = FALSE, state = ? WHERE id = ?", models.StateInactive, id)
+	return err
+}
+
+// stateOf traduz a coluna active para o estado (Save só grava ativos ou inativos)
+func stateOf(active bool) string {
+	if active {
+		return models.StateActive
+	}
+	return models.StateInactive
+}
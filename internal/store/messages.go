@@ -0,0 +1,155 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"tempmail/internal/models"
+	"time"
+)
+
+type sqlMessageStore struct {
+	db *sql.DB
+}
+
+// ownedMessage restringe a consulta às mensagens de aliases do dono
+const ownedMessage = "EXISTS(SELECT 1 FROM emails e WHERE e.email = m.alias AND e.owner_id = ?)"
+
+func (s *sqlMessageStore) Save(m models.Message) (int64, error) {
+	if m.ReceivedAt.IsZero() {
+		m.ReceivedAt = time.Now()
+	}
+	headers, err := json.Marshal(m.Headers)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = withTx(s.db, func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			INSERT INTO messages (alias, mail_from, header_from, subject, headers, text_body, html_body, size, seen, received_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, FALSE, ?)
+			RETURNING id`,
+			m.Alias, m.MailFrom, m.From, m.Subject, string(headers), m.Text, m.HTML, m.Size, m.ReceivedAt).Scan(&id)
+		if err != nil {
+			return err
+		}
+		for _, a := range m.Attachments {
+			_, err := tx.Exec(`
+				INSERT INTO message_attachments (message_id, filename, content_type, size, data)
+				VALUES (?, ?, ?, ?, ?)`,
+				id, a.Filename, a.ContentType, int64(len(a.Data)), a.Data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+func (s *sqlMessageStore) List(ownerID int64, alias string, limit int) ([]models.Message, error) {
+	query := `
+		SELECT m.id, m.alias, m.mail_from, m.header_from, m.subject, m.size, m.seen, m.received_at
+		FROM messages m
+		WHERE ` + ownedMessage
+	args := []interface{}{ownerID}
+	if alias != "" {
+		query += " AND m.alias = ?"
+		args = append(args, alias)
+	}
+	query += " ORDER BY m.received_at DESC, m.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Message{}
+	for rows.Next() {
+		var m models.Message
+		var mailFrom, from, subject sql.NullString
+		var size sql.NullInt64
+		var seen sql.NullBool
+		if err := rows.Scan(&m.ID, &m.Alias, &mailFrom, &from, &subject, &size, &seen, &m.ReceivedAt); err != nil {
+			return nil, err
+		}
+		m.MailFrom, m.From, m.Subject = mailFrom.String, from.String, subject.String
+		m.Size, m.Seen = size.Int64, seen.Bool
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqlMessageStore) Get(id, ownerID int64) (models.Message, error) {
+	var m models.Message
+	var mailFrom, from, subject, headers, text, html sql.NullString
+	var size sql.NullInt64
+	var seen sql.NullBool
+	err := s.db.QueryRow(`
+		SELECT m.id, m.alias, m.mail_from, m.header_from, m.subject, m.headers, m.text_body, m.html_body, m.size, m.seen, m.received_at
+		FROM messages m
+		WHERE m.id = ? AND `+ownedMessage, id, ownerID).
+		Scan(&m.ID, &m.Alias, &mailFrom, &from, &subject, &headers, &text, &html, &size, &seen, &m.ReceivedAt)
+	if err != nil {
+		return m, notFound(err)
+	}
+	m.MailFrom, m.From, m.Subject = mailFrom.String, from.String, subject.String
+	m.Text, m.HTML = text.String, html.String
+	m.Size, m.Seen = size.Int64, seen.Bool
+	if headers.String != "" {
+		json.Unmarshal([]byte(headers.String), &m.Headers)
+	}
+
+	rows, err := s.db.Query("SELECT id, filename, content_type, size FROM message_attachments WHERE message_id = ? ORDER BY id", id)
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+	m.Attachments = []models.Attachment{}
+	for rows.Next() {
+		var a models.Attachment
+		var filename, contentType sql.NullString
+		if err := rows.Scan(&a.ID, &filename, &contentType, &a.Size); err != nil {
+			return m, err
+		}
+		a.Filename, a.ContentType = filename.String, contentType.String
+		m.Attachments = append(m.Attachments, a)
+	}
+	return m, rows.Err()
+}
+
+func (s *sqlMessageStore) Attachment(id, ownerID int64) (models.Attachment, error) {
+	var a models.Attachment
+	var filename, contentType sql.NullString
+	err := s.db.QueryRow(`
+		SELECT a.id, a.filename, a.content_type, a.size, a.data
+		FROM message_attachments a
+		JOIN messages m ON m.id = a.message_id
+		WHERE a.id = ? AND `+ownedMessage, id, ownerID).
+		Scan(&a.ID, &filename, &contentType, &a.Size, &a.Data)
+	a.Filename, a.ContentType = filename.String, contentType.String
+	return a, notFound(err)
+}
+
+func (s *sqlMessageStore) MarkSeen(id, ownerID int64) error {
+	return mustAffect(s.db.Exec("UPDATE messages SET seen = TRUE WHERE id = ? AND EXISTS(SELECT 1 FROM emails e WHERE e.email = messages.alias AND e.owner_id = ?)", id, ownerID))
+}
+
+func (s *sqlMessageStore) Delete(id, ownerID int64) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		var owned bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM messages m WHERE m.id = ? AND "+ownedMessage+")", id, ownerID).Scan(&owned); err != nil {
+			return err
+		}
+		if !owned {
+			return ErrNotFound
+		}
+		if _, err := tx.Exec("DELETE FROM message_attachments WHERE message_id = ?", id); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM messages WHERE id = ?", id)
+		return err
+	})
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"tempmail/internal/secrets"
+)
+
+type sqlSecretStore struct {
+	db *sql.DB
+}
+
+// secretColumns são as colunas que guardam segredos cifrados
+var secretColumns = []struct{ table, key, column string }{
+	{"domains", "id", "cf_token"},
+	{"users", "id", "totp_secret"},
+}
+
+func (s *sqlSecretStore) Seal() (int, error) {
+	count := 0
+	err := s.each(func(value string) (string, bool, error) {
+		if secrets.IsEncrypted(value) {
+			_, err := secrets.Decrypt(value)
+			return "", false, err
+		}
+		sealed, err := secrets.Encrypt(value)
+		count++
+		return sealed, true, err
+	})
+	return count, err
+}
+
+func (s *sqlSecretStore) Rotate(newKey []byte) (int, error) {
+	count := 0
+	err := s.each(func(value string) (string, bool, error) {
+		plain, err := secrets.Decrypt(value)
+		if err != nil {
+			return "", false, err
+		}
+		sealed, err := secrets.EncryptWith(newKey, plain)
+		count++
+		return sealed, true, err
+	})
+	return count, err
+}
+
+// each aplica fn a cada segredo não vazio, gravando o novo valor quando fn pede,
+// tudo em uma transação: ou todos os segredos mudam ou nenhum
+func (s *sqlSecretStore) each(fn func(value string) (string, bool, error)) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		for _, c := range secretColumns {
+			rows, err := tx.Query(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s IS NOT NULL AND %s <> ''", c.key, c.column, c.table, c.column, c.column))
+			if err != nil {
+				return err
+			}
+			updates := make(map[int64]string)
+			for rows.Next() {
+				var id int64
+				var value string
+				if err := rows.Scan(&id, &value); err != nil {
+					rows.Close()
+					return err
+				}
+				next, changed, err := fn(value)
+				if err != nil {
+					rows.Close()
+					return fmt.Errorf("%s.%s (id %d): %w", c.table, c.column, id, err)
+				}
+				if changed {
+					updates[id] = next
+				}
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for id, value := range updates {
+				if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", c.table, c.column, c.key), value, id); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
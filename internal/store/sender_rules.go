@@ -0,0 +1,72 @@
+package store
+
+import (
+	"database/sql"
+	"tempmail/internal/models"
+)
+
+// replaceSenderRules troca a lista de um tipo (allow ou block) do alias
+func replaceSenderRules(tx *sql.Tx, alias, kind string, patterns []string) error {
+	if _, err := tx.Exec("DELETE FROM sender_rules WHERE alias = ? AND kind = ?", alias, kind); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		if _, err := tx.Exec("INSERT INTO sender_rules (alias, kind, pattern) VALUES (?, ?, ?)", alias, kind, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlEmailStore) LockSender(alias, pattern string) (bool, error) {
+	res, err := s.db.Exec(`
+		INSERT INTO sender_rules (alias, kind, pattern)
+		SELECT ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM sender_rules WHERE alias = ? AND kind = ?)`,
+		alias, models.SenderAllow, pattern, alias, models.SenderAllow)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// attachSenderRules completa as listas de remetentes a partir de uma consulta
+// (alias, kind, pattern)
+func attachSenderRules(db *sql.DB, list []models.EmailEntry, query string, args ...interface{}) error {
+	if len(list) == 0 {
+		return nil
+	}
+	byAlias := make(map[string]*models.EmailEntry, len(list))
+	for i := range list {
+		byAlias[list[i].Email] = &list[i]
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias, kind, pattern string
+		if err := rows.Scan(&alias, &kind, &pattern); err != nil {
+			return err
+		}
+		e, ok := byAlias[alias]
+		if !ok {
+			continue
+		}
+		switch kind {
+		case models.SenderAllow:
+			e.AllowSenders = append(e.AllowSenders, pattern)
+		case models.SenderBlock:
+			e.BlockSenders = append(e.BlockSenders, pattern)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"tempmail/internal/models"
+	"time"
+)
+
+type sqlSessionStore struct {
+	db *sql.DB
+}
+
+const sessionColumns = "id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked"
+
+func scanSession(row rowScanner) (models.Session, error) {
+	var s models.Session
+	var ua, ip sql.NullString
+	var revoked sql.NullBool
+	err := row.Scan(&s.ID, &s.UserID, &ua, &ip, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &revoked)
+	s.UserAgent = ua.String
+	s.IP = ip.String
+	s.Revoked = revoked.Bool
+	return s, err
+}
+
+func (s *sqlSessionStore) Create(userID int64, refreshHash, userAgent, ip string, expiresAt time.Time) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	now := time.Now()
+	_, err := s.db.Exec(`
+		INSERT INTO sessions (id, user_id, refresh_hash, user_agent, ip, created_at, last_seen_at, expires_at, revoked)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, FALSE)`,
+		id, userID, refreshHash, userAgent, ip, now, now, expiresAt)
+	return id, err
+}
+
+func (s *sqlSessionStore) Get(id string) (models.Session, error) {
+	sess, err := scanSession(s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id))
+	return sess, notFound(err)
+}
+
+func (s *sqlSessionStore) FindByRefresh(hash string) (models.Session, bool, error) {
+	sess, err := scanSession(s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE refresh_hash = ?", hash))
+	if !errors.Is(err, sql.ErrNoRows) {
+		return sess, false, err
+	}
+	sess, err = scanSession(s.db.QueryRow(`
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE id = (SELECT session_id FROM session_refresh_history WHERE refresh_hash = ?)`, hash))
+	return sess, err == nil, notFound(err)
+}
+
+func (s *sqlSessionStore) Rotate(id, oldHash, newHash, ip string, expiresAt time.Time) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		var current string
+		if err := tx.QueryRow("SELECT refresh_hash FROM sessions WHERE id = ?", id).Scan(&current); err != nil {
+			return notFound(err)
+		}
+		if oldHash != "" && oldHash != current {
+			return ErrRefreshReused
+		}
+
+		now := time.Now()
+		err := mustAffect(tx.Exec(`
+			UPDATE sessions SET refresh_hash = ?, ip = ?, last_seen_at = ?, expires_at = ?
+			WHERE id = ? AND refresh_hash = ?`, newHash, ip, now, expiresAt, id, current))
+		if errors.Is(err, ErrNotFound) {
+			return ErrRefreshReused
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO session_refresh_history (refresh_hash, session_id, rotated_at) VALUES (?, ?, ?)", current, id, now)
+		return err
+	})
+}
+
+func (s *sqlSessionStore) Touch(id string) error {
+	now := time.Now()
+	_, err := s.db.Exec("UPDATE sessions SET last_seen_at = ? WHERE id = ? AND last_seen_at < ?", now, id, now.Add(-time.Minute))
+	return err
+}
+
+func (s *sqlSessionStore) Revoke(id string) error {
+	return mustAffect(s.db.Exec("UPDATE sessions SET revoked = TRUE WHERE id = ?", id))
+}
+
+func (s *sqlSessionStore) RevokeUser(userID int64, exceptID string) error {
+	_, err := s.db.Exec("UPDATE sessions SET revoked = TRUE WHERE user_id = ? AND id <> ?", userID, exceptID)
+	return err
+}
+
+func (s *sqlSessionStore) List(userID int64) ([]models.Session, error) {
+	rows, err := s.db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND revoked = FALSE AND expires_at > ? ORDER BY last_seen_at DESC", userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Session{}
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, sess)
+	}
+	return list, rows.Err()
+}
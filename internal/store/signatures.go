@@ -0,0 +1,41 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+type sqlSignatureStore struct {
+	db *sql.DB
+}
+
+func (s *sqlSignatureStore) Claim(signature string, expiresAt time.Time) (bool, error) {
+	var claimed bool
+	err := withTx(s.db, func(tx *sql.Tx) error {
+		// Uma assinatura vencida que a varredura ainda não apagou não impede o registro
+		if _, err := tx.Exec("DELETE FROM inbound_signatures WHERE signature = ? AND expires_at < ?", signature, time.Now()); err != nil {
+			return err
+		}
+		res, err := tx.Exec("INSERT INTO inbound_signatures (signature, expires_at) VALUES (?, ?) ON CONFLICT (signature) DO NOTHING", signature, expiresAt)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		claimed = n == 1
+		return err
+	})
+	return claimed, err
+}
+
+func (s *sqlSignatureStore) Release(signature string) error {
+	_, err := s.db.Exec("DELETE FROM inbound_signatures WHERE signature = ?", signature)
+	return err
+}
+
+func (s *sqlSignatureStore) Sweep(now time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM inbound_signatures WHERE expires_at < ?", now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
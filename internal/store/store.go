@@ -0,0 +1,281 @@
+// Package store reúne os repositórios tipados de acesso ao banco. Os handlers e o
+// agendador dependem apenas das interfaces, o que permite trocar o banco (ou usar um
+// SQLite em memória nos testes) chamando Use com outra conexão.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"tempmail/internal/models"
+	"time"
+)
+
+var (
+	// ErrNotFound indica que o registro pedido não existe (ou não pertence ao usuário)
+	ErrNotFound = errors.New("registro não encontrado")
+	// ErrConflict indica violação de unicidade (ex: username já usado)
+	ErrConflict = errors.New("registro já existe")
+	// ErrRefreshReused indica que o refresh token apresentado já foi trocado por outro
+	ErrRefreshReused = errors.New("refresh token reutilizado")
+)
+
+// EmailStore guarda os aliases, seus destinos e suas tags. TTL e ExpiresAt são devolvidos como estão
+// no banco (0 e nil quando ausentes); o cálculo dos padrões fica com quem consome.
+type EmailStore interface {
+	Get(id string) (models.EmailEntry, error)
+	FindByAddress(email string) (models.EmailEntry, error)
+	Exists(email string) (bool, error)
+	// Save cria ou recria o alias (mesmo endereço) e substitui seus destinos e tags, tudo em
+	// uma transação. Sem e.Destinations, o único destino é e.Destination.
+	Save(e models.EmailEntry, tags []string) error
+	ListByOwner(ownerID int64, activeOnly bool) ([]models.EmailEntry, error)
+	// ListActive devolve todos os aliases ativos, sem tags nem a lista de destinos (usado pelo agendador)
+	ListActive() ([]models.EmailEntry, error)
+	// ListWithRule devolve, sem tags, os aliases que têm regra na Cloudflare (ativos e queimados)
+	ListWithRule() ([]models.EmailEntry, error)
+	HasRule(id string) (bool, error)
+	// Update altera destinos, tags, listas de remetentes e o uso único de um alias do dono em
+	// uma transação
+	Update(id string, ownerID int64, u EmailUpdate) error
+	SetPinned(id string, ownerID int64, pinned bool) error
+	SetCreatedAt(id string, t time.Time) error
+	SetExpiry(id string, expiresAt *time.Time) error
+	// RecordHit conta uma mensagem recebida pelo alias (SMTP embutido ou Worker)
+	RecordHit(id string, at time.Time) error
+	// LockSender grava pattern como único remetente permitido se o alias ainda não tem lista
+	// de permitidos; false se já tinha (outra entrega travou antes)
+	LockSender(alias, pattern string) (bool, error)
+	// SetState muda o estado mantendo a coluna active em sincronia
+	SetState(id string, state string) error
+	Deactivate(id string) error
+	// SetExpireReason registra por que o alias foi expirado automaticamente (exibido no
+	// histórico); Save limpa o motivo ao recriar o alias
+	SetExpireReason(id, reason string) error
+}
+
+// EmailUpdate altera apenas os campos não nulos; cada um substitui a lista inteira
+type EmailUpdate struct {
+	Destinations *[]string
+	Tags         *[]string
+	AllowSenders *[]string
+	BlockSenders *[]string
+	SenderLock   *bool
+}
+
+// TagStore guarda as tags de cada usuário
+type TagStore interface {
+	List(ownerID int64) ([]models.Tag, error)
+}
+
+// UserStore guarda usuários, o segundo fator (TOTP) e os códigos de recuperação
+type UserStore interface {
+	Count() (int, error)
+	Get(username string) (models.User, error)
+	GetByID(id int64) (models.User, error)
+	List() ([]models.User, error)
+	// Create insere o usuário com a senha já em hash (u.Password)
+	Create(u models.User) (int64, error)
+	Update(id int64, u UserUpdate) error
+	SetPassword(id int64, hash string) error
+	CountActiveAdmins() (int, error)
+
+	TOTP(id int64) (TOTPState, error)
+	SetTOTPSecret(id int64, secret string) error
+	// EnableTOTP ativa o 2FA e troca os códigos de recuperação de uma só vez
+	EnableTOTP(id int64, step int64, recoveryHashes []string) error
+	DisableTOTP(id int64) error
+	// ConsumeTOTPStep registra o passo usado; false se ele (ou um posterior) já foi usado
+	ConsumeTOTPStep(id int64, step int64) (bool, error)
+	// UseRecoveryCode marca o código como usado; false se não existir ou já tiver sido usado
+	UseRecoveryCode(id int64, hash string) (bool, error)
+	RecoveryCodesLeft(id int64) (int, error)
+	// PasswordChangedAt devolve quando a senha foi trocada pela última vez (nil se nunca)
+	PasswordChangedAt(username string) (*time.Time, error)
+}
+
+// SessionStore guarda os logins do painel (um por dispositivo) e seus refresh tokens
+type SessionStore interface {
+	// Create registra um novo login e devolve o ID da sessão
+	Create(userID int64, refreshHash, userAgent, ip string, expiresAt time.Time) (string, error)
+	Get(id string) (models.Session, error)
+	// FindByRefresh localiza a sessão dona do refresh token. Se o token já tiver sido
+	// rotacionado (reuso), reused é true: sinal de vazamento, e a sessão deve ser revogada.
+	// Todos os tokens já trocados ficam no histórico, não só o anterior.
+	FindByRefresh(hash string) (s models.Session, reused bool, err error)
+	// Rotate troca o refresh token, guarda o anterior no histórico e estende a validade.
+	// oldHash é o token apresentado pelo cliente: se ele já não for o atual (outra requisição
+	// trocou antes), devolve ErrRefreshReused. Vazio troca o token atual, qualquer que seja
+	// (troca de senha, em que o cliente não apresenta o refresh token).
+	Rotate(id, oldHash, newHash, ip string, expiresAt time.Time) error
+	// Touch atualiza o "visto por último", no máximo uma vez por minuto
+	Touch(id string) error
+	Revoke(id string) error
+	// RevokeUser revoga todas as sessões do usuário, exceto exceptID (se informado)
+	RevokeUser(userID int64, exceptID string) error
+	// List devolve as sessões ativas do usuário
+	List(userID int64) ([]models.Session, error)
+}
+
+// APIKeyStore guarda as chaves de API (somente o hash do segredo)
+type APIKeyStore interface {
+	// GetByHash busca uma chave ativa pelo hash do segredo
+	GetByHash(hash string) (models.APIKey, error)
+	// List lista as chaves do usuário, incluindo as revogadas
+	List(userID int64) ([]models.APIKey, error)
+	Create(k models.APIKey, hash string) (int64, error)
+	// Revoke revoga a chave do usuário (ErrNotFound se ela não existir)
+	Revoke(id string, userID int64) error
+	// Touch registra o último uso da chave, no máximo uma vez por minuto
+	Touch(id int64) error
+}
+
+// UserUpdate altera apenas os campos não nulos
+type UserUpdate struct {
+	Role         *string
+	Disabled     *bool
+	FullName     *string
+	PasswordHash *string
+	ResetTOTP    bool
+}
+
+// TOTPState é a configuração de 2FA de um usuário
+type TOTPState struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+// DomainStore guarda os domínios (zonas da Cloudflare) disponíveis para os aliases.
+// O token é gravado cifrado e devolvido já aberto.
+type DomainStore interface {
+	List() ([]models.Domain, error)
+	Get(id int64) (models.Domain, error)
+	FindByName(domain string) (models.Domain, error)
+	// Default devolve ErrNotFound enquanto nenhum domínio foi cadastrado
+	Default() (models.Domain, error)
+	// Save cria (ID 0) ou atualiza o domínio; o primeiro cadastrado vira o padrão
+	Save(d models.Domain) (int64, error)
+	SetDefault(id int64) error
+	SetAccountID(id int64, accountID string) error
+	// Delete recusa (ErrConflict) domínios que ainda têm aliases ativos ou queimados
+	Delete(id int64) error
+}
+
+// AuditStore guarda o registro de alterações administrativas
+type AuditStore interface {
+	Record(e models.AuditEntry) error
+	// List devolve as entradas mais recentes primeiro
+	List(limit int) ([]models.AuditEntry, error)
+}
+
+// MessageStore guarda a caixa de entrada dos aliases. As consultas com ownerID só enxergam
+// mensagens de aliases do usuário (ErrNotFound para as demais).
+type MessageStore interface {
+	// Save grava a mensagem e seus anexos em uma transação
+	Save(m models.Message) (int64, error)
+	// List devolve os resumos, mais recentes primeiro; alias vazio = todos os aliases do dono
+	List(ownerID int64, alias string, limit int) ([]models.Message, error)
+	// Get devolve a mensagem completa, com os anexos sem o conteúdo
+	Get(id, ownerID int64) (models.Message, error)
+	Attachment(id, ownerID int64) (models.Attachment, error)
+	MarkSeen(id, ownerID int64) error
+	Delete(id, ownerID int64) error
+}
+
+// DeliveryStore guarda os eventos de entrega dos aliases; as estatísticas de cada alias
+// (contagem, último recebimento, remetentes) vêm em EmailStore.ListByOwner
+type DeliveryStore interface {
+	// Record grava o evento; devolve false se o ExternalID já tinha sido importado
+	Record(d models.Delivery) (bool, error)
+	// Latest devolve o horário do evento mais recente da origem no domínio (ErrNotFound se nenhum)
+	Latest(source, domain string) (time.Time, error)
+}
+
+// SecretStore percorre todos os segredos cifrados do banco (tokens da Cloudflare e
+// segredos TOTP) de uma só vez
+type SecretStore interface {
+	// Seal cifra os valores ainda em texto puro e confere se os demais abrem com a
+	// chave mestra atual. Devolve quantos foram cifrados.
+	Seal() (int, error)
+	// Rotate recifra todos os segredos com a nova chave mestra em uma transação
+	Rotate(newKey []byte) (int, error)
+}
+
+// SignatureStore guarda as assinaturas de entregas do Email Worker já aceitas, para que uma
+// entrega capturada não seja aceita de novo por nenhuma instância
+type SignatureStore interface {
+	// Claim registra a assinatura até expiresAt; false se ela já estava registrada e ainda
+	// não venceu
+	Claim(signature string, expiresAt time.Time) (bool, error)
+	// Release apaga a assinatura, liberando a mesma entrega para nova tentativa
+	Release(signature string) error
+	// Sweep apaga as assinaturas vencidas antes de now e devolve quantas foram apagadas
+	Sweep(now time.Time) (int64, error)
+}
+
+// Repositórios em uso, definidos por Use
+var (
+	Emails     EmailStore
+	Tags       TagStore
+	Users      UserStore
+	Domains    DomainStore
+	Sessions   SessionStore
+	APIKeys    APIKeyStore
+	Audit      AuditStore
+	Messages   MessageStore
+	Deliveries DeliveryStore
+	Secrets    SecretStore
+	Signatures SignatureStore
+)
+
+// Use liga os repositórios a uma conexão aberta
+func Use(db *sql.DB) {
+	Emails = &sqlEmailStore{db: db}
+	Tags = &sqlTagStore{db: db}
+	Users = &sqlUserStore{db: db}
+	Domains = &sqlDomainStore{db: db}
+	Sessions = &sqlSessionStore{db: db}
+	APIKeys = &sqlAPIKeyStore{db: db}
+	Audit = &sqlAuditStore{db: db}
+	Messages = &sqlMessageStore{db: db}
+	Deliveries = &sqlDeliveryStore{db: db}
+	Secrets = &sqlSecretStore{db: db}
+	Signatures = &sqlSignatureStore{db: db}
+}
+
+// withTx executa fn em uma transação, confirmando apenas se não houver erro
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// notFound traduz sql.ErrNoRows para ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// mustAffect devolve ErrNotFound quando o comando não alterou nenhuma linha
+func mustAffect(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
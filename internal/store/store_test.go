@@ -0,0 +1,348 @@
+package store_test
+
+import (
+	"database/sql"
+	"errors"
+	"tempmail/internal/database"
+	"tempmail/internal/models"
+	"tempmail/internal/secrets"
+	"tempmail/internal/store"
+	"tempmail/internal/testutil"
+	"testing"
+	"time"
+)
+
+// Os mesmos cenários rodam nos dois dialetos: no SQLite sempre e no PostgreSQL quando
+// DATABASE_URL aponta para um
+func TestStoreSQLite(t *testing.T)   { runStore(t, testutil.DB) }
+func TestStorePostgres(t *testing.T) { runStore(t, testutil.Postgres) }
+
+func runStore(t *testing.T, open func(testing.TB) *sql.DB) {
+	for _, tc := range []struct {
+		name string
+		fn   func(t *testing.T)
+	}{
+		{"usuarios", testUsers},
+		{"aliases", testAliases},
+		{"sessoes", testSessions},
+		{"dominios", testDomains},
+		{"mensagens", testMessages},
+		{"entregas", testDeliveries},
+		{"segredos", testSecrets},
+		{"assinaturas", testSignatures},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			open(t)
+			tc.fn(t)
+		})
+	}
+}
+
+func testUsers(t *testing.T) {
+	u := testutil.User(t, "ana", "segredo123", "admin")
+	if _, err := store.Users.Create(models.User{Username: "ana", Password: "x", Role: "user", CreatedAt: time.Now()}); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("username repetido: %v, esperado ErrConflict", err)
+	}
+	got, err := store.Users.GetByID(u.ID)
+	if err != nil || got.Username != "ana" || got.Role != "admin" {
+		t.Fatalf("GetByID: %+v, %v", got, err)
+	}
+	if _, err := store.Users.Get("ninguem"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("usuário inexistente: %v, esperado ErrNotFound", err)
+	}
+	if at, err := store.Users.PasswordChangedAt("ana"); err != nil || at != nil {
+		t.Fatalf("PasswordChangedAt antes da troca: %v, %v", at, err)
+	}
+}
+
+func testAliases(t *testing.T) {
+	owner := testutil.User(t, "ana", "segredo123", "user")
+	e := models.EmailEntry{ID: "regra-1", Email: "caixa@exemplo.test", Destination: "a@dest.test", CreatedAt: time.Now(), Active: true, OwnerID: owner.ID}
+	if err := store.Emails.Save(e, []string{"compras", "viagem"}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.Emails.Exists(e.Email); err != nil || !ok {
+		t.Fatalf("Exists: %v, %v", ok, err)
+	}
+
+	if err := store.Emails.SetPinned(e.ID, owner.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Emails.SetPinned(e.ID, owner.ID+1, false); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("alias de outro dono: %v, esperado ErrNotFound", err)
+	}
+
+	list, err := store.Emails.ListByOwner(owner.ID, true)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListByOwner: %+v, %v", list, err)
+	}
+	if got := list[0]; !got.Pinned || got.Destination != e.Destination || len(got.Tags) != 2 {
+		t.Fatalf("alias após as alterações: %+v", got)
+	}
+	if tags, err := store.Tags.List(owner.ID); err != nil || len(tags) != 2 {
+		t.Fatalf("tags do dono: %+v, %v", tags, err)
+	}
+
+	if err := store.Emails.Deactivate(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if active, err := store.Emails.ListActive(); err != nil || len(active) != 0 {
+		t.Fatalf("ListActive após desativar: %+v, %v", active, err)
+	}
+	if _, err := store.Emails.Get("inexistente"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("alias inexistente: %v, esperado ErrNotFound", err)
+	}
+}
+
+func testSessions(t *testing.T) {
+	u := testutil.User(t, "ana", "segredo123", "user")
+	id, err := store.Sessions.Create(u.ID, "hash-1", "teste", "127.0.0.1", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range [][2]string{{"hash-1", "hash-2"}, {"hash-2", "hash-3"}} {
+		if err := store.Sessions.Rotate(id, step[0], step[1], "127.0.0.1", time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s, reused, err := store.Sessions.FindByRefresh("hash-3"); err != nil || reused || s.ID != id {
+		t.Fatalf("refresh atual: %+v, %v, %v", s, reused, err)
+	}
+	for _, old := range []string{"hash-1", "hash-2"} {
+		if s, reused, err := store.Sessions.FindByRefresh(old); err != nil || !reused || s.ID != id {
+			t.Fatalf("refresh %s já trocado: reused=%v, %v", old, reused, err)
+		}
+	}
+	if err := store.Sessions.Rotate(id, "hash-2", "hash-4", "127.0.0.1", time.Now().Add(time.Hour)); !errors.Is(err, store.ErrRefreshReused) {
+		t.Fatalf("rotação com token antigo: %v, esperado ErrRefreshReused", err)
+	}
+	if _, _, err := store.Sessions.FindByRefresh("nenhum"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("refresh desconhecido: %v, esperado ErrNotFound", err)
+	}
+
+	if err := store.Sessions.Revoke(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Sessions.Revoke("inexistente"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("revogar sessão inexistente: %v, esperado ErrNotFound", err)
+	}
+	if list, err := store.Sessions.List(u.ID); err != nil || len(list) != 0 {
+		t.Fatalf("List após revogar: %+v, %v", list, err)
+	}
+}
+
+func testDomains(t *testing.T) {
+	if _, err := store.Domains.Default(); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("padrão sem domínios: %v, esperado ErrNotFound", err)
+	}
+	first := testutil.Domain(t, "exemplo.test")
+	second := testutil.Domain(t, "outro.test")
+	if !first.IsDefault || second.IsDefault {
+		t.Fatalf("o primeiro domínio cadastrado deveria ser o padrão: %+v, %+v", first, second)
+	}
+	if _, err := store.Domains.Save(models.Domain{Domain: "outro.test", ZoneID: "z", CFToken: "t"}); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("domínio repetido: %v, esperado ErrConflict", err)
+	}
+
+	if err := store.Domains.SetDefault(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := store.Domains.Default(); err != nil || d.ID != second.ID {
+		t.Fatalf("padrão após SetDefault: %+v, %v", d, err)
+	}
+	if d, err := store.Domains.FindByName("exemplo.test"); err != nil || d.IsDefault {
+		t.Fatalf("domínio anterior continua padrão: %+v, %v", d, err)
+	}
+
+	if err := store.Domains.SetAccountID(first.ID, "conta-1"); err != nil {
+		t.Fatal(err)
+	}
+	owner := testutil.User(t, "ana", "segredo123", "user")
+	e := models.EmailEntry{ID: "regra-1", Email: "caixa@exemplo.test", Destination: "a@dest.test", CreatedAt: time.Now(), Active: true, OwnerID: owner.ID, DomainID: first.ID}
+	if err := store.Emails.Save(e, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Domains.Delete(first.ID); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("remover domínio com alias ativo: %v, esperado ErrConflict", err)
+	}
+	if err := store.Emails.Deactivate(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Domains.Delete(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := store.Domains.List(); err != nil || len(list) != 1 || list[0].ID != second.ID {
+		t.Fatalf("domínios após remover: %+v, %v", list, err)
+	}
+}
+
+func testMessages(t *testing.T) {
+	owner := testutil.User(t, "ana", "segredo123", "user")
+	dom := testutil.Domain(t, "exemplo.test")
+	e := testutil.Alias(t, models.EmailEntry{Email: "caixa@exemplo.test", OwnerID: owner.ID, DomainID: dom.ID})
+
+	id, err := store.Messages.Save(models.Message{
+		Alias:       e.Email,
+		MailFrom:    "joao@remetente.test",
+		Subject:     "oi",
+		Text:        "olá",
+		ReceivedAt:  time.Now(),
+		Attachments: []models.Attachment{{Filename: "a.txt", ContentType: "text/plain", Size: 3, Data: []byte("abc")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := store.Messages.Get(id, owner.ID)
+	if err != nil || m.Text != "olá" || len(m.Attachments) != 1 {
+		t.Fatalf("Get: %+v, %v", m, err)
+	}
+	if _, err := store.Messages.Get(id, owner.ID+1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("mensagem de outro dono: %v, esperado ErrNotFound", err)
+	}
+	if a, err := store.Messages.Attachment(m.Attachments[0].ID, owner.ID); err != nil || string(a.Data) != "abc" {
+		t.Fatalf("Attachment: %q, %v", a.Data, err)
+	}
+
+	if err := store.Messages.Delete(id, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := store.Messages.List(owner.ID, "", 10); err != nil || len(list) != 0 {
+		t.Fatalf("List após apagar: %+v, %v", list, err)
+	}
+}
+
+func testDeliveries(t *testing.T) {
+	owner := testutil.User(t, "ana", "segredo123", "user")
+	dom := testutil.Domain(t, "exemplo.test")
+	e := testutil.Alias(t, models.EmailEntry{Email: "caixa@exemplo.test", OwnerID: owner.ID, DomainID: dom.ID})
+	now := time.Now().Truncate(time.Second)
+
+	d := models.Delivery{Alias: e.Email, Sender: "joao@remetente.test", SenderDomain: "remetente.test", Status: models.DeliveryDelivered, Source: models.SourceCloudflare, ExternalID: "evt-1", ReceivedAt: now.Add(-time.Hour)}
+	if ok, err := store.Deliveries.Record(d); err != nil || !ok {
+		t.Fatalf("Record: %v, %v", ok, err)
+	}
+	if ok, err := store.Deliveries.Record(d); err != nil || ok {
+		t.Fatalf("Record repetido: %v, %v (esperado false)", ok, err)
+	}
+	// Sem ExternalID não há deduplicação; o domínio inválido é descartado
+	for _, sender := range []string{"bia@remetente.test", `x@"><img>`} {
+		local := models.Delivery{Alias: e.Email, Sender: sender, SenderDomain: sender[2:], Status: models.DeliveryDelivered, Source: models.SourceSMTP, ReceivedAt: now}
+		if ok, err := store.Deliveries.Record(local); err != nil || !ok {
+			t.Fatalf("Record %s: %v, %v", sender, ok, err)
+		}
+	}
+
+	if got, err := store.Deliveries.Latest(models.SourceCloudflare, "Exemplo.test"); err != nil || !got.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("Latest: %v, %v", got, err)
+	}
+	// O domínio é comparado por inteiro, sem curingas
+	for _, domain := range []string{"exempl_.test", "%.test", "plo.test"} {
+		if _, err := store.Deliveries.Latest(models.SourceCloudflare, domain); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Latest(%q): %v, esperado ErrNotFound", domain, err)
+		}
+	}
+
+	list, err := store.Emails.ListByOwner(owner.ID, true)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListByOwner: %+v, %v", list, err)
+	}
+	if got := list[0]; got.MessageCount != 3 || len(got.SenderDomains) != 1 || got.SenderDomains[0] != "remetente.test" ||
+		got.LastReceivedAt == nil || !got.LastReceivedAt.Equal(now) {
+		t.Errorf("estatísticas: count=%d domains=%v last=%v", got.MessageCount, got.SenderDomains, got.LastReceivedAt)
+	}
+}
+
+func testSecrets(t *testing.T) {
+	oldKey := testutil.MasterKey(t)
+	ana := testutil.User(t, "ana", "segredo123", "user")
+	bia := testutil.User(t, "bia", "segredo123", "user")
+	dom := testutil.Domain(t, "exemplo.test")
+	if err := store.Users.SetTOTPSecret(ana.ID, "SEGREDOANA"); err != nil {
+		t.Fatal(err)
+	}
+	// Valor gravado antes da cifragem existir
+	if _, err := database.DB.Exec("UPDATE users SET totp_secret = ? WHERE id = ?", "SEGREDOBIA", bia.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := store.Secrets.Seal(); err != nil || n != 1 {
+		t.Fatalf("Seal: %d, %v; esperado 1 segredo cifrado", n, err)
+	}
+
+	encoded, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := secrets.ParseKey(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Secrets.Rotate(newKey); err != nil || n != 3 {
+		t.Fatalf("Rotate: %d, %v; esperado 3 segredos", n, err)
+	}
+
+	// Nenhuma linha ficou com a chave antiga
+	if _, err := store.Users.TOTP(ana.ID); !errors.Is(err, secrets.ErrWrongKey) {
+		t.Fatalf("segredo aberto com a chave antiga: %v", err)
+	}
+	if err := secrets.SetMasterKey(newKey); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := store.Domains.Get(dom.ID); err != nil || d.CFToken != "token-exemplo.test" {
+		t.Fatalf("token após a rotação: %q, %v", d.CFToken, err)
+	}
+	for id, want := range map[int64]string{ana.ID: "SEGREDOANA", bia.ID: "SEGREDOBIA"} {
+		if st, err := store.Users.TOTP(id); err != nil || st.Secret != want {
+			t.Fatalf("segredo TOTP após a rotação: %q, %v", st.Secret, err)
+		}
+	}
+
+	// Com a chave atual errada a rotação falha sem alterar nada
+	if err := secrets.SetMasterKey(oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Secrets.Rotate(oldKey); err == nil {
+		t.Fatal("Rotate com a chave atual errada não falhou")
+	}
+	if err := secrets.SetMasterKey(newKey); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Secrets.Seal(); err != nil || n != 0 {
+		t.Fatalf("Seal após rotação falha: %d, %v", n, err)
+	}
+}
+
+func testSignatures(t *testing.T) {
+	now := time.Now()
+	claim := func(signature string, until time.Time) bool {
+		t.Helper()
+		ok, err := store.Signatures.Claim(signature, until)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if !claim("aaa", now.Add(time.Minute)) || claim("aaa", now.Add(time.Minute)) {
+		t.Fatal("assinatura registrada duas vezes")
+	}
+	// Liberada, a mesma entrega pode ser tentada de novo
+	if err := store.Signatures.Release("aaa"); err != nil {
+		t.Fatal(err)
+	}
+	if !claim("aaa", now.Add(time.Minute)) {
+		t.Fatal("assinatura liberada continuou registrada")
+	}
+
+	// Vencida vale como livre mesmo antes da varredura
+	if !claim("bbb", now.Add(-time.Minute)) || !claim("bbb", now.Add(time.Minute)) {
+		t.Fatal("assinatura vencida impediu o registro")
+	}
+	claim("ccc", now.Add(-time.Minute))
+	if n, err := store.Signatures.Sweep(now); err != nil || n != 1 {
+		t.Fatalf("Sweep: %d, %v; esperado 1", n, err)
+	}
+	if claim("aaa", now.Add(time.Minute)) || claim("bbb", now.Add(time.Minute)) {
+		t.Fatal("Sweep apagou assinaturas em vigor")
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"math/big"
+	"tempmail/internal/models"
+)
+
+// coresTags é a paleta sorteada para tags novas
+var coresTags = []string{"#ef4444", "#f97316", "#f59e0b", "#84cc16", "#10b981", "#06b6d4", "#3b82f6", "#6366f1", "#8b5cf6", "#d946ef", "#f43f5e"}
+
+type sqlTagStore struct {
+	db *sql.DB
+}
+
+func (s *sqlTagStore) List(ownerID int64) ([]models.Tag, error) {
+	rows, err := s.db.Query("SELECT id, name, color FROM tags WHERE owner_id = ? ORDER BY name", ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Color); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// ensureTag devolve o ID da tag do dono, criando-a com uma cor sorteada se não existir
+func ensureTag(tx *sql.Tx, ownerID int64, name string) (int64, error) {
+	var id int64
+	err := tx.QueryRow("SELECT id FROM tags WHERE name = ? AND owner_id = ?", name, ownerID).Scan(&id)
+	if err == nil || !errors.Is(err, sql.ErrNoRows) {
+		return id, err
+	}
+
+	idx, _ := rand.Int(rand.Reader, big.NewInt(int64(len(coresTags))))
+	err = tx.QueryRow("INSERT INTO tags (owner_id, name, color) VALUES (?, ?, ?) RETURNING id", ownerID, name, coresTags[idx.Int64()]).Scan(&id)
+	return id, err
+}
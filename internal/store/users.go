@@ -0,0 +1,221 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"tempmail/internal/models"
+	"tempmail/internal/secrets"
+	"time"
+)
+
+type sqlUserStore struct {
+	db *sql.DB
+}
+
+const userColumns = "id, username, password, full_name, created_at, role, disabled"
+
+func scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	var fullName, role sql.NullString
+	var disabled sql.NullBool
+	err := row.Scan(&u.ID, &u.Username, &u.Password, &fullName, &u.CreatedAt, &role, &disabled)
+	u.FullName = fullName.String
+	u.Role = role.String
+	if u.Role == "" {
+		u.Role = models.RoleUser
+	}
+	u.Disabled = disabled.Bool
+	return u, err
+}
+
+func (s *sqlUserStore) Count() (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
+
+func (s *sqlUserStore) Get(username string) (models.User, error) {
+	u, err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ?", username))
+	return u, notFound(err)
+}
+
+func (s *sqlUserStore) GetByID(id int64) (models.User, error) {
+	u, err := scanUser(s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+	return u, notFound(err)
+}
+
+func (s *sqlUserStore) List() ([]models.User, error) {
+	rows, err := s.db.Query("SELECT " + userColumns + " FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqlUserStore) Create(u models.User) (int64, error) {
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	var id int64
+	err := s.db.QueryRow(
+		"INSERT INTO users (username, password, full_name, created_at, role, disabled) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		u.Username, u.Password, u.FullName, u.CreatedAt, u.Role, u.Disabled,
+	).Scan(&id)
+	if err != nil && isUniqueViolation(err) {
+		return 0, ErrConflict
+	}
+	return id, err
+}
+
+func (s *sqlUserStore) Update(id int64, u UserUpdate) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+		if u.Role != nil {
+			if _, err := tx.Exec("UPDATE users SET role = ? WHERE id = ?", *u.Role, id); err != nil {
+				return err
+			}
+		}
+		if u.Disabled != nil {
+			if _, err := tx.Exec("UPDATE users SET disabled = ? WHERE id = ?", *u.Disabled, id); err != nil {
+				return err
+			}
+		}
+		if u.FullName != nil {
+			if _, err := tx.Exec("UPDATE users SET full_name = ? WHERE id = ?", *u.FullName, id); err != nil {
+				return err
+			}
+		}
+		if u.PasswordHash != nil {
+			if _, err := tx.Exec("UPDATE users SET password = ?, password_changed_at = ? WHERE id = ?", *u.PasswordHash, time.Now(), id); err != nil {
+				return err
+			}
+		}
+		if u.ResetTOTP {
+			return clearTOTP(tx, id)
+		}
+		return nil
+	})
+}
+
+// SetPassword troca a senha e registra o momento, invalidando tokens emitidos antes
+func (s *sqlUserStore) SetPassword(id int64, hash string) error {
+	return mustAffect(s.db.Exec("UPDATE users SET password = ?, password_changed_at = ? WHERE id = ?", hash, time.Now(), id))
+}
+
+func (s *sqlUserStore) CountActiveAdmins() (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM users WHERE role = ? AND COALESCE(disabled, FALSE) = FALSE", models.RoleAdmin).Scan(&count)
+	return count, err
+}
+
+func (s *sqlUserStore) TOTP(id int64) (TOTPState, error) {
+	var st TOTPState
+	var secret sql.NullString
+	var enabled sql.NullBool
+	var lastStep sql.NullInt64
+	err := s.db.QueryRow("SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = ?", id).Scan(&secret, &enabled, &lastStep)
+	if err != nil {
+		return st, notFound(err)
+	}
+	st.Enabled = enabled.Bool
+	st.LastStep = lastStep.Int64
+	st.Secret, err = secrets.Decrypt(secret.String)
+	return st, err
+}
+
+func (s *sqlUserStore) SetTOTPSecret(id int64, secret string) error {
+	sealed, err := secrets.Encrypt(secret)
+	if err != nil {
+		return err
+	}
+	return mustAffect(s.db.Exec("UPDATE users SET totp_secret = ?, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?", sealed, id))
+}
+
+func (s *sqlUserStore) EnableTOTP(id int64, step int64, recoveryHashes []string) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		if err := mustAffect(tx.Exec("UPDATE users SET totp_enabled = TRUE, totp_last_step = ? WHERE id = ?", step, id)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", id); err != nil {
+			return err
+		}
+		for _, h := range recoveryHashes {
+			if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", id, h); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlUserStore) DisableTOTP(id int64) error {
+	return withTx(s.db, func(tx *sql.Tx) error {
+		return clearTOTP(tx, id)
+	})
+}
+
+// clearTOTP remove o segredo e os códigos de recuperação do usuário
+func clearTOTP(tx *sql.Tx, id int64) error {
+	if _, err := tx.Exec("UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?", id); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", id)
+	return err
+}
+
+func (s *sqlUserStore) ConsumeTOTPStep(id int64, step int64) (bool, error) {
+	res, err := s.db.Exec("UPDATE users SET totp_last_step = ? WHERE id = ? AND COALESCE(totp_last_step, 0) < ?", step, id, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *sqlUserStore) UseRecoveryCode(id int64, hash string) (bool, error) {
+	res, err := s.db.Exec(
+		"UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now(), id, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *sqlUserStore) RecoveryCodesLeft(id int64) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL", id).Scan(&count)
+	return count, err
+}
+
+func (s *sqlUserStore) PasswordChangedAt(username string) (*time.Time, error) {
+	var t sql.NullTime
+	err := s.db.QueryRow("SELECT password_changed_at FROM users WHERE username = ?", username).Scan(&t)
+	if err != nil || !t.Valid {
+		return nil, notFound(err)
+	}
+	return &t.Time, nil
+}
+
+// isUniqueViolation reconhece a violação de UNIQUE pela mensagem do driver
+func isUniqueViolation(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") || strings.Contains(msg, "duplicate key")
+}
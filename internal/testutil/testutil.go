@@ -0,0 +1,191 @@
+// Package testutil prepara o ambiente dos testes: um banco já migrado (SQLite em memória ou
+// PostgreSQL) e ligado aos repositórios (store.Use), como o sistema faz no boot, e o cliente
+// da Cloudflare trocado pelo FakeCloudflare, como no modo demo.
+package testutil
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"tempmail/internal/database"
+	"tempmail/internal/models"
+	"tempmail/internal/secrets"
+	"tempmail/internal/services"
+	"tempmail/internal/store"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var dbSeq atomic.Int64
+
+// DB abre um banco SQLite em memória exclusivo do teste, aplica as migrações e liga os
+// repositórios a ele. O banco some quando o teste termina.
+func DB(t testing.TB) *sql.DB {
+	t.Helper()
+	MasterKey(t)
+	// cache=shared faz as conexões do pool enxergarem o mesmo banco em memória
+	t.Setenv("DB_DRIVER", database.DialectSQLite)
+	t.Setenv("DATABASE_URL", fmt.Sprintf("file:testdb%d?mode=memory&cache=shared", dbSeq.Add(1)))
+	database.Open()
+	if _, err := database.Migrate(); err != nil {
+		t.Fatalf("migrações: %v", err)
+	}
+	db := database.DB
+	store.Use(db)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// MasterKey carrega uma chave mestra aleatória, como o boot faz com TEMPMAIL_MASTER_KEY
+func MasterKey(t testing.TB) []byte {
+	t.Helper()
+	key := make([]byte, secrets.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.SetMasterKey(key); err != nil {
+		t.Fatalf("chave mestra: %v", err)
+	}
+	return key
+}
+
+// Cloudflare troca o cliente da Cloudflare por um FakeCloudflare até o fim do teste
+func Cloudflare(t testing.TB) *services.FakeCloudflare {
+	t.Helper()
+	prev := services.CF
+	cf := services.NewFakeCloudflare(false)
+	services.CF = cf
+	t.Cleanup(func() { services.CF = prev })
+	return cf
+}
+
+// Domain cadastra um domínio com a zona "zone-<domínio>" e o token "token-<domínio>"; o
+// primeiro cadastrado vira o padrão
+func Domain(t testing.TB, name string) models.Domain {
+	t.Helper()
+	d := models.Domain{Domain: name, ZoneID: "zone-" + name, CFToken: "token-" + name, CreatedAt: time.Now()}
+	id, err := store.Domains.Save(d)
+	if err != nil {
+		t.Fatalf("criar domínio %s: %v", name, err)
+	}
+	d, err = store.Domains.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// Alias grava um alias ativo; ID, datas, estado e destino recebem valores padrão quando
+// vazios (expira em uma hora)
+func Alias(t testing.TB, e models.EmailEntry) models.EmailEntry {
+	t.Helper()
+	now := time.Now()
+	if e.ID == "" {
+		e.ID = "rule-" + e.Email
+	}
+	if e.Destination == "" && len(e.Destinations) == 0 {
+		e.Destination = "dono@example.org"
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = now
+	}
+	if e.ExpiresAt == nil {
+		exp := now.Add(time.Hour)
+		e.ExpiresAt = &exp
+		e.TTL = int64(time.Hour.Seconds())
+	}
+	if e.State == "" {
+		e.State = models.StateActive
+		e.Active = true
+	}
+	if err := store.Emails.Save(e, nil); err != nil {
+		t.Fatalf("criar alias %s: %v", e.Email, err)
+	}
+	saved, err := store.Emails.Get(e.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return saved
+}
+
+// Destination cadastra e confirma o destino na conta da zona do FakeCloudflare
+func Destination(t testing.TB, cf *services.FakeCloudflare, cfg models.Config, email string) {
+	t.Helper()
+	account, err := cf.GetAccountID(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.CreateDestination(cfg, account, email); err != nil {
+		t.Fatal(err)
+	}
+	cf.VerifyDestination(account, email)
+}
+
+// Postgres faz o mesmo que DB num PostgreSQL de verdade, apontado por DATABASE_URL; sem a
+// variável (ou com DB_DRIVER de outro banco) o teste é pulado. Tudo é criado num schema próprio, apagado no fim do teste,
+// então o banco pode ser o de desenvolvimento.
+func Postgres(t testing.TB) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("DATABASE_URL")
+	if driver := os.Getenv("DB_DRIVER"); dsn == "" || driver != "" && driver != database.DialectPostgres {
+		t.Skip("DATABASE_URL do PostgreSQL não definido: testes no PostgreSQL pulados")
+	}
+	MasterKey(t)
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	schema := fmt.Sprintf("tempmail_test_%d_%d", os.Getpid(), dbSeq.Add(1))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("criar schema de teste: %v", err)
+	}
+	t.Cleanup(func() {
+		if admin, err := sql.Open("postgres", dsn); err == nil {
+			admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+			admin.Close()
+		}
+	})
+
+	// search_path vai como parâmetro da conexão, valendo para todas as conexões do pool
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		dsn = u.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	t.Setenv("DB_DRIVER", database.DialectPostgres)
+	t.Setenv("DATABASE_URL", dsn)
+	database.Open()
+	if _, err := database.Migrate(); err != nil {
+		t.Fatalf("migrações: %v", err)
+	}
+	db := database.DB
+	store.Use(db)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// User cadastra um usuário. O hash usa o custo mínimo do bcrypt: o custo de produção
+// deixaria cada teste lento.
+func User(t testing.TB, username, password, role string) models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := models.User{Username: username, Password: string(hash), Role: role, CreatedAt: time.Now()}
+	if u.ID, err = store.Users.Create(u); err != nil {
+		t.Fatalf("criar usuário %s: %v", username, err)
+	}
+	return u
+}
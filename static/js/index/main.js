@@ -1,9 +1,15 @@
-const API_TOKEN = localStorage.getItem('token');
+let API_TOKEN = localStorage.getItem('token');
+
+// Limites de validade informados pelo servidor (em segundos)
+let ttlLimits = { default: 300, max: 604800 };
+const TTL_PRESETS = [300, 900, 3600, 6 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600];
 
 async function checkAccess() {
     try {
         const res = await fetch('/api/status');
         const status = await res.json();
+        if (status.default_ttl) ttlLimits.default = status.default_ttl;
+        if (status.max_ttl) ttlLimits.max = status.max_ttl;
 
         // Bloqueio 1: Se não houver usuário no DB
         if (!status.setup_done) {
@@ -38,6 +44,7 @@ async function logout() {
         console.error("Erro ao deslogar no backend:", e);
     } finally {
         localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
         window.location.href = '/auth.html';
     }
 }
@@ -71,6 +78,8 @@ async function changePassword(e) {
         });
 
         if (res.ok) {
+            // A troca de senha encerra as outras sessões e renova os tokens desta
+            storeTokens(await res.json());
             showToast('Senha alterada com sucesso!', 'success');
             e.target.reset();
         } else {
@@ -85,6 +94,67 @@ async function changePassword(e) {
     }
 }
 
+// --- 2FA (TOTP) ---
+async function loadTOTPStatus() {
+    const res = await apiFetch('/api/2fa');
+    if (!res || !res.ok) return;
+    const status = await res.json();
+    document.getElementById('totp-setup').classList.add('hidden');
+    document.getElementById('totp-disabled').classList.toggle('hidden', status.enabled);
+    document.getElementById('totp-enabled').classList.toggle('hidden', !status.enabled);
+    document.getElementById('totp-codes-left').innerText = `${status.recovery_codes_left} códigos de recuperação restantes`;
+}
+
+async function startTOTPSetup() {
+    const res = await apiFetch('/api/2fa/setup', { method: 'POST' });
+    if (!res || !res.ok) {
+        showToast(res ? await res.text() : 'Erro de conexão', 'error');
+        return;
+    }
+    const data = await res.json();
+    const qr = document.getElementById('totp-qr');
+    qr.innerHTML = '';
+    new QRCode(qr, { text: data.uri, width: 160, height: 160 });
+    document.getElementById('totp-secret').innerText = data.secret;
+    document.getElementById('totp-recovery').classList.add('hidden');
+    document.getElementById('totp-disabled').classList.add('hidden');
+    document.getElementById('totp-setup').classList.remove('hidden');
+}
+
+async function confirmTOTPSetup() {
+    const code = document.getElementById('totp-confirm-code').value;
+    const res = await apiFetch('/api/2fa/confirm', { method: 'POST', body: JSON.stringify({ code: code }) });
+    if (!res || !res.ok) {
+        showToast('Código inválido', 'error');
+        return;
+    }
+    const data = await res.json();
+    document.getElementById('totp-recovery-list').innerHTML = data.recovery_codes.map(c => `<span>${c}</span>`).join('');
+    document.getElementById('totp-recovery').classList.remove('hidden');
+    document.getElementById('totp-confirm-code').value = '';
+    showToast('2FA ativado!', 'success');
+    loadTOTPStatus();
+}
+
+async function disableTOTP(e) {
+    e.preventDefault();
+    const res = await apiFetch('/api/2fa/disable', {
+        method: 'POST',
+        body: JSON.stringify({
+            password: document.getElementById('totp-disable-password').value,
+            code: document.getElementById('totp-disable-code').value
+        })
+    });
+    if (!res || !res.ok) {
+        showToast(res ? await res.text() : 'Erro de conexão', 'error');
+        return;
+    }
+    e.target.reset();
+    document.getElementById('totp-recovery').classList.add('hidden');
+    showToast('2FA desativado.', 'success');
+    loadTOTPStatus();
+}
+
 // --- TAG SYSTEM CLASS (THUNDERBIRD STYLE) ---
 class TagSystem {
     constructor(inputId, containerId, suggestionId) {
@@ -198,6 +268,12 @@ class TagSystem {
         return this.tags;
     }
 
+    setTags(tags) {
+        this.reset();
+        this.tags = tags.slice();
+        this.render();
+    }
+
     render() {
         this.container.innerHTML = '';
         this.tags.forEach((tag, index) => {
@@ -240,17 +316,48 @@ const tagSystems = {};
 window.addEventListener('DOMContentLoaded', () => {
     tagSystems['tag-input-create'] = new TagSystem('tag-input-create', 'tags-container-create', 'suggestions-create');
     tagSystems['tag-input-custom'] = new TagSystem('tag-input-custom', 'tags-container-custom', 'suggestions-custom');
+    tagSystems['tag-input-edit'] = new TagSystem('tag-input-edit', 'tags-container-edit', 'suggestions-edit');
 });
 
 // --- API FETCH HELPER (WITH AUTH) ---
-async function apiFetch(url, options = {}) {
+function storeTokens(data) {
+    API_TOKEN = data.token;
+    localStorage.setItem('token', data.token);
+    localStorage.setItem('refresh_token', data.refresh_token);
+}
+
+// Troca o refresh token por um novo par; várias chamadas simultâneas compartilham a mesma renovação
+let refreshPromise = null;
+function refreshSession() {
+    if (!refreshPromise) {
+        refreshPromise = (async () => {
+            const refreshToken = localStorage.getItem('refresh_token');
+            if (!refreshToken) return false;
+            const res = await fetch('/api/refresh', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ refresh_token: refreshToken })
+            });
+            if (!res.ok) return false;
+            storeTokens(await res.json());
+            return true;
+        })().finally(() => { refreshPromise = null; });
+    }
+    return refreshPromise;
+}
+
+async function apiFetch(url, options = {}, retried = false) {
     options.headers = options.headers || {};
     options.headers['Authorization'] = `Bearer ${API_TOKEN}`;
     options.headers['Content-Type'] = 'application/json';
     
     const res = await fetch(url, options);
+    if (res.status === 401 && !retried && await refreshSession()) {
+        return apiFetch(url, options, true);
+    }
     if (res.status === 401 || res.status === 412) {
         localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
         window.location.href = '/auth.html';
         return;
     }
@@ -278,7 +385,10 @@ async function openCreateModal() {
     document.getElementById('create-modal-content').classList.add('hidden');
 
     tagSystems['tag-input-create'].reset();
-    await loadDestinations();
+    document.getElementById('modal-sender-lock').checked = false;
+    loadTTLOptions();
+    await loadDomains();
+    await loadDestinations(document.getElementById('modal-domain-select').value);
     document.getElementById('create-modal-loading').classList.add('hidden');
     document.getElementById('create-modal-content').classList.remove('hidden');
 }
@@ -294,13 +404,11 @@ async function openCustomModal() {
     document.getElementById('custom-modal-content').classList.add('hidden');
 
     tagSystems['tag-input-custom'].reset();
+    document.getElementById('custom-sender-lock').checked = false;
+    loadTTLOptions();
 
-    const destsPromise = loadDestinations();
-    const configPromise = apiFetch('/api/config').then(r => r.json()).then(cfg => {
-        if (cfg.domain) document.getElementById('domain-suffix').innerText = '@' + cfg.domain;
-    }).catch(() => { });
-
-    await Promise.all([destsPromise, configPromise]);
+    await loadDomains();
+    await loadDestinations(document.getElementById('custom-domain-select').value);
 
     document.getElementById('custom-modal-loading').classList.add('hidden');
     document.getElementById('custom-modal-content').classList.remove('hidden');
@@ -313,21 +421,28 @@ function closeCustomModal() {
 async function confirmCustomEmail() {
     const alias = document.getElementById('custom-alias-input').value.trim();
     const dest = document.getElementById('custom-dest-select').value;
-    const domainSuffix = document.getElementById('domain-suffix').innerText;
+    const domain = document.getElementById('custom-domain-select').value;
     const tags = tagSystems['tag-input-custom'].getTags();
+    const ttl = document.getElementById('custom-ttl-select').value;
 
     if (!alias) { alert("Digite um alias."); return; }
+    if (!domain) { alert("Cadastre um domínio primeiro."); return; }
 
-    const fullEmail = alias + domainSuffix;
+    const fullEmail = alias + '@' + domain;
     const checkRes = await apiFetch(`/api/check?email=${fullEmail}`);
     const checkData = await checkRes.json();
 
+    if (checkData.exists && !checkData.owned) {
+        showToast('Este endereço pertence a outro usuário.', 'error');
+        return;
+    }
+
     if (checkData.exists) {
         closeCustomModal();
         openConfirmModal(
             'Email Já Existe',
-            `O endereço ${fullEmail} já está no histórico. Deseja recriá-lo por mais 5 minutos?`,
-            () => executeRecreate(fullEmail, dest, tags),
+            `O endereço ${fullEmail} já está no histórico. Deseja recriá-lo por mais ${formatTTL(Number(ttl.replace('s', '')))}?`,
+            () => executeRecreate(fullEmail, [dest], tags, ttl),
             false
         );
         return;
@@ -340,7 +455,7 @@ async function confirmCustomEmail() {
     try {
         const res = await apiFetch('/api/create', {
             method: 'POST',
-            body: JSON.stringify({ email: fullEmail, destination: dest, tags: tags })
+            body: JSON.stringify({ email: fullEmail, destination: dest, tags: tags, ttl: ttl, sender_lock: document.getElementById('custom-sender-lock').checked })
         });
 
         if (res.ok) {
@@ -359,6 +474,105 @@ async function confirmCustomEmail() {
     }
 }
 
+// Alias em edição no modal (entrada vinda de /api/active)
+let editingEntry = null;
+
+async function openEditModal(id) {
+    const item = activeEntries[id];
+    if (!item) return;
+    editingEntry = item;
+
+    document.getElementById('edit-modal-email').innerText = item.email;
+    document.getElementById('edit-modal').classList.remove('hidden');
+    document.getElementById('edit-modal-loading').classList.remove('hidden');
+    document.getElementById('edit-modal-content').classList.add('hidden');
+
+    tagSystems['tag-input-edit'].setTags((item.tags || []).map(t => t.name));
+    document.getElementById('edit-allow-senders').value = (item.allow_senders || []).join(', ');
+    document.getElementById('edit-block-senders').value = (item.block_senders || []).join(', ');
+    document.getElementById('edit-sender-lock').checked = !!item.sender_lock;
+    await loadEditDestinations(item);
+
+    document.getElementById('edit-modal-loading').classList.add('hidden');
+    document.getElementById('edit-modal-content').classList.remove('hidden');
+}
+
+// Lista os destinos verificados da conta do domínio do alias, marcando os atuais
+async function loadEditDestinations(item) {
+    const container = document.getElementById('edit-dest-list');
+    container.innerHTML = '';
+    const current = item.destinations || [item.destination];
+    const domain = item.email.split('@')[1];
+
+    let list = [];
+    try {
+        const res = await apiFetch(`/api/destinations?domain=${encodeURIComponent(domain)}`);
+        if (res && res.ok) list = await res.json();
+    } catch (e) { }
+
+    // Destinos atuais que não aparecem mais na conta continuam visíveis para poder desmarcá-los
+    const emails = (list || []).map(d => d.email);
+    current.forEach(email => {
+        if (!emails.includes(email)) list.push({ email: email, verified: true });
+    });
+
+    list.forEach(d => {
+        const isVerified = !!d.verified;
+        const label = document.createElement('label');
+        label.className = `flex items-center gap-3 bg-slate-900 p-3 rounded border border-slate-700 cursor-pointer hover:border-slate-600 ${isVerified ? '' : 'opacity-50'}`;
+        label.innerHTML = `
+            <input type="checkbox" value="${d.email}" class="edit-dest-check accent-orange-500" ${current.includes(d.email) ? 'checked' : ''} ${isVerified ? '' : 'disabled'}>
+            <span class="text-slate-200 text-sm">${d.email}${isVerified ? '' : ' (Pendente)'}</span>
+        `;
+        container.appendChild(label);
+    });
+}
+
+// Lista de remetentes digitada separada por vírgula, ponto e vírgula ou espaço
+function splitSenders(text) {
+    return text.split(/[\s,;]+/).map(s => s.trim()).filter(s => s);
+}
+
+function closeEditModal() {
+    document.getElementById('edit-modal').classList.add('hidden');
+    editingEntry = null;
+}
+
+async function confirmEditEmail() {
+    if (!editingEntry) return;
+    const checked = Array.from(document.querySelectorAll('.edit-dest-check:checked')).map(c => c.value);
+    // Os destinos que já existiam mantêm a ordem (o primeiro é o principal)
+    const current = editingEntry.destinations || [editingEntry.destination];
+    const dests = current.filter(d => checked.includes(d)).concat(checked.filter(d => !current.includes(d)));
+    const tags = tagSystems['tag-input-edit'].getTags();
+    const allowSenders = splitSenders(document.getElementById('edit-allow-senders').value);
+    const blockSenders = splitSenders(document.getElementById('edit-block-senders').value);
+    if (dests.length === 0) { alert("Selecione ao menos um destino."); return; }
+
+    const btn = document.getElementById('btn-confirm-edit');
+    btn.innerHTML = '<i class="fa-solid fa-spinner fa-spin"></i> Salvando...';
+    btn.disabled = true;
+
+    try {
+        const res = await apiFetch('/api/update', {
+            method: 'PUT',
+            body: JSON.stringify({ id: editingEntry.id, destinations: dests, tags: tags, allow_senders: allowSenders, block_senders: blockSenders, sender_lock: document.getElementById('edit-sender-lock').checked })
+        });
+        if (res.ok) {
+            showToast('Email atualizado!', 'success');
+            closeEditModal();
+            loadActive();
+        } else {
+            showToast(await res.text(), 'error');
+        }
+    } catch (e) {
+        showToast('Erro de conexão', 'error');
+    } finally {
+        btn.innerHTML = '<i class="fa-solid fa-floppy-disk"></i> Salvar';
+        btn.disabled = false;
+    }
+}
+
 let pendingConfirmAction = null;
 
 function openConfirmModal(title, msg, actionCallback, isDestructive = false) {
@@ -404,7 +618,7 @@ function switchTab(tab) {
     document.querySelectorAll('.view-section').forEach(el => el.classList.add('hidden'));
     document.getElementById(`view-${tab}`).classList.remove('hidden');
     
-    const titles = { 'dashboard': 'Painel de Controle', 'history': 'Histórico de Emails', 'config': 'Configurações do Sistema' };
+    const titles = { 'dashboard': 'Painel de Controle', 'inbox': 'Caixa de Entrada', 'history': 'Histórico de Emails', 'config': 'Configurações do Sistema' };
     document.getElementById('page-title').innerText = titles[tab];
     
     const btnArea = document.getElementById('create-btn-area');
@@ -423,8 +637,13 @@ function switchTab(tab) {
     }
 
     if (tab === 'dashboard') loadActive();
+    if (tab === 'inbox') loadInboxAliases().then(loadInbox);
     if (tab === 'history') loadHistory();
-    if (tab === 'config') { loadConfig(); loadDestinations(); }
+    if (tab === 'config') {
+        loadConfig();
+        loadDomains().then(() => { loadDestinations(document.getElementById('dest-domain-select').value); loadCatchAll(); });
+        loadTOTPStatus();
+    }
 }
 
 function renderTagsHTML(tags) {
@@ -450,26 +669,44 @@ async function loadHistory() {
         const row = document.createElement('tr');
         row.className = "hover:bg-slate-800/50 transition border-b border-slate-700/50 last:border-0 history-row";
 
-        const statusHtml = item.active
+        let statusHtml = item.active
             ? '<span class="inline-flex items-center gap-1 bg-green-500/20 text-green-400 px-2 py-0.5 rounded text-xs border border-green-500/30"><span class="w-1.5 h-1.5 rounded-full bg-green-500"></span>ATIVO</span>'
             : '<span class="inline-flex items-center gap-1 bg-slate-700 text-slate-400 px-2 py-0.5 rounded text-xs">EXPIRADO</span>';
+        if (item.state === 'burned') {
+            statusHtml = '<span class="inline-flex items-center gap-1 bg-red-500/20 text-red-400 px-2 py-0.5 rounded text-xs border border-red-500/30"><i class="fa-solid fa-fire"></i>QUEIMADO</span>';
+        }
 
         let actionBtn = '';
-        if (!item.active) {
+        if (item.state === 'burned') {
+            actionBtn = `
+                <button onclick="confirmBurn('${item.id}', false)" class="text-green-500 hover:text-white hover:bg-green-600 px-3 py-1.5 rounded transition text-xs font-bold flex items-center gap-1 ml-auto border border-green-500/30 hover:border-green-500">
+                    <i class="fa-solid fa-rotate-left"></i> Reativar
+                </button>`;
+        } else if (!item.active) {
             const tagsList = item.tags ? item.tags.map(t => t.name) : [];
             const tagsJson = JSON.stringify(tagsList).replace(/"/g, '&quot;');
+            const destsJson = JSON.stringify(item.destinations || [item.destination]).replace(/"/g, '&quot;');
             actionBtn = `
-                <button onclick="confirmRecreate('${item.email}', '${item.destination}', ${tagsJson})" class="text-orange-500 hover:text-white hover:bg-orange-600 px-3 py-1.5 rounded transition text-xs font-bold flex items-center gap-1 ml-auto border border-orange-500/30 hover:border-orange-500">
+                <button onclick="confirmRecreate('${item.email}', ${destsJson}, ${tagsJson})" class="text-orange-500 hover:text-white hover:bg-orange-600 px-3 py-1.5 rounded transition text-xs font-bold flex items-center gap-1 ml-auto border border-orange-500/30 hover:border-orange-500">
                     <i class="fa-solid fa-rotate-right"></i> Recriar
                 </button>`;
         }
 
+        const updatedHtml = item.updated_at
+            ? `<div class="text-xs text-slate-600" title="Destino, tags ou remetentes alterados"><i class="fa-solid fa-pen"></i> ${new Date(item.updated_at).toLocaleString()}</div>`
+            : '';
+
+        // Expiração automática (uso único): o motivo fica visível para o dono mesmo sem relay
+        const reasonHtml = !item.active && item.expire_reason
+            ? `<div class="text-xs text-red-400 mt-1"><i class="fa-solid fa-fire"></i> ${escapeHTML(item.expire_reason)}</div>`
+            : '';
+
         const tagsStr = getTagsString(item.tags);
         row.innerHTML = `
-            <td class="p-4 font-mono text-white select-all alias-cell">${item.email}</td>
+            <td class="p-4"><div class="font-mono text-white select-all alias-cell">${item.email}</div><div class="text-xs text-slate-500 mt-1">${item.message_count ? deliveryStatsHTML(item) : 'Nunca recebeu'}</div>${reasonHtml}</td>
             <td class="p-4"><div class="flex flex-wrap max-w-[200px]">${renderTagsHTML(item.tags)}</div><span class="hidden tags-search-val">${tagsStr}</span></td>
-            <td class="p-4 text-slate-400 text-xs dest-cell">${item.destination}</td>
-            <td class="p-4 text-slate-500">${new Date(item.created_at).toLocaleString()}</td>
+            <td class="p-4 text-slate-400 text-xs dest-cell">${(item.destinations || [item.destination]).join('<br>')}</td>
+            <td class="p-4 text-slate-500">${new Date(item.created_at).toLocaleString()}${updatedHtml}</td>
             <td class="p-4 text-center">${statusHtml}</td>
             <td class="p-4 text-right">${actionBtn}</td>
         `;
@@ -477,6 +714,23 @@ async function loadHistory() {
     });
 }
 
+// Indica no card que o alias filtra remetentes (a lista aparece no título) ou é de uso único
+function sendersHTML(item) {
+    const allow = item.allow_senders || [];
+    const block = item.block_senders || [];
+    const lock = item.sender_lock ? ` · <span title="Uso único: outro remetente expira o alias"><i class="fa-solid fa-lock"></i></span>` : '';
+    if (!allow.length && !block.length) return lock;
+    const title = (allow.length ? `Permitidos: ${allow.join(', ')}` : '') + (allow.length && block.length ? '\n' : '') + (block.length ? `Bloqueados: ${block.join(', ')}` : '');
+    return `${lock} · <span title="${escapeHTML(title)}"><i class="fa-solid fa-filter"></i></span>`;
+}
+
+// Resumo das entregas do alias: quantidade, último recebimento e principais remetentes
+function deliveryStatsHTML(item) {
+    const last = item.last_received_at ? `Último em ${new Date(item.last_received_at).toLocaleString()}` : '';
+    const senders = (item.sender_domains || []).length ? `\nDe: ${item.sender_domains.join(', ')}` : '';
+    return `<span title="${escapeHTML(last + senders)}"><i class="fa-solid fa-envelope"></i> ${item.message_count}</span>`;
+}
+
 function filterHistory() {
     const term = document.getElementById('history-search').value.toLowerCase();
     const rows = document.querySelectorAll('.history-row');
@@ -492,21 +746,21 @@ function filterHistory() {
     });
 }
 
-function confirmRecreate(email, destination, tags) {
+function confirmRecreate(email, destinations, tags) {
     openConfirmModal(
         'Recriar Email',
-        `Deseja reativar o endereço ${email} por mais 5 minutos?`,
-        () => executeRecreate(email, destination, tags),
+        `Deseja reativar o endereço ${email} por mais ${formatTTL(ttlLimits.default)}?`,
+        () => executeRecreate(email, destinations, tags),
         false
     );
 }
 
-async function executeRecreate(email, destination, tags) {
+async function executeRecreate(email, destinations, tags, ttl) {
     showToast('Recriando...', 'success');
     try {
         const res = await apiFetch('/api/create', {
             method: 'POST',
-            body: JSON.stringify({ email: email, destination: destination, tags: tags })
+            body: JSON.stringify({ email: email, destinations: destinations, tags: tags, ttl: ttl })
         });
 
         if (res.ok) {
@@ -547,20 +801,21 @@ function confirmDeleteDest(id) {
 
 async function executeDeleteDest(id) {
     try {
-        const res = await apiFetch(`/api/destinations?id=${id}`, { method: 'DELETE' });
+        const domain = document.getElementById('dest-domain-select').value;
+        const res = await apiFetch(`/api/destinations?id=${id}&domain=${encodeURIComponent(domain)}`, { method: 'DELETE' });
         if (res.ok) {
             showToast('Destino removido.', 'success');
-            loadDestinations();
+            loadDestinations(domain);
         } else {
             showToast('Erro ao remover.', 'error');
         }
     } catch (e) { showToast('Erro de conexão', 'error'); }
 }
 
-function confirmPin(id, isCurrentlyPinned) {
+function confirmPin(id, isCurrentlyPinned, ttl) {
     const action = isCurrentlyPinned ? "Desafixar" : "Fixar";
     const msg = isCurrentlyPinned
-        ? "Ao desafixar, o email expirará em 5 minutos. Deseja continuar?"
+        ? `Ao desafixar, o email expirará em ${formatTTL(ttl)}. Deseja continuar?`
         : "Ao fixar, o email NÃO expirará automaticamente. Deseja continuar?";
 
     openConfirmModal(
@@ -571,6 +826,35 @@ function confirmPin(id, isCurrentlyPinned) {
     );
 }
 
+function confirmBurn(id, burn) {
+    openConfirmModal(
+        burn ? 'Queimar Email' : 'Reativar Email',
+        burn
+            ? 'Os emails para este endereço serão descartados (nem o catch-all recebe) e o endereço fica reservado. Deseja continuar?'
+            : 'O endereço volta a encaminhar para o destino e a contar a validade. Deseja continuar?',
+        () => executeBurn(id, burn),
+        burn
+    );
+}
+
+async function executeBurn(id, burn) {
+    try {
+        const res = await apiFetch('/api/burn', {
+            method: 'POST',
+            body: JSON.stringify({ id: id, burned: burn })
+        });
+        if (res.ok) {
+            showToast(burn ? 'Email queimado.' : 'Email reativado!', 'success');
+            loadActive();
+            loadHistory();
+        } else {
+            showToast(await res.text(), 'error');
+        }
+    } catch (e) {
+        showToast('Erro de conexão', 'error');
+    }
+}
+
 async function executePin(id, newState) {
     try {
         const res = await apiFetch('/api/pin', {
@@ -579,7 +863,7 @@ async function executePin(id, newState) {
         });
 
         if (res.ok) {
-            showToast(newState ? 'Email Fixado!' : 'Email Desafixado (5 min restantes)', 'success');
+            showToast(newState ? 'Email Fixado!' : 'Email Desafixado', 'success');
             loadActive();
         } else {
             showToast('Erro ao alterar status', 'error');
@@ -589,7 +873,9 @@ async function executePin(id, newState) {
     }
 }
 
-async function loadDestinations() {
+// Os destinos são da conta Cloudflare de cada domínio; sem domínio usa o padrão.
+// refresh ignora o cache do servidor (ex: depois de confirmar um destino pelo email)
+async function loadDestinations(domain = '', refresh = false) {
     const container = document.getElementById('dest-list');
     const viewConfig = document.getElementById('view-config');
     if (viewConfig && !viewConfig.classList.contains('hidden')) {
@@ -597,7 +883,11 @@ async function loadDestinations() {
     }
 
     try {
-        const res = await apiFetch('/api/destinations');
+        const params = new URLSearchParams();
+        if (domain) params.set('domain', domain);
+        if (refresh) params.set('refresh', '1');
+        const query = params.toString() ? '?' + params.toString() : '';
+        const res = await apiFetch('/api/destinations' + query);
         if (!res) return;
         if (!res.ok) {
             const err = await res.text();
@@ -667,7 +957,8 @@ async function confirmAddDest() {
     btn.disabled = true;
 
     try {
-        const res = await apiFetch('/api/destinations', {
+        const domain = document.getElementById('dest-domain-select').value;
+        const res = await apiFetch(`/api/destinations?domain=${encodeURIComponent(domain)}`, {
             method: 'POST',
             body: JSON.stringify({ email: email })
         });
@@ -676,7 +967,7 @@ async function confirmAddDest() {
             showToast('Email adicionado! Verifique sua caixa de entrada.', 'success');
             input.value = '';
             closeAddDestModal();
-            loadDestinations();
+            loadDestinations(domain);
         } else {
             const txt = await res.text();
             showToast(txt, 'error');
@@ -713,13 +1004,324 @@ async function saveConfig(e) {
     if (res && res.ok) {
         showToast('Credenciais Salvas!', 'success');
         loadConfig();
-        loadDestinations();
+        loadDomains().then(() => loadDestinations(document.getElementById('dest-domain-select').value));
     }
 }
 
+// --- CAIXA DE ENTRADA ---
+
+// Remetente, assunto e nomes de anexo vêm de terceiros: nunca entram no HTML sem escapar
+// Escapa texto para HTML, inclusive aspas: o resultado também vai dentro de atributos (title)
+function escapeHTML(str) {
+    const div = document.createElement('div');
+    div.textContent = str == null ? '' : String(str);
+    return div.innerHTML.replace(/"/g, '&quot;').replace(/'/g, '&#39;');
+}
+
+function formatSize(bytes) {
+    if (bytes < 1024) return `${bytes} B`;
+    if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+    return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+}
+
+async function loadInboxAliases() {
+    const res = await apiFetch('/api/active');
+    if (!res) return;
+    const list = await res.json();
+    const select = document.getElementById('inbox-alias');
+    const current = select.value;
+    select.innerHTML = '<option value="">Todos os aliases</option>';
+    (list || []).forEach(item => {
+        const opt = document.createElement('option');
+        opt.value = item.email;
+        opt.textContent = item.email;
+        select.appendChild(opt);
+    });
+    select.value = current;
+}
+
+async function loadInbox() {
+    const alias = document.getElementById('inbox-alias').value;
+    const params = new URLSearchParams();
+    if (alias) params.set('alias', alias);
+    const res = await apiFetch(`/api/messages?${params}`);
+    if (!res) return;
+    const list = await res.json();
+    const container = document.getElementById('inbox-list');
+    container.innerHTML = '';
+    document.getElementById('inbox-detail').classList.add('hidden');
+    document.getElementById('empty-inbox').classList.toggle('hidden', list && list.length > 0);
+
+    (list || []).forEach(msg => {
+        const row = document.createElement('button');
+        row.className = `w-full text-left p-4 hover:bg-slate-700/50 transition ${msg.seen ? 'text-slate-400' : 'text-white'}`;
+        row.onclick = () => openMessage(msg.id, row);
+        row.innerHTML = `
+            <div class="flex justify-between gap-2 text-xs mb-1">
+                <span class="truncate ${msg.seen ? '' : 'font-bold'}">${escapeHTML(msg.from || msg.mail_from || '(sem remetente)')}</span>
+                <span class="text-slate-500 shrink-0">${new Date(msg.received_at).toLocaleString()}</span>
+            </div>
+            <div class="truncate text-sm ${msg.seen ? '' : 'font-bold'}">${escapeHTML(msg.subject || '(sem assunto)')}</div>
+            <div class="truncate text-xs font-mono text-slate-500 mt-1">${escapeHTML(msg.alias)}</div>
+        `;
+        container.appendChild(row);
+    });
+}
+
+async function openMessage(id, row) {
+    const res = await apiFetch(`/api/messages?id=${id}`);
+    if (!res) return;
+    if (!res.ok) { showToast(await res.text(), 'error'); return; }
+    const msg = await res.json();
+    if (row) row.classList.replace('text-white', 'text-slate-400');
+
+    document.getElementById('msg-subject').textContent = msg.subject || '(sem assunto)';
+    document.getElementById('msg-from').textContent = msg.from || msg.mail_from || '(sem remetente)';
+    document.getElementById('msg-alias').textContent = msg.alias;
+    document.getElementById('msg-date').textContent = new Date(msg.received_at).toLocaleString();
+    document.getElementById('msg-delete').onclick = () => confirmDeleteMessage(msg.id);
+
+    const attachments = document.getElementById('msg-attachments');
+    attachments.innerHTML = '';
+    (msg.attachments || []).forEach(a => {
+        const btn = document.createElement('button');
+        btn.className = 'bg-slate-900 border border-slate-700 hover:border-orange-500 text-slate-300 px-3 py-1.5 rounded-lg text-xs transition flex items-center gap-2';
+        btn.innerHTML = `<i class="fa-solid fa-paperclip"></i> ${escapeHTML(a.filename)} <span class="text-slate-500">${formatSize(a.size)}</span>`;
+        btn.onclick = () => downloadAttachment(a.id, a.filename);
+        attachments.appendChild(btn);
+    });
+
+    // O HTML fica em um iframe sandbox: sem scripts, sem formulários e sem acesso ao painel
+    document.getElementById('msg-html').srcdoc = msg.html || '';
+    document.getElementById('msg-text').textContent = msg.text || '';
+    document.getElementById('msg-tab-html').disabled = !msg.html;
+    showMessageBody(msg.html ? 'html' : 'text');
+    document.getElementById('inbox-detail').classList.remove('hidden');
+}
+
+function showMessageBody(kind) {
+    document.getElementById('msg-html').classList.toggle('hidden', kind !== 'html');
+    document.getElementById('msg-text').classList.toggle('hidden', kind !== 'text');
+    ['html', 'text'].forEach(k => {
+        const tab = document.getElementById(`msg-tab-${k}`);
+        tab.classList.toggle('bg-slate-700', k === kind);
+        tab.classList.toggle('text-white', k === kind);
+        tab.classList.toggle('text-slate-400', k !== kind);
+    });
+}
+
+async function downloadAttachment(id, filename) {
+    const res = await apiFetch(`/api/messages/attachment?id=${id}`);
+    if (!res) return;
+    if (!res.ok) { showToast(await res.text(), 'error'); return; }
+    const url = URL.createObjectURL(await res.blob());
+    const link = document.createElement('a');
+    link.href = url;
+    link.download = filename;
+    link.click();
+    setTimeout(() => URL.revokeObjectURL(url), 1000);
+}
+
+function confirmDeleteMessage(id) {
+    openConfirmModal(
+        'Apagar Mensagem',
+        'A mensagem e seus anexos serão apagados. Tem certeza?',
+        () => executeDeleteMessage(id),
+        true
+    );
+}
+
+async function executeDeleteMessage(id) {
+    const res = await apiFetch(`/api/messages?id=${id}`, { method: 'DELETE' });
+    if (!res) return;
+    if (!res.ok) { showToast(await res.text(), 'error'); return; }
+    showToast('Mensagem apagada.', 'success');
+    loadInbox();
+}
+
+// --- CATCH-ALL ---
+
+async function loadCatchAll() {
+    const domain = document.getElementById('catchall-domain-select').value;
+    if (!domain) return;
+    try {
+        const [ruleRes, destRes] = await Promise.all([
+            apiFetch(`/api/catch-all?domain=${encodeURIComponent(domain)}`),
+            apiFetch(`/api/destinations?domain=${encodeURIComponent(domain)}`)
+        ]);
+        if (!ruleRes || !ruleRes.ok) return;
+        const rule = await ruleRes.json();
+        const dests = destRes && destRes.ok ? await destRes.json() : [];
+
+        const sel = document.getElementById('catchall-dest');
+        sel.innerHTML = '';
+        dests.filter(d => !!d.verified).forEach(d => {
+            const opt = document.createElement('option');
+            opt.value = d.email;
+            opt.innerText = d.email;
+            sel.appendChild(opt);
+        });
+        document.getElementById('catchall-action').value = rule.action;
+        if (rule.destination) sel.value = rule.destination;
+        toggleCatchAllDest();
+    } catch (e) { }
+}
+
+function toggleCatchAllDest() {
+    const forward = document.getElementById('catchall-action').value === 'forward';
+    document.getElementById('catchall-dest').classList.toggle('hidden', !forward);
+}
+
+async function saveCatchAll(e) {
+    e.preventDefault();
+    const domain = document.getElementById('catchall-domain-select').value;
+    const body = {
+        action: document.getElementById('catchall-action').value,
+        destination: document.getElementById('catchall-dest').value
+    };
+    const res = await apiFetch(`/api/catch-all?domain=${encodeURIComponent(domain)}`, { method: 'PUT', body: JSON.stringify(body) });
+    if (res && res.ok) {
+        showToast('Catch-all atualizado!', 'success');
+    } else if (res) {
+        showToast(await res.text(), 'error');
+    }
+}
+
+// --- DOMÍNIOS ---
+
+let domainsCache = [];
+
+async function loadDomains() {
+    try {
+        const res = await apiFetch('/api/domains');
+        if (!res || !res.ok) return;
+        domainsCache = await res.json();
+    } catch (e) { return; }
+
+    document.querySelectorAll('.domain-select-target').forEach(sel => {
+        const previous = sel.value;
+        const prefix = sel.dataset.prefix || '';
+        sel.innerHTML = '';
+        domainsCache.forEach(d => {
+            const opt = document.createElement('option');
+            opt.value = d.domain;
+            opt.innerText = prefix + d.domain + (d.is_default && !prefix ? ' (padrão)' : '');
+            sel.appendChild(opt);
+        });
+        if (sel.dataset.random === 'true' && domainsCache.length > 1) {
+            const opt = document.createElement('option');
+            opt.value = 'random';
+            opt.innerText = 'Aleatório';
+            sel.appendChild(opt);
+        }
+        if (previous && [...sel.options].some(o => o.value === previous)) sel.value = previous;
+    });
+
+    const container = document.getElementById('domain-list');
+    if (!container) return;
+    container.innerHTML = '';
+    if (domainsCache.length === 0) {
+        container.innerHTML = '<p class="text-slate-500 italic text-sm">Nenhum domínio cadastrado.</p>';
+        return;
+    }
+    domainsCache.forEach(d => {
+        const badge = d.is_default
+            ? `<span class="text-xs bg-orange-500/10 text-orange-400 border border-orange-500/20 px-2 py-0.5 rounded font-bold uppercase tracking-wider">Padrão</span>`
+            : `<button onclick="setDefaultDomain(${d.id})" class="text-xs text-slate-400 hover:text-orange-400 transition">Tornar padrão</button>`;
+        const item = document.createElement('div');
+        item.className = 'flex items-center justify-between bg-slate-900 p-3 rounded border border-slate-700 transition hover:border-slate-600';
+        item.innerHTML = `
+            <div class="flex items-center gap-3">
+                <i class="fa-solid fa-globe text-slate-500"></i>
+                <div>
+                    <div class="text-slate-200 font-medium">${d.domain}</div>
+                    <div class="text-xs text-slate-500 font-mono">${d.zone_id}</div>
+                </div>
+            </div>
+            <div class="flex items-center gap-3">
+                <input type="text" value="${d.worker || ''}" onchange="setDomainWorker(${d.id}, this.value)" placeholder="Worker" title="Email Worker da zona (vazio = encaminhar direto)" class="w-32 bg-slate-800 border border-slate-700 rounded px-2 py-1 text-xs text-slate-300 font-mono focus:border-orange-500 outline-none">
+                ${badge}
+                <button onclick="confirmDeleteDomain(${d.id}, '${d.domain}')" class="text-slate-600 hover:text-red-500 px-3 py-2 transition rounded hover:bg-red-500/10">
+                    <i class="fa-solid fa-trash"></i>
+                </button>
+            </div>
+        `;
+        container.appendChild(item);
+    });
+}
+
+async function addDomain(e) {
+    e.preventDefault();
+    const btn = document.getElementById('btn-add-domain');
+    btn.disabled = true;
+    try {
+        const res = await apiFetch('/api/domains', {
+            method: 'POST',
+            body: JSON.stringify({
+                domain: document.getElementById('new-domain-name').value,
+                zone_id: document.getElementById('new-domain-zone').value,
+                cf_token: document.getElementById('new-domain-token').value,
+                worker: document.getElementById('new-domain-worker').value
+            })
+        });
+        if (res.ok) {
+            showToast('Domínio adicionado!', 'success');
+            e.target.reset();
+            loadDomains();
+        } else {
+            showToast(await res.text(), 'error');
+        }
+    } catch (err) {
+        showToast('Erro de conexão', 'error');
+    } finally {
+        btn.disabled = false;
+    }
+}
+
+async function setDefaultDomain(id) {
+    const res = await apiFetch(`/api/domains?id=${id}`, { method: 'PUT', body: JSON.stringify({ is_default: true }) });
+    if (res && res.ok) {
+        showToast('Domínio padrão alterado.', 'success');
+        loadConfig();
+        loadDomains();
+    } else if (res) {
+        showToast(await res.text(), 'error');
+    }
+}
+
+async function setDomainWorker(id, worker) {
+    const res = await apiFetch(`/api/domains?id=${id}`, { method: 'PUT', body: JSON.stringify({ worker: worker.trim() }) });
+    if (res && res.ok) {
+        showToast(worker.trim() ? 'Novas regras irão para o Worker.' : 'Novas regras encaminham direto.', 'success');
+        loadDomains();
+    } else if (res) {
+        showToast(await res.text(), 'error');
+    }
+}
+
+function confirmDeleteDomain(id, domain) {
+    openConfirmModal(
+        'Remover Domínio',
+        `Remover ${domain}? As regras já criadas na Cloudflare não são apagadas; domínios com aliases ativos não podem ser removidos.`,
+        async () => {
+            const res = await apiFetch(`/api/domains?id=${id}`, { method: 'DELETE' });
+            if (res && res.ok) {
+                showToast('Domínio removido.', 'success');
+                loadConfig();
+                loadDomains();
+            } else if (res) {
+                showToast(await res.text(), 'error');
+            }
+        },
+        true
+    );
+}
+
 async function confirmCreateEmail() {
     const dest = document.getElementById('modal-dest-select').value;
+    const domain = document.getElementById('modal-domain-select').value;
     const tags = tagSystems['tag-input-create'].getTags();
+    const ttl = document.getElementById('modal-ttl-select').value;
 
     if (!dest) { alert("Selecione um destino válido."); return; }
     const btn = document.getElementById('btn-confirm-create');
@@ -729,7 +1331,7 @@ async function confirmCreateEmail() {
     try {
         const res = await apiFetch('/api/create', {
             method: 'POST',
-            body: JSON.stringify({ destination: dest, tags: tags })
+            body: JSON.stringify({ destination: dest, domain: domain, tags: tags, ttl: ttl, sender_lock: document.getElementById('modal-sender-lock').checked })
         });
 
         if (res.ok) {
@@ -748,10 +1350,15 @@ async function confirmCreateEmail() {
     }
 }
 
+// Últimos aliases ativos carregados, por ID (usados pelo modal de edição)
+let activeEntries = {};
+
 async function loadActive() {
     const res = await apiFetch('/api/active');
     if (!res) return;
     const list = await res.json();
+    activeEntries = {};
+    (list || []).forEach(item => { activeEntries[item.id] = item; });
     const grid = document.getElementById('active-grid');
     grid.innerHTML = '';
     if (!list || list.length === 0) {
@@ -761,7 +1368,7 @@ async function loadActive() {
     document.getElementById('empty-dashboard').classList.add('hidden');
     list.forEach(item => {
         const created = new Date(item.created_at);
-        const expires = new Date(created.getTime() + 5 * 60000);
+        const expires = item.expires_at ? new Date(item.expires_at) : new Date(created.getTime() + item.ttl * 1000);
 
         const isPinned = item.pinned;
         const borderClass = isPinned ? 'border-green-500/50' : 'border-slate-700';
@@ -773,14 +1380,15 @@ async function loadActive() {
         const card = document.createElement('div');
         card.className = `bg-slate-800 border ${borderClass} rounded-xl p-5 relative overflow-hidden group hover:border-orange-500/50 transition shadow-lg`;
 
+        const dests = item.destinations || [item.destination];
         const tagsHtml = renderTagsHTML(item.tags);
         const tagsContainer = tagsHtml ? `<div class="mb-3 flex flex-wrap">${tagsHtml}</div>` : '';
 
         card.innerHTML = `
             <div class="absolute top-0 left-0 h-1 ${progressColor} w-full transition-all duration-1000" id="prog-${item.id}" style="width: ${progressWidth}"></div>
             <div class="flex justify-between items-start mb-2">
-                <div class="text-xs text-slate-400 font-mono flex items-center gap-1 max-w-[65%] truncate" title="${item.destination}">
-                    <i class="fa-solid fa-arrow-right-long text-slate-600"></i> ${item.destination}
+                <div class="text-xs text-slate-400 font-mono flex items-center gap-1 max-w-[65%] truncate" title="${dests.join(', ')}">
+                    <i class="fa-solid fa-arrow-right-long text-slate-600"></i> ${dests[0]}${dests.length > 1 ? ` <span class="text-orange-400">+${dests.length - 1}</span>` : ''}
                 </div>
                 <span id="timer-${item.id}" class="font-mono font-bold text-white bg-slate-900 px-2 py-1 rounded text-sm">${timerDisplay}</span>
             </div>
@@ -789,23 +1397,29 @@ async function loadActive() {
                 <code class="text-lg text-white font-bold cursor-pointer hover:text-orange-400 transition break-all select-all" onclick="copyText('${item.email}')">
                     ${item.email}
                 </code>
-                <p class="text-xs text-slate-500 mt-1">Clique para copiar</p>
+                <p class="text-xs text-slate-500 mt-1">Clique para copiar${item.message_count ? ` · ${deliveryStatsHTML(item)}` : ''}${sendersHTML(item)}</p>
             </div>
             <div class="flex gap-2">
-                <button onclick="confirmPin('${item.id}', ${isPinned})" class="flex-1 ${pinBtnColor} border border-transparent py-2 rounded-lg font-bold text-sm transition flex items-center justify-center gap-2" title="${isPinned ? 'Desafixar' : 'Fixar para não expirar'}">
+                <button onclick="confirmPin('${item.id}', ${isPinned}, ${item.ttl})" class="flex-1 ${pinBtnColor} border border-transparent py-2 rounded-lg font-bold text-sm transition flex items-center justify-center gap-2" title="${isPinned ? 'Desafixar' : 'Fixar para não expirar'}">
                     <i class="fa-solid fa-thumbtack ${isPinned ? '' : 'rotate-45'}"></i>
                 </button>
+                <button onclick="openEditModal('${item.id}')" class="flex-1 text-slate-400 hover:text-white hover:bg-slate-700 border border-transparent py-2 rounded-lg font-bold text-sm transition flex items-center justify-center gap-2" title="Editar destino, tags e remetentes">
+                    <i class="fa-solid fa-pen"></i>
+                </button>
+                <button onclick="confirmBurn('${item.id}', true)" class="flex-1 text-slate-400 hover:text-white hover:bg-red-900/60 border border-transparent py-2 rounded-lg font-bold text-sm transition flex items-center justify-center gap-2" title="Queimar: descarta tudo e reserva o endereço">
+                    <i class="fa-solid fa-fire"></i>
+                </button>
                 <button onclick="confirmDeleteEmail('${item.id}')" class="flex-[3] bg-slate-700 hover:bg-red-600 text-slate-300 hover:text-white py-2 rounded-lg font-bold text-sm transition flex items-center justify-center gap-2">
                     <i class="fa-solid fa-trash"></i> Destruir
                 </button>
             </div>
         `;
         grid.appendChild(card);
-        if (!isPinned) startLocalTimer(item.id, expires);
+        if (!isPinned) startLocalTimer(item.id, expires, item.ttl);
     });
 }
 
-function startLocalTimer(id, expires) {
+function startLocalTimer(id, expires, total) {
     const el = document.getElementById(`timer-${id}`);
     const prog = document.getElementById(`prog-${id}`);
     if (!el) return;
@@ -813,21 +1427,47 @@ function startLocalTimer(id, expires) {
     window[`timer_${id}`] = setInterval(() => {
         const now = new Date();
         const diff = Math.floor((expires - now) / 1000);
-        const total = 300;
         if (diff <= 0) {
             clearInterval(window[`timer_${id}`]);
             el.innerText = "00:00";
             prog.style.width = "0%";
             loadActive();
         } else {
-            const m = Math.floor(diff / 60);
+            const h = Math.floor(diff / 3600);
+            const m = Math.floor((diff % 3600) / 60);
             const s = diff % 60;
-            el.innerText = `${m}:${s.toString().padStart(2, '0')}`;
-            prog.style.width = `${(diff / total) * 100}%`;
+            el.innerText = h > 0
+                ? `${h}:${m.toString().padStart(2, '0')}:${s.toString().padStart(2, '0')}`
+                : `${m}:${s.toString().padStart(2, '0')}`;
+            prog.style.width = `${Math.min(100, (diff / total) * 100)}%`;
         }
     }, 1000);
 }
 
+function formatTTL(seconds) {
+    if (seconds % 86400 === 0) return `${seconds / 86400} dia(s)`;
+    if (seconds % 3600 === 0) return `${seconds / 3600} hora(s)`;
+    return `${Math.round(seconds / 60)} minutos`;
+}
+
+// Preenche os selects de validade com os presets permitidos pelo servidor
+function loadTTLOptions() {
+    const options = TTL_PRESETS.filter(s => s <= ttlLimits.max);
+    if (!options.includes(ttlLimits.default)) options.push(ttlLimits.default);
+    options.sort((a, b) => a - b);
+
+    document.querySelectorAll('.ttl-select-target').forEach(sel => {
+        sel.innerHTML = '';
+        options.forEach(sec => {
+            const opt = document.createElement('option');
+            opt.value = `${sec}s`;
+            opt.innerText = formatTTL(sec);
+            if (sec === ttlLimits.default) opt.selected = true;
+            sel.appendChild(opt);
+        });
+    });
+}
+
 function copyText(txt) {
     navigator.clipboard.writeText(txt);
     showToast('Endereço copiado!', 'success');